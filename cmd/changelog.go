@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Write release notes from done tasks",
+	Long: `Renders the tasks finished in a milestone, or since a date or git tag, as a Keep a Changelog section grouped by task type: features under Added, improvements under Changed, bugs under Fixed and chores under Maintenance. Task refs and commits link to GitHub when the repository has a GitHub remote.
+
+--template takes a Go text/template file, executed with the same data --json prints.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		milestone, _ := cmd.Flags().GetString("milestone")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		version, _ := cmd.Flags().GetString("version")
+		date, _ := cmd.Flags().GetString("date")
+		templatePath, _ := cmd.Flags().GetString("template")
+		prepend, _ := cmd.Flags().GetString("prepend")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if milestone == "" && sinceFlag == "" {
+			return fmt.Errorf("give --milestone or --since")
+		}
+		if asJSON && (templatePath != "" || prepend != "") {
+			return fmt.Errorf("--json cannot be used with --template or --prepend")
+		}
+		var since time.Time
+		if sinceFlag != "" {
+			if since, err = time.ParseInLocation(models.DateLayout, sinceFlag, time.Local); err != nil {
+				if since, err = project.GitRefTime(root, sinceFlag); err != nil {
+					return err
+				}
+			}
+		}
+		if date != "" {
+			if _, err := time.Parse(models.DateLayout, date); err != nil {
+				return fmt.Errorf("--date must be YYYY-MM-DD")
+			}
+		}
+
+		if version == "" {
+			version = milestone
+		}
+		if version != "" && date == "" {
+			date = time.Now().Format(models.DateLayout)
+			if m, err := s.GetMilestone(milestone); err == nil && m.ClosedAt != nil {
+				date = m.ClosedAt.Local().Format(models.DateLayout)
+			}
+		}
+
+		tasks, err := s.ChangelogTasks(milestone, since)
+		if err != nil {
+			return err
+		}
+		wf, err := s.Workflow()
+		if err != nil {
+			return err
+		}
+		c := models.NewChangelog(version, date, project.DetectGitHubRepo(root), tasks, wf.Types)
+
+		if asJSON {
+			return printJSON(c)
+		}
+
+		tmpl := ""
+		if templatePath != "" {
+			data, err := os.ReadFile(templatePath)
+			if err != nil {
+				return fmt.Errorf("reading template: %w", err)
+			}
+			tmpl = string(data)
+		}
+		section, err := c.Render(tmpl)
+		if err != nil {
+			return err
+		}
+
+		if prepend == "" {
+			fmt.Print(section)
+			return nil
+		}
+		if len(tasks) == 0 {
+			fmt.Println("No finished tasks; nothing to add to the changelog.")
+			return nil
+		}
+		if !filepath.IsAbs(prepend) {
+			prepend = filepath.Join(root, prepend)
+		}
+		if err := project.PrependChangelog(prepend, section); err != nil {
+			return err
+		}
+		fmt.Printf("Added %d task(s) to %s\n", len(tasks), prepend)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.Flags().StringP("milestone", "m", "", "Tasks in this milestone")
+	changelogCmd.Flags().String("since", "", "Tasks finished after this date (YYYY-MM-DD) or git tag")
+	changelogCmd.Flags().String("version", "", "Version in the heading (default: the milestone, else Unreleased)")
+	changelogCmd.Flags().String("date", "", "Release date in the heading (default: when the milestone closed, else today)")
+	changelogCmd.Flags().String("template", "", "Go text/template file to render with instead of the default")
+	changelogCmd.Flags().String("prepend", "", "Prepend to this changelog file instead of printing (default "+project.ChangelogFile+")")
+	changelogCmd.Flags().Lookup("prepend").NoOptDefVal = project.ChangelogFile
+	changelogCmd.Flags().Bool("json", false, "Output the changelog data as JSON")
+}
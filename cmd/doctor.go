@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check .ghist/ for broken, mismatched or dangling records",
+	Long:  "Walks tasks/, events/, opportunities/ and settings.json and reports malformed JSON, files whose name disagrees with the ID inside, duplicate IDs and refs, links to tasks that do not exist, statuses the workflow does not know, and a missing current_context.json. --fix repairs what can be repaired without guessing; the rest is reported. Exits non-zero while problems remain, so it can run in CI.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fix, _ := cmd.Flags().GetBool("fix")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		// The store will not open over a settings.json that does not parse,
+		// so report that on its own before anything else.
+		root, err := findRoot()
+		if err != nil {
+			return err
+		}
+		if p := store.CheckSettings(project.GhistDirPath(root)); p != nil {
+			report := &store.DoctorReport{Problems: []store.Problem{*p}}
+			return printDoctorReport(cmd, report, fix, asJSON)
+		}
+
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		report, err := s.Doctor(fix)
+		if err != nil {
+			return err
+		}
+
+		_, statErr := os.Stat(project.ContextPath(root))
+		contextMissing := os.IsNotExist(statErr)
+		if fix && (contextMissing || len(report.Problems) > 0) {
+			if err := project.UpdateContext(root, s); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+			} else if contextMissing {
+				report.Add(store.ProblemMissingContext, "current_context.json", "current_context.json is missing; regenerated", true)
+				contextMissing = false
+			}
+		}
+		if contextMissing {
+			report.Add(store.ProblemMissingContext, "current_context.json", "current_context.json is missing; run 'ghist refresh' or 'ghist doctor --fix'", false)
+		}
+		return printDoctorReport(cmd, report, fix, asJSON)
+	},
+}
+
+// printDoctorReport prints report and returns an error, so the command exits
+// non-zero, while problems remain.
+func printDoctorReport(cmd *cobra.Command, report *store.DoctorReport, fix, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else if len(report.Problems) == 0 {
+		fmt.Println("No problems found.")
+	} else {
+		for _, p := range report.Problems {
+			mark := "✗"
+			if p.Fixed {
+				mark = "✓"
+			}
+			fmt.Printf("%s %-18s %s: %s\n", mark, p.Kind, p.Path, p.Message)
+		}
+	}
+
+	if n := report.Unresolved(); n > 0 {
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		if !fix {
+			return fmt.Errorf("%d problem(s) found; run 'ghist doctor --fix' to repair what can be repaired", n)
+		}
+		return fmt.Errorf("%d problem(s) remain", n)
+	}
+	return nil
+}
+
+func init() {
+	doctorCmd.Flags().Bool("fix", false, "Repair what can be repaired safely")
+	doctorCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Maintain the event log",
+}
+
+var eventsCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Archive events older than the retention policy allows",
+	Long:  "Moves events older than event_retention.archive_after_days (90 by default) from .ghist/events/ into monthly archive files under .ghist/archive/events/. Task-linked events of the types in event_retention.keep_types (decision by default) stay in place. Archived events still show up in ghist status and task history.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		report, err := s.CompactEvents(dryRun)
+		if err != nil {
+			return err
+		}
+		return printArchiveReport(cmd, report, dryRun)
+	},
+}
+
+var eventsArchiveCmd = &cobra.Command{
+	Use:   "archive --before YYYY-MM-DD",
+	Short: "Archive events created before a date",
+	Long:  "Moves events created before the given date into monthly archive files under .ghist/archive/events/. Task-linked events the retention policy keeps stay in place unless --all is given.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		beforeStr, _ := cmd.Flags().GetString("before")
+		if beforeStr == "" {
+			return fmt.Errorf("--before is required")
+		}
+		before, err := time.ParseInLocation("2006-01-02", beforeStr, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --before date %q (want YYYY-MM-DD)", beforeStr)
+		}
+
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		all, _ := cmd.Flags().GetBool("all")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		report, err := s.ArchiveEvents(before, all, dryRun)
+		if err != nil {
+			return err
+		}
+		return printArchiveReport(cmd, report, dryRun)
+	},
+}
+
+func printArchiveReport(cmd *cobra.Command, report *store.ArchiveReport, dryRun bool) error {
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if report.Archived == 0 {
+		fmt.Println("No events to archive.")
+	} else {
+		verb := "Archived"
+		if dryRun {
+			verb = "Would archive"
+		}
+		fmt.Printf("%s %d event(s)\n", verb, report.Archived)
+		for _, f := range report.Files {
+			fmt.Printf("  .ghist/%s\n", f)
+		}
+	}
+	if report.Kept > 0 {
+		fmt.Printf("Kept %d task-linked event(s) by retention policy\n", report.Kept)
+	}
+	return nil
+}
+
+func init() {
+	eventsCompactCmd.Flags().Bool("dry-run", false, "Show what would be archived without writing")
+	eventsCompactCmd.Flags().Bool("json", false, "Output as JSON")
+
+	eventsArchiveCmd.Flags().String("before", "", "Archive events created before this date (YYYY-MM-DD)")
+	eventsArchiveCmd.Flags().Bool("all", false, "Also archive events the retention policy would keep")
+	eventsArchiveCmd.Flags().Bool("dry-run", false, "Show what would be archived without writing")
+	eventsArchiveCmd.Flags().Bool("json", false, "Output as JSON")
+
+	eventsCmd.AddCommand(eventsCompactCmd, eventsArchiveCmd)
+	rootCmd.AddCommand(eventsCmd)
+}
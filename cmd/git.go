@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Integrate ghist with git",
+}
+
+var gitInstallHooksCmd = &cobra.Command{
+	Use:   "install-hooks",
+	Short: "Link commits to the tasks their messages name",
+	Long: `Installs commit-msg and post-commit hooks in the project's git repository. A commit whose message names a task ("GHST-12", "Fixes GHST-12") gets a Ghist-Task or Ghist-Closes trailer for it, and once committed is linked to the task as its commit hash. A task named after "Fixes", "Closes" or "Resolves" is also moved to done, unless --no-close is given.
+
+The hooks run for every commit, whoever or whatever makes it. They never stop a commit: problems are only reported.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := findRoot()
+		if err != nil {
+			return err
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		noClose, _ := cmd.Flags().GetBool("no-close")
+
+		paths, err := project.InstallGitHooks(root, force, noClose)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			fmt.Printf("Installed %s\n", p)
+		}
+		return nil
+	},
+}
+
+var gitUninstallHooksCmd = &cobra.Command{
+	Use:   "uninstall-hooks",
+	Short: "Remove the hooks installed by install-hooks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := findRoot()
+		if err != nil {
+			return err
+		}
+
+		paths, err := project.UninstallGitHooks(root)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Println("No ghist git hooks installed.")
+			return nil
+		}
+		for _, p := range paths {
+			fmt.Printf("Removed %s\n", p)
+		}
+		return nil
+	},
+}
+
+// findRoot returns the root of the ghist project around the working
+// directory.
+func findRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting working directory: %w", err)
+	}
+	root, err := project.FindRoot(cwd)
+	if err != nil {
+		return "", fmt.Errorf("not a ghist project (run 'ghist init' first): %w", err)
+	}
+	return root, nil
+}
+
+func init() {
+	gitInstallHooksCmd.Flags().Bool("force", false, "Replace existing hooks, keeping them as <hook>.pre-ghist")
+	gitInstallHooksCmd.Flags().Bool("no-close", false, "Link commits without moving the tasks they fix to done")
+	gitCmd.AddCommand(gitInstallHooksCmd)
+	gitCmd.AddCommand(gitUninstallHooksCmd)
+	rootCmd.AddCommand(gitCmd)
+}
@@ -7,12 +7,15 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
 	"github.com/spf13/cobra"
 )
 
 var hookCmd = &cobra.Command{
 	Use:    "hook",
-	Short:  "Internal hook handlers (used by agent integrations)",
+	Short:  "Internal hook handlers (used by agent integrations and git hooks)",
 	Hidden: true,
 }
 
@@ -50,6 +53,19 @@ var postToolUseCmd = &cobra.Command{
 			return nil
 		}
 
+		// With ghist's git hooks installed, a commit that names its task has
+		// been linked already.
+		if root, s, err := openStore(); err == nil {
+			defer s.Close()
+			if project.GitHooksInstalled(root) {
+				if _, msg, err := project.LastCommit(root); err == nil {
+					if refs, _ := s.ParseCommitRefs(msg); len(refs) > 0 {
+						return nil
+					}
+				}
+			}
+		}
+
 		msg := fmt.Sprintf(
 			"ghist: commit %s was just made. Check both in-progress and recently completed tasks — run `ghist task list --status in_progress` and `ghist task list --status done` to see candidates. Link this commit to any task that was being worked on or just closed with `ghist task update <id> --commit-hash %s`. If the task is in_progress, also move it to done at the same time: `ghist task update <id> --status done --commit-hash %s`. If no tasks clearly match, skip it.",
 			hash, hash, hash,
@@ -66,7 +82,127 @@ var postToolUseCmd = &cobra.Command{
 	},
 }
 
+var commitMsgCmd = &cobra.Command{
+	Use:   "commit-msg [file]",
+	Short: "Handle git's commit-msg hook: add a trailer for each task the message names",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// A ghist problem never stops a commit: warn and exit cleanly.
+		root, s, err := openStore()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+		defer s.Close()
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return nil
+		}
+		refs, err := s.ParseCommitRefs(string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+
+		var trailers []string
+		for _, r := range refs {
+			t, err := s.GetTask(r.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ghist: %s is not a task; the commit will not be linked to it\n", r.Ref)
+				continue
+			}
+			key := models.TrailerTask
+			if r.Closes {
+				key = models.TrailerCloses
+			}
+			trailers = append(trailers, key+": "+t.RefID)
+		}
+		if err := project.AddCommitTrailers(root, args[0], trailers); err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+		}
+		return nil
+	},
+}
+
+var postCommitCmd = &cobra.Command{
+	Use:   "post-commit",
+	Short: "Handle git's post-commit hook: link the commit to the tasks it names",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+		defer s.Close()
+
+		noClose, _ := cmd.Flags().GetBool("no-close")
+		hash, msg, err := project.LastCommit(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+		refs, err := s.ParseCommitRefs(msg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+		wf, err := s.Workflow()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ghist: %v\n", err)
+			return nil
+		}
+
+		changed := false
+		for _, r := range refs {
+			t, err := s.GetTask(r.ID)
+			if err != nil {
+				continue // commit-msg has warned about it
+			}
+			u := store.TaskUpdate{}
+			if t.CommitHash != hash {
+				u.CommitHash = &hash
+			}
+			if r.Closes && !noClose && !wf.IsDone(t.Status) {
+				done := wf.DoneStatus()
+				u.Status = &done
+			}
+			if u.CommitHash == nil && u.Status == nil {
+				continue
+			}
+
+			_, err = s.UpdateTask(t.ID, u)
+			if err != nil && u.Status != nil && u.CommitHash != nil {
+				// The workflow may not allow finishing the task from its
+				// current status; link the commit anyway.
+				fmt.Fprintf(os.Stderr, "ghist: not moving %s to %s: %v\n", t.RefID, *u.Status, err)
+				u.Status = nil
+				_, err = s.UpdateTask(t.ID, u)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ghist: linking %s: %v\n", t.RefID, err)
+				continue
+			}
+			changed = true
+			if u.Status != nil {
+				fmt.Printf("ghist: linked %s to %s and moved it to %s\n", hash[:min(7, len(hash))], t.RefID, *u.Status)
+			} else {
+				fmt.Printf("ghist: linked %s to %s\n", hash[:min(7, len(hash))], t.RefID)
+			}
+		}
+		if changed {
+			if err := project.UpdateContext(root, s); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+			}
+		}
+		return nil
+	},
+}
+
 func init() {
+	postCommitCmd.Flags().Bool("no-close", false, "Link commits without moving tasks they fix to done")
+	hookCmd.AddCommand(commitMsgCmd)
+	hookCmd.AddCommand(postCommitCmd)
 	hookCmd.AddCommand(postToolUseCmd)
 	rootCmd.AddCommand(hookCmd)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/unnecessary-special-projects/ghist/internal/models"
 	"github.com/unnecessary-special-projects/ghist/internal/project"
 	"github.com/spf13/cobra"
 )
@@ -18,17 +19,60 @@ var initCmd = &cobra.Command{
 			return fmt.Errorf("getting working directory: %w", err)
 		}
 
+		prefix, _ := cmd.Flags().GetString("prefix")
+		if cmd.Flags().Changed("prefix") {
+			if _, err := models.NormalizeRefPrefix(prefix); err != nil {
+				return err
+			}
+		}
+
 		fmt.Println("Initializing ghist...")
 
 		if err := project.Init(cwd, os.Stdin); err != nil {
 			return err
 		}
 
+		if cmd.Flags().Changed("prefix") {
+			if err := setRefPrefix(prefix); err != nil {
+				return err
+			}
+		}
+
 		fmt.Println("ghist initialized successfully!")
 		return nil
 	},
 }
 
+// setRefPrefix switches the project to a new task ref prefix and reports how
+// many existing tasks were renamed.
+func setRefPrefix(prefix string) error {
+	root, s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	migrated, err := s.SetRefPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	current, err := s.RefPrefix()
+	if err != nil {
+		return err
+	}
+	if err := project.UpdateContext(root, s); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+	}
+
+	fmt.Printf("  Task refs now use %s-<id>", current)
+	if migrated > 0 {
+		fmt.Printf(" (renamed %d existing task(s); old refs still resolve)", migrated)
+	}
+	fmt.Println()
+	return nil
+}
+
 func init() {
+	initCmd.Flags().String("prefix", "", "Task ref prefix for this project, e.g. API (default GHST)")
 	rootCmd.AddCommand(initCmd)
 }
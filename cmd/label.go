@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var labelCmd = &cobra.Command{
+	Use:   "label",
+	Short: "Work with task labels",
+}
+
+var labelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List labels in use with their task counts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		labels, err := s.LabelCounts()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(labels, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(labels) == 0 {
+			fmt.Println("No labels found.")
+			return nil
+		}
+
+		output.PrintLabelTable(labels)
+		return nil
+	},
+}
+
+func init() {
+	labelListCmd.Flags().Bool("json", false, "Output as JSON")
+	labelCmd.AddCommand(labelListCmd)
+	rootCmd.AddCommand(labelCmd)
+}
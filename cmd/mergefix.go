@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var mergeFixCmd = &cobra.Command{
+	Use:   "merge-fix",
+	Short: "Renumber records that share an ID after a git merge",
+	Long:  "When two branches each create tasks and are merged, their tasks can end up with the same display ID. merge-fix keeps the oldest record at each ID, moves the others to fresh IDs, and re-points their linked events and the blocking links they confirm. Parent links to a shared ID cannot be attributed and are listed for you to check.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		report, err := s.MergeFix(dryRun)
+		if err != nil {
+			return err
+		}
+
+		if !dryRun && len(report.Renumbered) > 0 {
+			if err := project.UpdateContext(root, s); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+			}
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(report.Renumbered) == 0 {
+			fmt.Println("No duplicate IDs found.")
+			return nil
+		}
+
+		verb := "Renumbered"
+		if dryRun {
+			verb = "Would renumber"
+		}
+		for _, r := range report.Renumbered {
+			fmt.Printf("%s %s #%d → #%d\n", verb, r.Kind, r.OldID, r.NewID)
+		}
+		if report.EventsRelinked > 0 {
+			verb = "Relinked"
+			if dryRun {
+				verb = "Would relink"
+			}
+			fmt.Printf("%s %d event(s) to renumbered tasks\n", verb, report.EventsRelinked)
+		}
+		if report.LinksRelinked > 0 {
+			verb = "Relinked"
+			if dryRun {
+				verb = "Would relink"
+			}
+			fmt.Printf("%s %d task link(s) to renumbered tasks\n", verb, report.LinksRelinked)
+		}
+		for _, a := range report.Ambiguous {
+			ids := make([]string, len(a.Candidates))
+			for i, id := range a.Candidates {
+				ids[i] = fmt.Sprintf("#%d", id)
+			}
+			fmt.Printf("Check task #%d: %s #%d could mean %s; left at #%d\n", a.TaskID, a.Field, a.ID, strings.Join(ids, " or "), a.ID)
+		}
+		return nil
+	},
+}
+
+func init() {
+	mergeFixCmd.Flags().Bool("dry-run", false, "Show what would change without writing")
+	mergeFixCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(mergeFixCmd)
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var milestoneCmd = &cobra.Command{
+	Use:   "milestone",
+	Short: "Manage milestones",
+	Long:  "Tasks name their milestone with --milestone. A milestone record adds a description, start and target dates and an open/closed state; a name that tasks use without a record is an open milestone with no dates.",
+}
+
+// --- milestone add ---
+
+var milestoneAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Record a milestone",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		description, _ := cmd.Flags().GetString("description")
+		start, _ := cmd.Flags().GetString("start")
+		target, _ := cmd.Flags().GetString("target")
+
+		m, err := s.CreateMilestone(store.MilestoneInput{
+			Name:        args[0],
+			Description: description,
+			StartDate:   start,
+			TargetDate:  target,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created milestone %s\n", m.Name)
+		return nil
+	},
+}
+
+// --- milestone list ---
+
+var milestoneListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List milestones with their progress",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		state, _ := cmd.Flags().GetString("state")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if state != "" && state != models.MilestoneOpen && state != models.MilestoneClosed {
+			return fmt.Errorf("--state must be %s or %s", models.MilestoneOpen, models.MilestoneClosed)
+		}
+
+		all, err := s.MilestoneInfo()
+		if err != nil {
+			return err
+		}
+		milestones := []models.MilestoneInfo{}
+		for _, m := range all {
+			if state == "" || m.State == state {
+				milestones = append(milestones, m)
+			}
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(milestones, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(milestones) == 0 {
+			fmt.Println("No milestones found.")
+			return nil
+		}
+
+		output.PrintMilestoneTable(milestones)
+		return nil
+	},
+}
+
+// --- milestone show ---
+
+// milestoneDetail is 'milestone show --json': the milestone's progress,
+// record fields and the tasks that name it.
+type milestoneDetail struct {
+	models.MilestoneInfo
+	Description string        `json:"description,omitempty"`
+	ClosedAt    *time.Time    `json:"closed_at,omitempty"`
+	Tasks       []models.Task `json:"tasks"`
+}
+
+var milestoneShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show a milestone and its tasks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+		name := args[0]
+
+		infos, err := s.MilestoneInfo()
+		if err != nil {
+			return err
+		}
+		var detail *milestoneDetail
+		for _, info := range infos {
+			if info.Name == name {
+				detail = &milestoneDetail{MilestoneInfo: info}
+			}
+		}
+		if detail == nil {
+			return fmt.Errorf("milestone %q not found", name)
+		}
+		if m, err := s.GetMilestone(name); err == nil {
+			detail.Description = m.Description
+			detail.ClosedAt = m.ClosedAt
+		}
+		detail.Tasks, err = s.ListTasks("", name, "", "")
+		if err != nil {
+			return err
+		}
+		if detail.Tasks == nil {
+			detail.Tasks = []models.Task{}
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(detail, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		output.PrintMilestoneDetail(detail.MilestoneInfo, detail.Description, detail.ClosedAt, detail.Tasks)
+		return nil
+	},
+}
+
+// --- milestone update ---
+
+var milestoneUpdateCmd = &cobra.Command{
+	Use:   "update [name]",
+	Short: "Change a milestone's description or dates",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		u := store.MilestoneUpdate{}
+		if cmd.Flags().Changed("description") {
+			v, _ := cmd.Flags().GetString("description")
+			u.Description = &v
+		}
+		if cmd.Flags().Changed("start") {
+			v, _ := cmd.Flags().GetString("start")
+			u.StartDate = &v
+		}
+		if cmd.Flags().Changed("target") {
+			v, _ := cmd.Flags().GetString("target")
+			u.TargetDate = &v
+		}
+
+		m, err := s.UpdateMilestone(args[0], u)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Updated milestone %s\n", m.Name)
+		return nil
+	},
+}
+
+// --- milestone close / reopen ---
+
+var milestoneCloseCmd = &cobra.Command{
+	Use:   "close [name]",
+	Short: "Close a milestone",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMilestoneState(args[0], models.MilestoneClosed)
+	},
+}
+
+var milestoneReopenCmd = &cobra.Command{
+	Use:   "reopen [name]",
+	Short: "Reopen a closed milestone",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMilestoneState(args[0], models.MilestoneOpen)
+	},
+}
+
+func setMilestoneState(name, state string) error {
+	_, s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	m, err := s.SetMilestoneState(name, state, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Milestone %s is %s\n", m.Name, m.State)
+	return nil
+}
+
+// --- milestone rename / merge ---
+
+var milestoneRenameCmd = &cobra.Command{
+	Use:   "rename [name] [new-name]",
+	Short: "Rename a milestone and every task in it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		n, err := s.RenameMilestone(args[0], args[1], "")
+		if err != nil {
+			return err
+		}
+		if err := project.UpdateContext(root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+		}
+
+		fmt.Printf("Renamed milestone %s to %s (%d task(s) updated)\n", args[0], args[1], n)
+		return nil
+	},
+}
+
+var milestoneMergeCmd = &cobra.Command{
+	Use:   "merge [name] [into]",
+	Short: "Move every task in a milestone into another and remove it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		n, err := s.MergeMilestone(args[0], args[1], "")
+		if err != nil {
+			return err
+		}
+		if err := project.UpdateContext(root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+		}
+
+		fmt.Printf("Merged milestone %s into %s (%d task(s) moved)\n", args[0], args[1], n)
+		return nil
+	},
+}
+
+// --- milestone delete ---
+
+var milestoneDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a milestone that has no tasks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		if err := s.DeleteMilestone(args[0], ""); err != nil {
+			return err
+		}
+
+		fmt.Printf("Deleted milestone %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(milestoneCmd)
+
+	milestoneAddCmd.Flags().StringP("description", "d", "", "What the milestone delivers")
+	milestoneAddCmd.Flags().String("start", "", "Start date (YYYY-MM-DD)")
+	milestoneAddCmd.Flags().String("target", "", "Target date (YYYY-MM-DD)")
+	milestoneCmd.AddCommand(milestoneAddCmd)
+
+	milestoneListCmd.Flags().String("state", "", "Only list open or closed milestones")
+	milestoneListCmd.Flags().Bool("json", false, "Output as JSON")
+	milestoneCmd.AddCommand(milestoneListCmd)
+
+	milestoneShowCmd.Flags().Bool("json", false, "Output as JSON")
+	milestoneCmd.AddCommand(milestoneShowCmd)
+
+	milestoneUpdateCmd.Flags().StringP("description", "d", "", "New description")
+	milestoneUpdateCmd.Flags().String("start", "", "New start date (YYYY-MM-DD, \"\" to clear)")
+	milestoneUpdateCmd.Flags().String("target", "", "New target date (YYYY-MM-DD, \"\" to clear)")
+	milestoneCmd.AddCommand(milestoneUpdateCmd)
+
+	milestoneCmd.AddCommand(milestoneCloseCmd)
+	milestoneCmd.AddCommand(milestoneReopenCmd)
+	milestoneCmd.AddCommand(milestoneRenameCmd)
+	milestoneCmd.AddCommand(milestoneMergeCmd)
+	milestoneCmd.AddCommand(milestoneDeleteCmd)
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var opportunityCmd = &cobra.Command{
+	Use:     "opportunity",
+	Aliases: []string{"opp", "lead"},
+	Short:   "Manage opportunities (leads, requests and requirements)",
+}
+
+// --- opportunity add ---
+
+var opportunityAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Record a new opportunity",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		notes, _ := cmd.Flags().GetString("notes")
+
+		opp, err := s.CreateOpportunity(args[0], notes, "")
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created opportunity #%d: %s\n", opp.ID, opp.Name)
+		return nil
+	},
+}
+
+// --- opportunity list ---
+
+var opportunityListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List opportunities",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		opps, err := s.ListOpportunities()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(opps, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(opps) == 0 {
+			fmt.Println("No opportunities found.")
+			return nil
+		}
+
+		output.PrintOpportunityTable(opps)
+		return nil
+	},
+}
+
+// --- opportunity show ---
+
+var opportunityShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show opportunity details",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := parseOpportunityID(args[0])
+		if err != nil {
+			return err
+		}
+
+		opp, err := s.GetOpportunity(id)
+		if err != nil {
+			return err
+		}
+
+		output.PrintOpportunityDetail(opp)
+		return nil
+	},
+}
+
+// --- opportunity update ---
+
+var opportunityUpdateCmd = &cobra.Command{
+	Use:   "update [id]",
+	Short: "Update an opportunity",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := parseOpportunityID(args[0])
+		if err != nil {
+			return err
+		}
+
+		u := store.OpportunityUpdate{}
+
+		if cmd.Flags().Changed("name") {
+			v, _ := cmd.Flags().GetString("name")
+			u.Name = &v
+		}
+		if cmd.Flags().Changed("notes") {
+			v, _ := cmd.Flags().GetString("notes")
+			u.Notes = &v
+		}
+		if cmd.Flags().Changed("note") {
+			v, _ := cmd.Flags().GetString("note")
+			existing, err := s.GetOpportunity(id)
+			if err != nil {
+				return err
+			}
+			notes := existing.Notes
+			if u.Notes != nil {
+				notes = *u.Notes
+			}
+			if notes != "" && !strings.HasSuffix(notes, "\n") {
+				notes += "\n"
+			}
+			notes += v
+			u.Notes = &notes
+		}
+
+		opp, err := s.UpdateOpportunity(id, u)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Updated opportunity #%d: %s\n", opp.ID, opp.Name)
+		return nil
+	},
+}
+
+// --- opportunity delete ---
+
+var opportunityDeleteCmd = &cobra.Command{
+	Use:   "delete [id]",
+	Short: "Delete an opportunity",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := parseOpportunityID(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := s.DeleteOpportunity(id, ""); err != nil {
+			return err
+		}
+
+		fmt.Printf("Deleted opportunity #%d\n", id)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(opportunityCmd)
+
+	opportunityAddCmd.Flags().StringP("notes", "n", "", "Notes (requirements, pain points, contacts)")
+	opportunityCmd.AddCommand(opportunityAddCmd)
+
+	opportunityListCmd.Flags().Bool("json", false, "Output as JSON")
+	opportunityCmd.AddCommand(opportunityListCmd)
+
+	opportunityCmd.AddCommand(opportunityShowCmd)
+
+	opportunityUpdateCmd.Flags().String("name", "", "New name")
+	opportunityUpdateCmd.Flags().StringP("notes", "n", "", "Replace notes")
+	opportunityUpdateCmd.Flags().String("note", "", "Append a line to the notes")
+	opportunityCmd.AddCommand(opportunityUpdateCmd)
+
+	opportunityCmd.AddCommand(opportunityDeleteCmd)
+}
+
+// parseOpportunityID accepts a bare ("3") or "#"-prefixed ("#3") opportunity ID.
+func parseOpportunityID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(raw), "#"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid opportunity id: %s", raw)
+	}
+	return id, nil
+}
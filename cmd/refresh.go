@@ -11,7 +11,7 @@ import (
 var refreshCmd = &cobra.Command{
 	Use:   "refresh",
 	Short: "Update ghist configuration after an upgrade",
-	Long:  "Re-runs database migrations, updates CLAUDE.md injection, and refreshes context. Use this after upgrading ghist to apply new changes.",
+	Long:  "Runs any pending schema migrations on .ghist/ (backing up what they change under .ghist/backups/) and reports them, updates CLAUDE.md injection, and refreshes context. Use this after upgrading ghist to apply new changes.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report how fast work moves",
+	Long:  "Burndown, throughput, lead time and cycle time, computed over leaf tasks (archived ones included) from when each task was created, first started and finished. ghist records those times on every status change; for older tasks they are recovered from the task.status_changed events.",
+}
+
+// reportFormat reads --json and --csv, which are mutually exclusive.
+func reportFormat(cmd *cobra.Command) (asJSON, asCSV bool, err error) {
+	asJSON, _ = cmd.Flags().GetBool("json")
+	asCSV, _ = cmd.Flags().GetBool("csv")
+	if asJSON && asCSV {
+		return false, false, fmt.Errorf("--json and --csv cannot be used together")
+	}
+	return asJSON, asCSV, nil
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func printCSV(rows [][]string) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("writing CSV: %w", err)
+	}
+	return nil
+}
+
+// --- report burndown ---
+
+var reportBurndownCmd = &cobra.Command{
+	Use:   "burndown",
+	Short: "Remaining work per day in a milestone",
+	Long:  "Shows, for each day from the milestone's start date (or its first task) to today, how many tasks it had, how many were done and how many remained, with an ideal line to the target date if it has one. Without --milestone, every open milestone is shown.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, asCSV, err := reportFormat(cmd)
+		if err != nil {
+			return err
+		}
+		names := []string{}
+		if m, _ := cmd.Flags().GetString("milestone"); m != "" {
+			names = append(names, m)
+		} else {
+			infos, err := s.MilestoneInfo()
+			if err != nil {
+				return err
+			}
+			for _, m := range infos {
+				if m.State == models.MilestoneOpen {
+					names = append(names, m.Name)
+				}
+			}
+		}
+
+		burndowns := []*models.Burndown{}
+		for _, name := range names {
+			b, err := s.Burndown(name)
+			if err != nil {
+				return err
+			}
+			burndowns = append(burndowns, b)
+		}
+
+		switch {
+		case asJSON:
+			return printJSON(burndowns)
+		case asCSV:
+			var rows [][]string
+			for i, b := range burndowns {
+				r := b.CSV()
+				if i > 0 {
+					r = r[1:]
+				}
+				rows = append(rows, r...)
+			}
+			return printCSV(rows)
+		}
+		if len(burndowns) == 0 {
+			fmt.Println("No open milestones.")
+			return nil
+		}
+		for i, b := range burndowns {
+			if i > 0 {
+				fmt.Println()
+			}
+			output.PrintBurndown(b)
+		}
+		return nil
+	},
+}
+
+// --- report throughput ---
+
+var reportThroughputCmd = &cobra.Command{
+	Use:   "throughput",
+	Short: "Tasks finished per week",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, asCSV, err := reportFormat(cmd)
+		if err != nil {
+			return err
+		}
+		weeks, _ := cmd.Flags().GetInt("weeks")
+		milestone, _ := cmd.Flags().GetString("milestone")
+
+		tp, err := s.Throughput(weeks, milestone)
+		if err != nil {
+			return err
+		}
+		switch {
+		case asJSON:
+			return printJSON(tp)
+		case asCSV:
+			return printCSV(models.ThroughputCSV(tp))
+		}
+		output.PrintThroughput(tp)
+		return nil
+	},
+}
+
+// --- report lead-time / cycle-time ---
+
+var reportLeadTimeCmd = &cobra.Command{
+	Use:   "lead-time",
+	Short: "Time from creating a task to finishing it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDurationReport(cmd, "Lead time", "created → done", store.Store.LeadTime)
+	},
+}
+
+var reportCycleTimeCmd = &cobra.Command{
+	Use:   "cycle-time",
+	Short: "Time from starting work on a task to finishing it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDurationReport(cmd, "Cycle time", "in progress → done", store.Store.CycleTime)
+	},
+}
+
+func runDurationReport(cmd *cobra.Command, title, what string, get func(store.Store, string) (*models.DurationReport, error)) error {
+	_, s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	asJSON, asCSV, err := reportFormat(cmd)
+	if err != nil {
+		return err
+	}
+	milestone, _ := cmd.Flags().GetString("milestone")
+
+	r, err := get(s, milestone)
+	if err != nil {
+		return err
+	}
+	switch {
+	case asJSON:
+		return printJSON(r)
+	case asCSV:
+		return printCSV(r.CSV())
+	}
+	output.PrintDurationReport(title, what, r)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	for _, c := range []*cobra.Command{reportBurndownCmd, reportThroughputCmd, reportLeadTimeCmd, reportCycleTimeCmd} {
+		c.Flags().StringP("milestone", "m", "", "Only count tasks in this milestone")
+		c.Flags().Bool("json", false, "Output as JSON")
+		c.Flags().Bool("csv", false, "Output as CSV")
+		reportCmd.AddCommand(c)
+	}
+	reportThroughputCmd.Flags().Int("weeks", 8, "Number of weeks to show, ending with this one")
+}
@@ -14,6 +14,18 @@ var rootCmd = &cobra.Command{
 	Short:   "Project memory layer for AI agents",
 	Long:    "Ghist maintains persistent project state so AI agents never lose context between sessions.",
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if cmd.Annotations[noJournal] == "" {
+			operationName = commandLine()
+		}
+	},
+}
+
+// actorFlag is the global --as flag: who to record as making changes.
+var actorFlag string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&actorFlag, "as", "", "Record changes as this person or agent (default $GHIST_ACTOR, else your git identity)")
 }
 
 func Execute() {
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [terms...]",
+	Short: "Search task titles, descriptions, plans and logged events",
+	Long:  "Full-text search over tasks and logged events (decisions, notes). Every term must match; terms of three or more letters also match longer words they start. Results are ranked, with title matches above matches in descriptions, plans and event messages.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		results, err := s.Search(strings.Join(args, " "), limit)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No matches found.")
+			return nil
+		}
+
+		prefix, err := s.RefPrefix()
+		if err != nil {
+			return err
+		}
+		output.PrintSearchResults(results, prefix)
+		return nil
+	},
+}
+
+func init() {
+	searchCmd.Flags().IntP("limit", "n", 20, "Maximum number of results")
+	searchCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(searchCmd)
+}
@@ -24,6 +24,8 @@ func SetWebFS(wfs embed.FS) {
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start local web server with Kanban board UI",
+	// Changes made through the API are not undoable from the CLI.
+	Annotations: map[string]string{noJournal: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, s, err := openStore()
 		if err != nil {
@@ -45,7 +47,7 @@ var serveCmd = &cobra.Command{
 		}
 
 		repoURL := project.DetectGitHubRepo(root)
-		srv := api.NewServer(s, project.GhistDirPath(root), frontendFS, dev, repoURL)
+		srv := api.NewServer(s, frontendFS, dev, repoURL)
 		addr := fmt.Sprintf(":%d", port)
 
 		fmt.Printf("ghist server starting on http://localhost:%d\n", port)
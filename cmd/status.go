@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/unnecessary-special-projects/ghist/internal/models"
 	"github.com/spf13/cobra"
@@ -35,6 +36,11 @@ var statusCmd = &cobra.Command{
 			return err
 		}
 
+		wf, err := s.Workflow()
+		if err != nil {
+			return err
+		}
+
 		total := 0
 		for _, c := range counts {
 			total += c
@@ -63,7 +69,7 @@ var statusCmd = &cobra.Command{
 		if total > 0 {
 			fmt.Printf(" (")
 			first := true
-			for _, status := range []string{"todo", "in_progress", "done", "blocked"} {
+			for _, status := range statusOrder(wf, counts) {
 				if c, ok := counts[status]; ok && c > 0 {
 					if !first {
 						fmt.Printf(", ")
@@ -76,9 +82,19 @@ var statusCmd = &cobra.Command{
 		}
 		fmt.Println()
 
-		if len(milestones) > 0 {
+		var open, overdue []models.MilestoneInfo
+		for _, m := range milestones {
+			if m.State == models.MilestoneClosed {
+				continue
+			}
+			open = append(open, m)
+			if m.Overdue {
+				overdue = append(overdue, m)
+			}
+		}
+		if len(open) > 0 {
 			fmt.Printf("\nMilestones:\n")
-			for _, m := range milestones {
+			for _, m := range open {
 				pct := 0
 				if m.Total > 0 {
 					pct = (m.Done * 100) / m.Total
@@ -86,6 +102,12 @@ var statusCmd = &cobra.Command{
 				fmt.Printf("  %-20s %d/%d (%d%%)\n", m.Name, m.Done, m.Total, pct)
 			}
 		}
+		if len(overdue) > 0 {
+			fmt.Printf("\nOverdue Milestones:\n")
+			for _, m := range overdue {
+				fmt.Printf("  %-20s due %s, %d/%d done\n", m.Name, m.TargetDate, m.Done, m.Total)
+			}
+		}
 
 		if len(events) > 0 {
 			fmt.Printf("\nRecent Events:\n")
@@ -94,6 +116,9 @@ var statusCmd = &cobra.Command{
 				if e.TaskID != nil {
 					taskInfo = fmt.Sprintf(" (task #%d)", *e.TaskID)
 				}
+				if e.Actor != "" {
+					taskInfo += " by " + e.Actor
+				}
 				fmt.Printf("  [%s] %s%s\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Message, taskInfo)
 			}
 		}
@@ -106,3 +131,18 @@ func init() {
 	statusCmd.Flags().Bool("json", false, "Output as JSON")
 	rootCmd.AddCommand(statusCmd)
 }
+
+// statusOrder lists the workflow's statuses in board order, followed by any
+// statuses in counts that the workflow doesn't define (so stray values left
+// by hand edits stay visible).
+func statusOrder(wf models.Workflow, counts map[string]int) []string {
+	order := wf.StatusNames()
+	var extra []string
+	for status := range counts {
+		if !wf.HasStatus(status) {
+			extra = append(extra, status)
+		}
+	}
+	sort.Strings(extra)
+	return append(order, extra...)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage where ghist keeps its records",
+}
+
+var storeConvertCmd = &cobra.Command{
+	Use:         "convert",
+	Short:       "Move the records to another storage backend",
+	Long:        "Copies every task, event, opportunity, plan revision, trash entry and event archive into the chosen backend and switches settings.json over to it. 'json' keeps one file per record under .ghist/, to be committed and merged with git; 'sqlite' keeps them in a single .ghist/ghist.db, which git cannot merge. The old copy is moved under .ghist/backups/.",
+	Annotations: map[string]string{noJournal: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if to == "" {
+			return fmt.Errorf("--to is required: json or sqlite")
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getting working directory: %w", err)
+		}
+		root, err := project.FindRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not a ghist project (run 'ghist init' first): %w", err)
+		}
+
+		report, err := store.Convert(project.GhistDirPath(root), to)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Printf("Converted %d file(s) from %s to %s. The old copy is in .ghist/%s.\n", report.Files, report.From, report.To, report.Backup)
+		return nil
+	},
+}
+
+func init() {
+	storeConvertCmd.Flags().String("to", "", "Backend to convert to: json or sqlite")
+	storeConvertCmd.Flags().Bool("json", false, "Output as JSON")
+	storeCmd.AddCommand(storeConvertCmd)
+	rootCmd.AddCommand(storeCmd)
+}
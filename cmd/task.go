@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/unnecessary-special-projects/ghist/internal/models"
 	"github.com/unnecessary-special-projects/ghist/internal/output"
 	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/unnecessary-special-projects/ghist/internal/query"
 	"github.com/unnecessary-special-projects/ghist/internal/store"
+	"github.com/unnecessary-special-projects/ghist/internal/textdiff"
 	"github.com/spf13/cobra"
 )
 
@@ -37,6 +42,21 @@ var taskAddCmd = &cobra.Command{
 		priority, _ := cmd.Flags().GetString("priority")
 		taskType, _ := cmd.Flags().GetString("type")
 		legacyID, _ := cmd.Flags().GetString("legacy-id")
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		assignee, _ := cmd.Flags().GetString("assignee")
+		if assignee, err = resolveAssignee(root, assignee); err != nil {
+			return err
+		}
+
+		var parentID *int64
+		if cmd.Flags().Changed("parent") {
+			v, _ := cmd.Flags().GetString("parent")
+			id, err := s.ParseTaskID(v)
+			if err != nil {
+				return err
+			}
+			parentID = &id
+		}
 
 		task, err := s.CreateTask(store.CreateTaskInput{
 			Title:       args[0],
@@ -46,6 +66,9 @@ var taskAddCmd = &cobra.Command{
 			Priority:    priority,
 			Type:        taskType,
 			LegacyID:    legacyID,
+			Assignee:    assignee,
+			ParentID:    parentID,
+			Labels:      labels,
 		})
 		if err != nil {
 			return err
@@ -66,7 +89,7 @@ var taskListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List tasks",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, s, err := openStore()
+		root, s, err := openStore()
 		if err != nil {
 			return err
 		}
@@ -76,9 +99,49 @@ var taskListCmd = &cobra.Command{
 		milestone, _ := cmd.Flags().GetString("milestone")
 		priority, _ := cmd.Flags().GetString("priority")
 		taskType, _ := cmd.Flags().GetString("type")
+		assignee, _ := cmd.Flags().GetString("assignee")
+		if assignee, err = resolveAssignee(root, assignee); err != nil {
+			return err
+		}
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		labelMatch, _ := cmd.Flags().GetString("label-match")
+		expr, _ := cmd.Flags().GetString("query")
+		sortSpec, _ := cmd.Flags().GetString("sort")
+		limit, _ := cmd.Flags().GetInt("limit")
+		includeArchived, _ := cmd.Flags().GetBool("include-archived")
 		asJSON, _ := cmd.Flags().GetBool("json")
 
-		tasks, err := s.ListTasks(status, milestone, priority, taskType)
+		if labelMatch != "all" && labelMatch != "any" {
+			return fmt.Errorf("--label-match must be 'all' or 'any'")
+		}
+		q, err := query.Parse(expr)
+		if err != nil {
+			return err
+		}
+		order, err := query.ParseSort(sortSpec)
+		if err != nil {
+			return err
+		}
+		wf, err := s.Workflow()
+		if err != nil {
+			return err
+		}
+
+		tasks, err := s.FilterTasks(store.TaskFilter{
+			Status:          status,
+			Milestone:       milestone,
+			Priority:        priority,
+			Type:            taskType,
+			Assignee:        assignee,
+			Labels:          labels,
+			AnyLabel:        labelMatch == "any",
+			IncludeArchived: includeArchived,
+		})
+		if err != nil {
+			return err
+		}
+		env := query.Env{Workflow: wf, Now: time.Now(), Me: project.Identity(root, actorFlag)}
+		tasks, err = query.Apply(tasks, q, order, limit, env)
 		if err != nil {
 			return err
 		}
@@ -115,7 +178,7 @@ var taskShowCmd = &cobra.Command{
 		}
 		defer s.Close()
 
-		id, err := models.ParseTaskID(args[0])
+		id, err := s.ParseTaskID(args[0])
 		if err != nil {
 			return err
 		}
@@ -130,7 +193,12 @@ var taskShowCmd = &cobra.Command{
 			return err
 		}
 
-		output.PrintTaskDetail(task, events)
+		subtasks, err := s.ListSubtasks(id)
+		if err != nil {
+			return err
+		}
+
+		output.PrintTaskDetail(task, events, subtasks)
 		return nil
 	},
 }
@@ -148,7 +216,7 @@ var taskUpdateCmd = &cobra.Command{
 		}
 		defer s.Close()
 
-		id, err := models.ParseTaskID(args[0])
+		id, err := s.ParseTaskID(args[0])
 		if err != nil {
 			return err
 		}
@@ -191,6 +259,32 @@ var taskUpdateCmd = &cobra.Command{
 			v, _ := cmd.Flags().GetString("legacy-id")
 			u.LegacyID = &v
 		}
+		if cmd.Flags().Changed("parent") {
+			v, _ := cmd.Flags().GetString("parent")
+			var parentID int64
+			if v != "" && v != "none" {
+				if parentID, err = s.ParseTaskID(v); err != nil {
+					return err
+				}
+			}
+			u.ParentID = &parentID
+		}
+		u.AddLabels, _ = cmd.Flags().GetStringSlice("label")
+		u.RemoveLabels, _ = cmd.Flags().GetStringSlice("remove-label")
+		if cmd.Flags().Changed("assignee") {
+			v, _ := cmd.Flags().GetString("assignee")
+			if v, err = resolveAssignee(root, v); err != nil {
+				return err
+			}
+			if strings.EqualFold(v, store.NoAssignee) {
+				v = ""
+			}
+			u.Assignee = &v
+		}
+		if cmd.Flags().Changed("if-revision") {
+			v, _ := cmd.Flags().GetInt64("if-revision")
+			u.IfRevision = &v
+		}
 		planStdin, _ := cmd.Flags().GetBool("plan-stdin")
 		if planStdin {
 			data, err := io.ReadAll(os.Stdin)
@@ -210,7 +304,7 @@ var taskUpdateCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
 		}
 
-		fmt.Printf("Updated task %s: %s [%s]\n", task.RefID, task.Title, task.Status)
+		fmt.Printf("Updated task %s: %s [%s] (revision %d)\n", task.RefID, task.Title, task.Status, task.Revision)
 		return nil
 	},
 }
@@ -219,7 +313,7 @@ var taskUpdateCmd = &cobra.Command{
 
 var taskDeleteCmd = &cobra.Command{
 	Use:   "delete [id]",
-	Short: "Delete a task",
+	Short: "Move a task to the trash",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, s, err := openStore()
@@ -228,7 +322,7 @@ var taskDeleteCmd = &cobra.Command{
 		}
 		defer s.Close()
 
-		id, err := models.ParseTaskID(args[0])
+		id, err := s.ParseTaskID(args[0])
 		if err != nil {
 			return err
 		}
@@ -241,7 +335,390 @@ var taskDeleteCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
 		}
 
-		fmt.Printf("Deleted task #%d\n", id)
+		fmt.Printf("Moved task #%d to the trash (ghist trash restore %d)\n", id, id)
+		return nil
+	},
+}
+
+// --- task history ---
+
+var taskHistoryCmd = &cobra.Command{
+	Use:   "history [id]",
+	Short: "Show the timeline of changes to a task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := s.ParseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		task, err := s.GetTask(id)
+		if err != nil {
+			return err
+		}
+
+		history, err := s.TaskHistory(id)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(history, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		output.PrintTaskHistory(task, history)
+		return nil
+	},
+}
+
+// --- task plan-history / plan-diff / plan-restore ---
+
+var taskPlanHistoryCmd = &cobra.Command{
+	Use:   "plan-history [id]",
+	Short: "List saved revisions of a task's plan",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := s.ParseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+		showRev, _ := cmd.Flags().GetInt("rev")
+
+		if showRev > 0 {
+			rev, err := s.GetPlanRevision(id, showRev)
+			if err != nil {
+				return err
+			}
+			fmt.Print(rev.Plan)
+			if !strings.HasSuffix(rev.Plan, "\n") {
+				fmt.Println()
+			}
+			return nil
+		}
+
+		revs, err := s.ListPlanRevisions(id)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(revs, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(revs) == 0 {
+			fmt.Println("No plan revisions saved.")
+			return nil
+		}
+
+		output.PrintPlanRevisions(revs)
+		return nil
+	},
+}
+
+var taskPlanDiffCmd = &cobra.Command{
+	Use:   "plan-diff [id] [rev1] [rev2]",
+	Short: "Show a unified diff between two plan revisions",
+	Long:  "Shows a unified diff between two plan revisions. If rev2 is omitted the diff is against the current plan.",
+	Args:  cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := s.ParseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+
+		rev1, err := parseRev(args[1])
+		if err != nil {
+			return err
+		}
+		from, err := s.GetPlanRevision(id, rev1)
+		if err != nil {
+			return err
+		}
+
+		toName, toPlan := "current", ""
+		if len(args) == 3 {
+			rev2, err := parseRev(args[2])
+			if err != nil {
+				return err
+			}
+			to, err := s.GetPlanRevision(id, rev2)
+			if err != nil {
+				return err
+			}
+			toName, toPlan = fmt.Sprintf("rev %d", rev2), to.Plan
+		} else {
+			task, err := s.GetTask(id)
+			if err != nil {
+				return err
+			}
+			toPlan = task.Plan
+		}
+
+		diff := textdiff.Unified(fmt.Sprintf("rev %d", rev1), toName, from.Plan, toPlan, 3)
+		if diff == "" {
+			fmt.Println("Plans are identical.")
+			return nil
+		}
+		fmt.Print(diff)
+		return nil
+	},
+}
+
+var taskPlanRestoreCmd = &cobra.Command{
+	Use:   "plan-restore [id] [rev]",
+	Short: "Restore an earlier plan revision as the current plan",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := s.ParseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+		rev, err := parseRev(args[1])
+		if err != nil {
+			return err
+		}
+
+		task, err := s.RestorePlanRevision(id, rev, "")
+		if err != nil {
+			return err
+		}
+
+		if err := project.UpdateContext(root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+		}
+
+		fmt.Printf("Restored plan revision %d on task %s\n", rev, task.RefID)
+		return nil
+	},
+}
+
+// parseRev parses a plan revision number, accepting an optional "r" prefix.
+func parseRev(raw string) (int, error) {
+	rev, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "r"))
+	if err != nil || rev < 1 {
+		return 0, fmt.Errorf("invalid plan revision: %s", raw)
+	}
+	return rev, nil
+}
+
+// --- task link / unlink ---
+
+var taskLinkCmd = &cobra.Command{
+	Use:   "link [id]",
+	Short: "Record that a task blocks, or is blocked by, another task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTaskLink(cmd, args[0], false)
+	},
+}
+
+var taskUnlinkCmd = &cobra.Command{
+	Use:   "unlink [id]",
+	Short: "Remove a blocking relation between two tasks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTaskLink(cmd, args[0], true)
+	},
+}
+
+func runTaskLink(cmd *cobra.Command, rawID string, remove bool) error {
+	root, s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	id, err := s.ParseTaskID(rawID)
+	if err != nil {
+		return err
+	}
+
+	blocks, _ := cmd.Flags().GetString("blocks")
+	blockedBy, _ := cmd.Flags().GetString("blocked-by")
+	if (blocks == "") == (blockedBy == "") {
+		return fmt.Errorf("specify exactly one of --blocks or --blocked-by")
+	}
+
+	blocker, blocked := id, int64(0)
+	if blocks != "" {
+		if blocked, err = s.ParseTaskID(blocks); err != nil {
+			return err
+		}
+	} else {
+		if blocker, err = s.ParseTaskID(blockedBy); err != nil {
+			return err
+		}
+		blocked = id
+	}
+
+	if remove {
+		err = s.UnlinkTasks(blocker, blocked, "")
+	} else {
+		err = s.LinkTasks(blocker, blocked, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := project.UpdateContext(root, s); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+	}
+
+	if remove {
+		fmt.Printf("Task #%d no longer blocks task #%d\n", blocker, blocked)
+	} else {
+		fmt.Printf("Task #%d now blocks task #%d\n", blocker, blocked)
+	}
+	return nil
+}
+
+// --- task archive / unarchive ---
+
+var taskArchiveCmd = &cobra.Command{
+	Use:   "archive [id...]",
+	Short: "Archive tasks, hiding them from listings and the context file",
+	Long:  "Archived tasks keep their files, events and history but are left out of task list, status, next and current_context.json. Pass task IDs, or select tasks with --milestone and/or --status (e.g. --milestone v1 --status done).",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTaskArchive(cmd, args, true)
+	},
+}
+
+var taskUnarchiveCmd = &cobra.Command{
+	Use:   "unarchive [id...]",
+	Short: "Bring archived tasks back",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTaskArchive(cmd, args, false)
+	},
+}
+
+func runTaskArchive(cmd *cobra.Command, rawIDs []string, archive bool) error {
+	milestone, _ := cmd.Flags().GetString("milestone")
+	status, _ := cmd.Flags().GetString("status")
+	if len(rawIDs) == 0 && milestone == "" && status == "" {
+		return fmt.Errorf("specify task IDs or select tasks with --milestone and/or --status")
+	}
+
+	root, s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var ids []int64
+	for _, raw := range rawIDs {
+		id, err := s.ParseTaskID(raw)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if milestone != "" || status != "" {
+		tasks, err := s.FilterTasks(store.TaskFilter{Status: status, Milestone: milestone, IncludeArchived: true})
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			if t.Archived != archive && !slices.Contains(ids, t.ID) {
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("No matching tasks.")
+		return nil
+	}
+
+	verb := "Archived"
+	if !archive {
+		verb = "Unarchived"
+	}
+	for _, id := range ids {
+		task, err := s.UpdateTask(id, store.TaskUpdate{Archived: &archive})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s task %s: %s\n", verb, task.RefID, task.Title)
+	}
+
+	if err := project.UpdateContext(root, s); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+	}
+	return nil
+}
+
+// --- task next ---
+
+var taskNextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "List todo tasks that are not blocked by unfinished work",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		tasks, err := s.NextTasks()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(tasks, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(tasks) == 0 {
+			fmt.Println("No unblocked todo tasks.")
+			return nil
+		}
+
+		output.PrintTaskTable(tasks)
 		return nil
 	},
 }
@@ -250,17 +727,27 @@ func init() {
 	rootCmd.AddCommand(taskCmd)
 
 	taskAddCmd.Flags().StringP("description", "d", "", "Task description")
-	taskAddCmd.Flags().StringP("status", "s", "", "Task status (todo, in_planning, in_progress, done, blocked)")
+	taskAddCmd.Flags().StringP("status", "s", "", "Task status (see 'ghist workflow'; defaults to the first status)")
 	taskAddCmd.Flags().StringP("milestone", "m", "", "Milestone name")
-	taskAddCmd.Flags().StringP("priority", "p", "", "Priority (low, medium, high, urgent)")
-	taskAddCmd.Flags().StringP("type", "t", "", "Type (bug, feature, improvement, chore)")
+	taskAddCmd.Flags().StringP("priority", "p", "", "Priority (see 'ghist workflow')")
+	taskAddCmd.Flags().StringP("type", "t", "", "Type (see 'ghist workflow')")
 	taskAddCmd.Flags().String("legacy-id", "", "Legacy ID from external system")
+	taskAddCmd.Flags().String("parent", "", "Create as a subtask of this task")
+	taskAddCmd.Flags().StringSlice("label", nil, "Label to add (repeatable or comma-separated)")
+	taskAddCmd.Flags().String("assignee", "", "Who the task is assigned to (\"me\" for yourself)")
 	taskCmd.AddCommand(taskAddCmd)
 
 	taskListCmd.Flags().StringP("status", "s", "", "Filter by status")
 	taskListCmd.Flags().StringP("milestone", "m", "", "Filter by milestone")
 	taskListCmd.Flags().StringP("priority", "p", "", "Filter by priority")
 	taskListCmd.Flags().StringP("type", "t", "", "Filter by type")
+	taskListCmd.Flags().String("assignee", "", "Filter by assignee (\"me\" for yourself, \"none\" for unassigned)")
+	taskListCmd.Flags().StringSlice("label", nil, "Filter by label (repeatable or comma-separated)")
+	taskListCmd.Flags().String("label-match", "all", "Match tasks with 'all' or 'any' of the --label values")
+	taskListCmd.Flags().StringP("query", "q", "", "Query expression, e.g. 'status:todo,blocked priority>=high updated:<7d'")
+	taskListCmd.Flags().String("sort", "id", "Sort field with optional asc/desc, e.g. 'updated_at desc'")
+	taskListCmd.Flags().Int("limit", 0, "Show at most this many tasks")
+	taskListCmd.Flags().Bool("include-archived", false, "Include archived tasks")
 	taskListCmd.Flags().Bool("json", false, "Output as JSON")
 	taskCmd.AddCommand(taskListCmd)
 
@@ -268,35 +755,84 @@ func init() {
 
 	taskUpdateCmd.Flags().String("title", "", "New title")
 	taskUpdateCmd.Flags().StringP("description", "d", "", "New description")
-	taskUpdateCmd.Flags().StringP("status", "s", "", "New status (todo, in_planning, in_progress, done, blocked)")
+	taskUpdateCmd.Flags().StringP("status", "s", "", "New status (see 'ghist workflow')")
 	taskUpdateCmd.Flags().StringP("milestone", "m", "", "New milestone")
 	taskUpdateCmd.Flags().String("commit-hash", "", "Associated commit hash")
 	taskUpdateCmd.Flags().String("plan", "", "Implementation plan text")
 	taskUpdateCmd.Flags().Bool("plan-stdin", false, "Read plan from stdin")
-	taskUpdateCmd.Flags().StringP("priority", "p", "", "Priority (low, medium, high, urgent)")
-	taskUpdateCmd.Flags().StringP("type", "t", "", "Type (bug, feature, improvement, chore)")
+	taskUpdateCmd.Flags().StringP("priority", "p", "", "Priority (see 'ghist workflow')")
+	taskUpdateCmd.Flags().StringP("type", "t", "", "Type (see 'ghist workflow')")
 	taskUpdateCmd.Flags().String("legacy-id", "", "Legacy ID from external system")
+	taskUpdateCmd.Flags().String("parent", "", "Move under this parent task (\"none\" to detach)")
+	taskUpdateCmd.Flags().String("assignee", "", "Assign the task (\"me\" for yourself, \"none\" to unassign)")
+	taskUpdateCmd.Flags().StringSlice("label", nil, "Label to add (repeatable or comma-separated)")
+	taskUpdateCmd.Flags().StringSlice("remove-label", nil, "Label to remove (repeatable or comma-separated)")
+	taskUpdateCmd.Flags().Int64("if-revision", 0, "Fail unless the task is still at this revision (see 'ghist task show')")
 	taskCmd.AddCommand(taskUpdateCmd)
 
 	taskCmd.AddCommand(taskDeleteCmd)
+
+	for _, c := range []*cobra.Command{taskLinkCmd, taskUnlinkCmd} {
+		c.Flags().String("blocks", "", "Task that this task blocks")
+		c.Flags().String("blocked-by", "", "Task that blocks this task")
+		taskCmd.AddCommand(c)
+	}
+
+	taskHistoryCmd.Flags().Bool("json", false, "Output as JSON")
+	taskCmd.AddCommand(taskHistoryCmd)
+
+	taskPlanHistoryCmd.Flags().Bool("json", false, "Output as JSON")
+	taskPlanHistoryCmd.Flags().Int("rev", 0, "Print the full text of this revision")
+	taskCmd.AddCommand(taskPlanHistoryCmd)
+	taskCmd.AddCommand(taskPlanDiffCmd)
+	taskCmd.AddCommand(taskPlanRestoreCmd)
+
+	for _, c := range []*cobra.Command{taskArchiveCmd, taskUnarchiveCmd} {
+		c.Flags().StringP("milestone", "m", "", "Select tasks in this milestone")
+		c.Flags().StringP("status", "s", "", "Select tasks with this status")
+		taskCmd.AddCommand(c)
+	}
+
+	taskNextCmd.Flags().Bool("json", false, "Output as JSON")
+	taskCmd.AddCommand(taskNextCmd)
 }
 
-// openStore finds the project root and opens the store.
-func openStore() (string, *store.Store, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return "", nil, fmt.Errorf("getting working directory: %w", err)
+// resolveAssignee turns "me" into the user's identity; anything else is
+// taken as given.
+func resolveAssignee(root, v string) (string, error) {
+	if !strings.EqualFold(strings.TrimSpace(v), "me") {
+		return v, nil
+	}
+	me := project.Identity(root, actorFlag)
+	if me == "" {
+		return "", fmt.Errorf("cannot tell who \"me\" is: pass --as, set %s or configure git user.name", project.ActorEnv)
 	}
+	return me, nil
+}
 
-	root, err := project.FindRoot(cwd)
+// openStore finds the project root and opens the store.
+func openStore() (string, store.Store, error) {
+	root, err := findRoot()
 	if err != nil {
-		return "", nil, fmt.Errorf("not a ghist project (run 'ghist init' first): %w", err)
+		return "", nil, err
 	}
 
-	s, err := store.Open(project.GhistDirPath(root))
+	fileStore, err := store.Open(project.GhistDirPath(root))
 	if err != nil {
 		return "", nil, fmt.Errorf("opening database: %w", err)
 	}
+	for _, m := range fileStore.Migrations() {
+		if m.Summary != "" {
+			fmt.Fprintln(os.Stderr, project.FormatMigration(m))
+		}
+	}
 
+	var s store.Store = fileStore
+	if a, ok := s.(store.ActorSetter); ok {
+		a.SetActor(project.ResolveActor(root, actorFlag))
+	}
+	if o, ok := s.(store.OperationRecorder); ok && operationName != "" {
+		o.BeginOperation(operationName)
+	}
 	return root, s, nil
 }
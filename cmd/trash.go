@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/unnecessary-special-projects/ghist/internal/output"
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore or empty deleted tasks",
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List deleted tasks, most recent first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		trashed, err := s.ListTrash()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(trashed, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(trashed) == 0 {
+			fmt.Println("Trash is empty.")
+			return nil
+		}
+
+		output.PrintTrashTable(trashed)
+		return nil
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore [id]",
+	Short: "Restore a deleted task and re-link its events",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		id, err := s.ParseTaskID(args[0])
+		if err != nil {
+			return err
+		}
+
+		report, err := s.RestoreTask(id)
+		if err != nil {
+			return err
+		}
+
+		if err := project.UpdateContext(root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+		}
+
+		fmt.Printf("Restored task %s: %s\n", report.Task.RefID, report.Task.Title)
+		if report.Task.ID != report.PreviousID {
+			fmt.Printf("  Task #%d was taken; restored as #%d\n", report.PreviousID, report.Task.ID)
+		}
+		if report.EventsRelinked > 0 {
+			fmt.Printf("  Re-linked %d event(s)\n", report.EventsRelinked)
+		}
+		if report.ChildrenReattached > 0 {
+			fmt.Printf("  Moved %d subtask(s) back under it\n", report.ChildrenReattached)
+		}
+		return nil
+	},
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently remove every task in the trash",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		n, err := s.EmptyTrash()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d task(s) from the trash\n", n)
+		return nil
+	},
+}
+
+func init() {
+	trashListCmd.Flags().Bool("json", false, "Output as JSON")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// noJournal is a command annotation that keeps openStore from recording the
+// command in the undo journal.
+const noJournal = "ghist/no-journal"
+
+// operationName is the command line being run, recorded in the undo journal
+// with the files it changes. It is set before each command runs.
+var operationName string
+
+// commandLine renders the running command for the journal, quoting arguments
+// that contain spaces or quotes.
+func commandLine() string {
+	words := []string{"ghist"}
+	for _, a := range os.Args[1:] {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = strconv.Quote(a)
+		}
+		words = append(words, a)
+	}
+	return strings.Join(words, " ")
+}
+
+var undoCmd = &cobra.Command{
+	Use:         "undo",
+	Short:       "Revert the last ghist command that changed something",
+	Long:        "Restores every file the last changing ghist command wrote or removed, as recorded in the local journal under .ghist/journal/. Run it again to go further back; the journal keeps the last 50 commands. Undo refuses to run if those files have changed since, for example after a git checkout. Changes made through ghist serve are not journaled.",
+	Annotations: map[string]string{noJournal: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		op, err := s.Undo()
+		if err != nil {
+			return err
+		}
+
+		if err := project.UpdateContext(root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update context: %v\n", err)
+		}
+
+		fmt.Printf("Undid: %s (%s, %d file(s) restored)\n", op.Name, op.At.Local().Format("2006-01-02 15:04"), len(op.Files))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
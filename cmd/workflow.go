@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Show the project's statuses, transitions, priorities and types",
+	Long:  "Shows the project workflow. It is defined under \"workflow\" in .ghist/settings.json; anything left out falls back to the built-in default. Run 'ghist workflow init' to write the default there as a starting point.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		wf, err := s.Workflow()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(wf, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Println("Statuses:")
+		for _, st := range wf.Statuses {
+			line := "  " + st.Name
+			if st.Done {
+				line += " (done)"
+			}
+			if next, ok := wf.Transitions[st.Name]; ok {
+				line += " → " + strings.Join(next, ", ")
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("\nPriorities (low → high): %s\n", strings.Join(wf.Priorities, ", "))
+		fmt.Printf("Types: %s\n", strings.Join(wf.Types, ", "))
+		return nil
+	},
+}
+
+var workflowInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write the default workflow to settings.json for editing",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, s, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		force, _ := cmd.Flags().GetBool("force")
+
+		current, err := s.Workflow()
+		if err != nil {
+			return err
+		}
+		def := models.DefaultWorkflow()
+		if !force {
+			cur, _ := json.Marshal(current)
+			want, _ := json.Marshal(def)
+			if string(cur) != string(want) {
+				return fmt.Errorf("settings.json already defines a custom workflow (use --force to overwrite)")
+			}
+		}
+
+		if err := s.SetWorkflow(def); err != nil {
+			return err
+		}
+		fmt.Println("Wrote default workflow to .ghist/settings.json")
+		return nil
+	},
+}
+
+func init() {
+	workflowCmd.Flags().Bool("json", false, "Output as JSON")
+	workflowInitCmd.Flags().Bool("force", false, "Overwrite a custom workflow")
+	workflowCmd.AddCommand(workflowInitCmd)
+	rootCmd.AddCommand(workflowCmd)
+}
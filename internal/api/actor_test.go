@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+)
+
+func newTestServer(t *testing.T) (*Server, *store.FileStore) {
+	t.Helper()
+	s, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("opening test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.SetActor("server")
+	return NewServer(s, nil, false, ""), s
+}
+
+func TestRequestActorRecorded(t *testing.T) {
+	srv, s := newTestServer(t)
+	a, _ := s.CreateTask(store.CreateTaskInput{Title: "Blocker"})
+	b, _ := s.CreateTask(store.CreateTaskInput{Title: "Blocked"})
+	for _, plan := range []string{"first", "second"} {
+		s.UpdateTask(a.ID, store.TaskUpdate{Plan: &plan})
+	}
+	s.CreateMilestone(store.MilestoneInput{Name: "later"})
+
+	steps := []struct {
+		method, path, body string
+		actor, eventType   string
+	}{
+		{"POST", "/api/opportunities", `{"name": "Acme"}`, "ann", models.EventOpportunityCreated},
+		{"PATCH", "/api/opportunities/1", `{"notes": "Wants SSO"}`, "bob", models.EventOpportunityUpdated},
+		{"DELETE", "/api/opportunities/1", ``, "cat", models.EventOpportunityDeleted},
+		{"POST", "/api/milestones", `{"name": "v1"}`, "dan", models.EventMilestoneCreated},
+		{"PATCH", "/api/milestones/v1", `{"description": "First cut"}`, "eve", models.EventMilestoneUpdated},
+		{"PATCH", "/api/milestones/v1", `{"state": "closed"}`, "fay", models.EventMilestoneUpdated},
+		{"POST", "/api/milestones/v1/rename", `{"name": "v2"}`, "gus", models.EventMilestoneUpdated},
+		{"POST", "/api/milestones/v2/merge", `{"into": "later"}`, "hal", models.EventMilestoneDeleted},
+		{"DELETE", "/api/milestones/later", ``, "ivy", models.EventMilestoneDeleted},
+		{"POST", "/api/tasks/1/links", `{"blocks": 2}`, "jon", models.EventTaskLinked},
+		{"DELETE", "/api/tasks/1/links", `{"blocks": 2}`, "kim", models.EventTaskUnlinked},
+		{"POST", "/api/tasks/1/plan/revisions/1/restore", ``, "lee", models.EventTaskPlanUpdated},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("X-Ghist-Actor", step.actor)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code >= 300 {
+			t.Errorf("%s %s: status %d: %s", step.method, step.path, rec.Code, rec.Body)
+			continue
+		}
+		events, _ := s.ListEventsByActor(step.actor, 0)
+		found := false
+		for _, e := range events {
+			found = found || e.Type == step.eventType
+		}
+		if !found {
+			t.Errorf("%s %s: expected a %s event by %s, got %+v", step.method, step.path, step.eventType, step.actor, events)
+		}
+	}
+
+	// Both tasks in a link record the requester, not the server's actor.
+	for _, id := range []int64{a.ID, b.ID} {
+		history, _ := s.TaskHistory(id)
+		for _, e := range history {
+			if (e.Type == models.EventTaskLinked || e.Type == models.EventTaskUnlinked) && e.Actor != "jon" && e.Actor != "kim" {
+				t.Errorf("expected link events on task %d by the requester, got %+v", id, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+)
+
+func TestErrorStatusCodes(t *testing.T) {
+	srv, s := newTestServer(t)
+	s.CreateTask(store.CreateTaskInput{Title: "First"})
+	s.CreateTask(store.CreateTaskInput{Title: "Second"})
+	s.CreateMilestone(store.MilestoneInput{Name: "v1"})
+
+	steps := []struct {
+		method, path, body, ifMatch string
+		want                        int
+	}{
+		{"PATCH", "/api/tasks/99", `{"title": "Gone"}`, "", http.StatusNotFound},
+		{"DELETE", "/api/tasks/99", ``, "", http.StatusNotFound},
+		{"PATCH", "/api/tasks/1", `{"status": "nonsense"}`, "", http.StatusBadRequest},
+		{"PATCH", "/api/tasks/1", `{"parent_id": 99}`, "", http.StatusBadRequest},
+		{"PATCH", "/api/tasks/1", `{"title": "Stale"}`, `"0"`, http.StatusPreconditionFailed},
+		{"DELETE", "/api/tasks/1", ``, `"0"`, http.StatusPreconditionFailed},
+		{"POST", "/api/tasks/1/links", `{"blocks": 99}`, "", http.StatusNotFound},
+		{"POST", "/api/tasks/1/links", `{"blocks": 1}`, "", http.StatusBadRequest},
+		{"DELETE", "/api/tasks/1/links", `{"blocks": 2}`, "", http.StatusNotFound},
+		{"GET", "/api/tasks/1/plan/revisions", ``, "", http.StatusOK},
+		{"GET", "/api/tasks/99/plan/revisions", ``, "", http.StatusNotFound},
+		{"POST", "/api/trash/99/restore", ``, "", http.StatusNotFound},
+		{"PATCH", "/api/opportunities/99", `{"notes": "x"}`, "", http.StatusNotFound},
+		{"DELETE", "/api/opportunities/99", ``, "", http.StatusNotFound},
+		{"GET", "/api/milestones/nope", ``, "", http.StatusNotFound},
+		{"PATCH", "/api/milestones/nope", `{"description": "x"}`, "", http.StatusNotFound},
+		{"PATCH", "/api/milestones/v1", `{"state": "half-open"}`, "", http.StatusBadRequest},
+		{"POST", "/api/milestones/v1/merge", `{"into": "nope"}`, "", http.StatusBadRequest},
+		{"GET", "/api/reports/burndown?milestone=nope", ``, "", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", step.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != step.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", step.method, step.path, step.body, step.want, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestErrorStatusStoreFailure(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.Open(dir)
+	if err != nil {
+		t.Fatalf("opening test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	srv := NewServer(s, nil, false, "")
+	s.CreateTask(store.CreateTaskInput{Title: "Corrupt"})
+
+	// A task file that exists but cannot be parsed is the server's problem,
+	// not a missing task.
+	files, _ := filepath.Glob(filepath.Join(dir, "tasks", "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one task file, got %v", files)
+	}
+	if err := os.WriteFile(files[0], []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"PATCH", "DELETE"} {
+		req := httptest.NewRequest(method, "/api/tasks/1", strings.NewReader(`{"title": "x"}`))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s /api/tasks/1 on a corrupt task: expected 500, got %d: %s", method, rec.Code, rec.Body)
+		}
+	}
+}
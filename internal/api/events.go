@@ -24,7 +24,7 @@ func (s *Server) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "message is required")
 		return
 	}
-	event, err := s.store.CreateEvent(req.Type, req.Message, "{}", req.TaskID)
+	event, err := s.store.CreateEventAs(requestActor(r), req.Type, req.Message, "{}", req.TaskID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -33,7 +33,7 @@ func (s *Server) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleListTaskEvents(w http.ResponseWriter, r *http.Request) {
-	id, err := models.ParseTaskID(r.PathValue("id"))
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
@@ -50,8 +50,14 @@ func (s *Server) handleListTaskEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	prefix, err := s.store.RefPrefix()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{
 		"github_repo_url": s.repoURL,
+		"ref_prefix":      prefix,
 	})
 }
 
@@ -63,7 +69,13 @@ func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	events, err := s.store.ListEvents(limit)
+	var events []models.Event
+	var err error
+	if actor := r.URL.Query().Get("actor"); actor != "" {
+		events, err = s.store.ListEventsByActor(actor, limit)
+	} else {
+		events, err = s.store.ListEvents(limit)
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+)
+
+func (s *Server) handleListMilestones(w http.ResponseWriter, r *http.Request) {
+	ms, err := s.store.ListMilestones()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ms == nil {
+		ms = []models.Milestone{}
+	}
+	writeJSON(w, http.StatusOK, ms)
+}
+
+type createMilestoneRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	StartDate   string `json:"start_date"`
+	TargetDate  string `json:"target_date"`
+}
+
+func (s *Server) handleCreateMilestone(w http.ResponseWriter, r *http.Request) {
+	var req createMilestoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	m, err := s.store.CreateMilestone(store.MilestoneInput{
+		Name:        req.Name,
+		Description: req.Description,
+		StartDate:   req.StartDate,
+		TargetDate:  req.TargetDate,
+		Actor:       requestActor(r),
+	})
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleGetMilestone(w http.ResponseWriter, r *http.Request) {
+	m, err := s.store.GetMilestone(r.PathValue("name"))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+type updateMilestoneRequest struct {
+	Description *string `json:"description"`
+	StartDate   *string `json:"start_date"`
+	TargetDate  *string `json:"target_date"`
+	State       *string `json:"state"` // "open" or "closed"
+}
+
+func (s *Server) handleUpdateMilestone(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req updateMilestoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	var m *models.Milestone
+	var err error
+	if req.State != nil {
+		if m, err = s.store.SetMilestoneState(name, *req.State, requestActor(r)); err != nil {
+			writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+			return
+		}
+	}
+	if req.Description != nil || req.StartDate != nil || req.TargetDate != nil || m == nil {
+		m, err = s.store.UpdateMilestone(name, store.MilestoneUpdate{
+			Description: req.Description,
+			StartDate:   req.StartDate,
+			TargetDate:  req.TargetDate,
+			Actor:       requestActor(r),
+		})
+		if err != nil {
+			writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) handleDeleteMilestone(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.DeleteMilestone(r.PathValue("name"), requestActor(r)); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// milestoneMove is the response to a rename or merge: where the tasks went.
+type milestoneMove struct {
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks"`
+}
+
+func (s *Server) handleRenameMilestone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	n, err := s.store.RenameMilestone(r.PathValue("name"), req.Name, requestActor(r))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, milestoneMove{Name: req.Name, Tasks: n})
+}
+
+func (s *Server) handleMergeMilestone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	n, err := s.store.MergeMilestone(r.PathValue("name"), req.Into, requestActor(r))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, milestoneMove{Name: req.Into, Tasks: n})
+}
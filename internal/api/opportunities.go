@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/store"
+)
+
+func (s *Server) handleListOpportunities(w http.ResponseWriter, r *http.Request) {
+	opps, err := s.store.ListOpportunities()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if opps == nil {
+		opps = []models.Opportunity{}
+	}
+	writeJSON(w, http.StatusOK, opps)
+}
+
+type createOpportunityRequest struct {
+	Name  string `json:"name"`
+	Notes string `json:"notes"`
+}
+
+func (s *Server) handleCreateOpportunity(w http.ResponseWriter, r *http.Request) {
+	var req createOpportunityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	opp, err := s.store.CreateOpportunity(req.Name, req.Notes, requestActor(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, opp)
+}
+
+func (s *Server) handleGetOpportunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid opportunity id")
+		return
+	}
+	opp, err := s.store.GetOpportunity(id)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, opp)
+}
+
+type updateOpportunityRequest struct {
+	Name  *string `json:"name"`
+	Notes *string `json:"notes"`
+}
+
+func (s *Server) handleUpdateOpportunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid opportunity id")
+		return
+	}
+	var req updateOpportunityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	opp, err := s.store.UpdateOpportunity(id, store.OpportunityUpdate{
+		Name:  req.Name,
+		Notes: req.Notes,
+		Actor: requestActor(r),
+	})
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, opp)
+}
+
+func (s *Server) handleDeleteOpportunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid opportunity id")
+		return
+	}
+	if err := s.store.DeleteOpportunity(id, requestActor(r)); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
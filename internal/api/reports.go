@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Report endpoints answer in JSON, or in CSV with ?format=csv. They all take
+// ?milestone= to count only the tasks in one milestone.
+
+func (s *Server) handleBurndownReport(w http.ResponseWriter, r *http.Request) {
+	b, err := s.store.Burndown(r.URL.Query().Get("milestone"))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeReport(w, r, b, b.CSV)
+}
+
+func (s *Server) handleThroughputReport(w http.ResponseWriter, r *http.Request) {
+	weeks := 0
+	if v := r.URL.Query().Get("weeks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "weeks must be a positive integer")
+			return
+		}
+		weeks = n
+	}
+	tp, err := s.store.Throughput(weeks, r.URL.Query().Get("milestone"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeReport(w, r, tp, func() [][]string { return models.ThroughputCSV(tp) })
+}
+
+func (s *Server) handleLeadTimeReport(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.store.LeadTime(r.URL.Query().Get("milestone"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeReport(w, r, rep, rep.CSV)
+}
+
+func (s *Server) handleCycleTimeReport(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.store.CycleTime(r.URL.Query().Get("milestone"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeReport(w, r, rep, rep.CSV)
+}
+
+// writeReport writes data as JSON, or rows as CSV if the request asks for it.
+func writeReport(w http.ResponseWriter, r *http.Request, data any, rows func() [][]string) {
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, data)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		csv.NewWriter(w).WriteAll(rows())
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/unnecessary-special-projects/ghist/internal/search"
+)
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	results, err := s.store.Search(q, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if results == nil {
+		results = []search.Result{}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
@@ -2,17 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io/fs"
 	"log"
 	"net/http"
-	"path/filepath"
 	"strings"
 
 	"github.com/unnecessary-special-projects/ghist/internal/store"
 )
 
 type Server struct {
-	store   *store.Store
+	store   store.Store
 	mux     *http.ServeMux
 	webFS   fs.FS
 	devMode bool
@@ -20,7 +20,7 @@ type Server struct {
 	hub     *hub
 }
 
-func NewServer(s *store.Store, ghistDir string, webFS fs.FS, devMode bool, repoURL string) *Server {
+func NewServer(s store.Store, webFS fs.FS, devMode bool, repoURL string) *Server {
 	h := newHub()
 	srv := &Server{
 		store:   s,
@@ -31,10 +31,7 @@ func NewServer(s *store.Store, ghistDir string, webFS fs.FS, devMode bool, repoU
 		hub:     h,
 	}
 	srv.routes()
-	go watchDirs(h, []string{
-		filepath.Join(ghistDir, "tasks"),
-		filepath.Join(ghistDir, "events"),
-	})
+	go watchStore(h, s)
 	return srv
 }
 
@@ -49,14 +46,40 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("GET /api/tasks/{id}", s.handleGetTask)
 	s.mux.HandleFunc("PATCH /api/tasks/{id}", s.handleUpdateTask)
 	s.mux.HandleFunc("DELETE /api/tasks/{id}", s.handleDeleteTask)
+	s.mux.HandleFunc("GET /api/tasks/next", s.handleNextTasks)
+	s.mux.HandleFunc("GET /api/trash", s.handleListTrash)
+	s.mux.HandleFunc("POST /api/trash/{id}/restore", s.handleRestoreTask)
+	s.mux.HandleFunc("POST /api/tasks/{id}/links", s.handleLinkTask)
+	s.mux.HandleFunc("DELETE /api/tasks/{id}/links", s.handleUnlinkTask)
+	s.mux.HandleFunc("GET /api/tasks/{id}/plan/revisions", s.handleListPlanRevisions)
+	s.mux.HandleFunc("POST /api/tasks/{id}/plan/revisions/{rev}/restore", s.handleRestorePlanRevision)
 	s.mux.HandleFunc("GET /api/events", s.handleListEvents)
 	s.mux.HandleFunc("POST /api/events", s.handleCreateEvent)
 	s.mux.HandleFunc("GET /api/tasks/{id}/events", s.handleListTaskEvents)
+	s.mux.HandleFunc("GET /api/opportunities", s.handleListOpportunities)
+	s.mux.HandleFunc("POST /api/opportunities", s.handleCreateOpportunity)
+	s.mux.HandleFunc("GET /api/opportunities/{id}", s.handleGetOpportunity)
+	s.mux.HandleFunc("PATCH /api/opportunities/{id}", s.handleUpdateOpportunity)
+	s.mux.HandleFunc("DELETE /api/opportunities/{id}", s.handleDeleteOpportunity)
+	s.mux.HandleFunc("GET /api/milestones", s.handleListMilestones)
+	s.mux.HandleFunc("POST /api/milestones", s.handleCreateMilestone)
+	s.mux.HandleFunc("GET /api/milestones/{name}", s.handleGetMilestone)
+	s.mux.HandleFunc("PATCH /api/milestones/{name}", s.handleUpdateMilestone)
+	s.mux.HandleFunc("DELETE /api/milestones/{name}", s.handleDeleteMilestone)
+	s.mux.HandleFunc("POST /api/milestones/{name}/rename", s.handleRenameMilestone)
+	s.mux.HandleFunc("POST /api/milestones/{name}/merge", s.handleMergeMilestone)
+	s.mux.HandleFunc("GET /api/reports/burndown", s.handleBurndownReport)
+	s.mux.HandleFunc("GET /api/reports/throughput", s.handleThroughputReport)
+	s.mux.HandleFunc("GET /api/reports/lead-time", s.handleLeadTimeReport)
+	s.mux.HandleFunc("GET /api/reports/cycle-time", s.handleCycleTimeReport)
 	s.mux.HandleFunc("GET /api/status", s.handleStatus)
 	s.mux.HandleFunc("GET /api/config", s.handleConfig)
 	s.mux.HandleFunc("GET /api/events/stream", s.handleSSE)
 	s.mux.HandleFunc("GET /api/settings/milestone-order", s.handleGetMilestoneOrder)
 	s.mux.HandleFunc("PUT /api/settings/milestone-order", s.handleSetMilestoneOrder)
+	s.mux.HandleFunc("GET /api/workflow", s.handleGetWorkflow)
+	s.mux.HandleFunc("GET /api/labels", s.handleListLabels)
+	s.mux.HandleFunc("GET /api/search", s.handleSearch)
 
 	// Serve frontend (embedded or dev proxy)
 	if s.webFS != nil {
@@ -99,7 +122,8 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		if s.devMode {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match, X-Ghist-Actor")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
 				return
@@ -124,3 +148,22 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
+
+// errorStatus maps a store error to an HTTP status. Validation failures are
+// the client's fault (400), a missing record is 404 and a stale If-Match is
+// 412; anything else gets the handler's fallback.
+func errorStatus(err error, fallback int) int {
+	var verr *store.ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusBadRequest
+	}
+	var nerr *store.NotFoundError
+	if errors.As(err, &nerr) {
+		return http.StatusNotFound
+	}
+	var rerr *store.RevisionError
+	if errors.As(err, &rerr) {
+		return http.StatusPreconditionFailed
+	}
+	return fallback
+}
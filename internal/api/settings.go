@@ -27,3 +27,21 @@ func (s *Server) handleSetMilestoneOrder(w http.ResponseWriter, r *http.Request)
 	s.hub.broadcast()
 	writeJSON(w, http.StatusOK, order)
 }
+
+func (s *Server) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	wf, err := s.store.Workflow()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, wf)
+}
+
+func (s *Server) handleListLabels(w http.ResponseWriter, r *http.Request) {
+	labels, err := s.store.LabelCounts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, labels)
+}
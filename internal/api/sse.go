@@ -3,10 +3,10 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"strings"
 	"sync"
 	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/store"
 )
 
 // hub manages SSE subscriber channels.
@@ -44,38 +44,22 @@ func (h *hub) broadcast() {
 	h.mu.Unlock()
 }
 
-// watchDirs polls dirs every 500ms and broadcasts when anything changes.
-func watchDirs(h *hub, dirs []string) {
-	last := dirFingerprint(dirs)
+// watchStore polls the store's change stamp every 500ms and broadcasts when
+// it changes, whichever process made the change.
+func watchStore(h *hub, s store.Store) {
+	last, _ := s.ChangeStamp()
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 	for range ticker.C {
-		fp := dirFingerprint(dirs)
-		if fp != last {
-			last = fp
-			h.broadcast()
-		}
-	}
-}
-
-// dirFingerprint returns a string that changes whenever any file in dirs
-// is created, deleted, or modified.
-func dirFingerprint(dirs []string) string {
-	var sb strings.Builder
-	for _, dir := range dirs {
-		entries, err := os.ReadDir(dir)
+		stamp, err := s.ChangeStamp()
 		if err != nil {
 			continue
 		}
-		for _, e := range entries {
-			info, err := e.Info()
-			if err != nil {
-				continue
-			}
-			fmt.Fprintf(&sb, "%s:%d:%d|", e.Name(), info.Size(), info.ModTime().UnixNano())
+		if stamp != last {
+			last = stamp
+			h.broadcast()
 		}
 	}
-	return sb.String()
 }
 
 // handleSSE streams server-sent events to the client. It blocks until the
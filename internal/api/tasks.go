@@ -2,24 +2,78 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/query"
 	"github.com/unnecessary-special-projects/ghist/internal/store"
 )
 
 func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
-	status := r.URL.Query().Get("status")
-	milestone := r.URL.Query().Get("milestone")
-	priority := r.URL.Query().Get("priority")
-	taskType := r.URL.Query().Get("type")
-
-	tasks, err := s.store.ListTasks(status, milestone, priority, taskType)
+	q := r.URL.Query()
+	var labels []string
+	for _, v := range q["label"] {
+		labels = append(labels, strings.Split(v, ",")...)
+	}
+	labelMatch := q.Get("label_match")
+	if labelMatch != "" && labelMatch != "all" && labelMatch != "any" {
+		writeError(w, http.StatusBadRequest, "label_match must be 'all' or 'any'")
+		return
+	}
+	expr, err := query.Parse(q.Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := query.ParseSort(q.Get("sort"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	includeArchived := false
+	if a := q.Get("archived"); a != "" {
+		if includeArchived, err = strconv.ParseBool(a); err != nil {
+			writeError(w, http.StatusBadRequest, "archived must be true or false")
+			return
+		}
+	}
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+	}
+	wf, err := s.store.Workflow()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	tasks, err := s.store.FilterTasks(store.TaskFilter{
+		Status:          q.Get("status"),
+		Milestone:       q.Get("milestone"),
+		Priority:        q.Get("priority"),
+		Type:            q.Get("type"),
+		Assignee:        q.Get("assignee"),
+		Labels:          labels,
+		AnyLabel:        labelMatch == "any",
+		IncludeArchived: includeArchived,
+	})
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	tasks, err = query.Apply(tasks, expr, order, limit, query.Env{Workflow: wf, Now: time.Now(), Me: requestActor(r)})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if tasks == nil {
 		tasks = []models.Task{}
 	}
@@ -28,13 +82,16 @@ func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
 }
 
 type createTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Status      string `json:"status"`
-	Milestone   string `json:"milestone"`
-	Priority    string `json:"priority"`
-	Type        string `json:"type"`
-	LegacyID    string `json:"legacy_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Milestone   string   `json:"milestone"`
+	Priority    string   `json:"priority"`
+	Type        string   `json:"type"`
+	LegacyID    string   `json:"legacy_id"`
+	Assignee    string   `json:"assignee"`
+	ParentID    *int64   `json:"parent_id"`
+	Labels      []string `json:"labels"`
 }
 
 func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
@@ -57,9 +114,13 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		Priority:    req.Priority,
 		Type:        req.Type,
 		LegacyID:    req.LegacyID,
+		Assignee:    req.Assignee,
+		ParentID:    req.ParentID,
+		Labels:      req.Labels,
+		Actor:       requestActor(r),
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -67,7 +128,7 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
-	id, err := models.ParseTaskID(r.PathValue("id"))
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
@@ -75,11 +136,68 @@ func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.store.GetTask(id)
 	if err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, task)
+	subtasks, err := s.store.ListSubtasks(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	writeJSON(w, http.StatusOK, buildTaskTree(*task, subtasks))
+}
+
+// requestActor is who the request says is making it, from the X-Ghist-Actor
+// header. Empty means the server's own actor.
+func requestActor(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-Ghist-Actor"))
+}
+
+// taskETag is a task's revision as an HTTP entity tag.
+func taskETag(t *models.Task) string {
+	return `"` + strconv.FormatInt(t.Revision, 10) + `"`
+}
+
+// ifMatchRevision returns the task revision named by the request's If-Match
+// header, or nil if there is none or it is "*".
+func ifMatchRevision(r *http.Request) (*int64, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return nil, nil
+	}
+	rev, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(h, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid If-Match header %q: want a task ETag such as \"3\"", h)
+	}
+	return &rev, nil
+}
+
+// taskTree is a task with its subtasks nested beneath it.
+type taskTree struct {
+	models.Task
+	Children []taskTree `json:"children"`
+}
+
+// buildTaskTree nests subtasks (all descendants of root) under root by ParentID.
+func buildTaskTree(root models.Task, subtasks []models.Task) taskTree {
+	children := make(map[int64][]models.Task)
+	for _, t := range subtasks {
+		if t.ParentID != nil {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		}
+	}
+	var build func(t models.Task) taskTree
+	build = func(t models.Task) taskTree {
+		node := taskTree{Task: t, Children: []taskTree{}}
+		for _, c := range children[t.ID] {
+			node.Children = append(node.Children, build(c))
+		}
+		return node
+	}
+	return build(root)
 }
 
 type updateTaskRequest struct {
@@ -92,15 +210,29 @@ type updateTaskRequest struct {
 	Priority    *string `json:"priority"`
 	Type        *string `json:"type"`
 	LegacyID    *string `json:"legacy_id"`
+	Assignee    *string `json:"assignee"`  // "" unassigns
+	ParentID    *int64  `json:"parent_id"` // 0 detaches from the parent
+	// Labels replaces the task's labels; AddLabels and RemoveLabels adjust
+	// them without resending the full list.
+	Labels       *[]string `json:"labels"`
+	AddLabels    []string  `json:"add_labels"`
+	RemoveLabels []string  `json:"remove_labels"`
+	Archived     *bool     `json:"archived"`
 }
 
 func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
-	id, err := models.ParseTaskID(r.PathValue("id"))
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	ifRevision, err := ifMatchRevision(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var req updateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON")
@@ -108,35 +240,182 @@ func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	task, err := s.store.UpdateTask(id, store.TaskUpdate{
-		Title:       req.Title,
-		Description: req.Description,
-		Plan:        req.Plan,
-		Status:      req.Status,
-		Milestone:   req.Milestone,
-		CommitHash:  req.CommitHash,
-		Priority:    req.Priority,
-		Type:        req.Type,
-		LegacyID:    req.LegacyID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Plan:         req.Plan,
+		Status:       req.Status,
+		Milestone:    req.Milestone,
+		CommitHash:   req.CommitHash,
+		Priority:     req.Priority,
+		Type:         req.Type,
+		LegacyID:     req.LegacyID,
+		Assignee:     req.Assignee,
+		ParentID:     req.ParentID,
+		Labels:       req.Labels,
+		AddLabels:    req.AddLabels,
+		RemoveLabels: req.RemoveLabels,
+		Archived:     req.Archived,
+		IfRevision:   ifRevision,
+		Actor:        requestActor(r),
 	})
 	if err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
+	w.Header().Set("ETag", taskETag(task))
 	writeJSON(w, http.StatusOK, task)
 }
 
 func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
-	id, err := models.ParseTaskID(r.PathValue("id"))
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
-	if err := s.store.DeleteTask(id); err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+	ifRevision, err := ifMatchRevision(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if ifRevision != nil {
+		err = s.store.DeleteTaskAtRevision(id, *ifRevision)
+	} else {
+		err = s.store.DeleteTask(id)
+	}
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
+
+// linkRequest names the other task in a blocking relation; exactly one of
+// Blocks or BlockedBy must be set.
+type linkRequest struct {
+	Blocks    *int64 `json:"blocks"`
+	BlockedBy *int64 `json:"blocked_by"`
+}
+
+func (s *Server) handleLinkTask(w http.ResponseWriter, r *http.Request) {
+	s.editTaskLink(w, r, s.store.LinkTasks)
+}
+
+func (s *Server) handleUnlinkTask(w http.ResponseWriter, r *http.Request) {
+	s.editTaskLink(w, r, s.store.UnlinkTasks)
+}
+
+func (s *Server) editTaskLink(w http.ResponseWriter, r *http.Request, edit func(blocker, blocked int64, actor string) error) {
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	var req linkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if (req.Blocks == nil) == (req.BlockedBy == nil) {
+		writeError(w, http.StatusBadRequest, "exactly one of blocks or blocked_by is required")
+		return
+	}
+
+	blocker, blocked := id, int64(0)
+	if req.Blocks != nil {
+		blocked = *req.Blocks
+	} else {
+		blocker, blocked = *req.BlockedBy, id
+	}
+	if err := edit(blocker, blocked, requestActor(r)); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	task, err := s.store.GetTask(id)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) handleNextTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.store.NextTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *Server) handleListPlanRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	revs, err := s.store.ListPlanRevisions(id)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	if revs == nil {
+		revs = []models.PlanRevision{}
+	}
+	writeJSON(w, http.StatusOK, revs)
+}
+
+func (s *Server) handleRestorePlanRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+	task, err := s.store.RestorePlanRevision(id, rev, requestActor(r))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	trashed, err := s.store.ListTrash()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if trashed == nil {
+		trashed = []models.TrashedTask{}
+	}
+	writeJSON(w, http.StatusOK, trashed)
+}
+
+func (s *Server) handleRestoreTask(w http.ResponseWriter, r *http.Request) {
+	id, err := s.store.ParseTaskID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	report, err := s.store.RestoreTask(id)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Unreleased is the version of a changelog section that names no release.
+const Unreleased = "Unreleased"
+
+// DefaultChangelogTemplate renders a Changelog as a Keep a Changelog
+// section. Custom templates are executed with the same data.
+const DefaultChangelogTemplate = `## [{{.Version}}]{{if .Date}} - {{.Date}}{{end}}
+{{range .Sections}}
+### {{.Title}}
+
+{{range .Entries}}- {{.Title}} ({{if .TaskURL}}[{{.RefID}}]({{.TaskURL}}){{else}}{{.RefID}}{{end}}{{if .Commit}}, {{if .CommitURL}}[{{.ShortCommit}}]({{.CommitURL}}){{else}}{{.ShortCommit}}{{end}}{{end}})
+{{end}}{{end}}`
+
+// changelogSections maps the default task types to their Keep a Changelog
+// heading, in the order the headings appear. Other types get a heading of
+// their own after these, in workflow order, and untyped tasks come last.
+var changelogSections = []struct{ typ, title string }{
+	{"feature", "Added"},
+	{"improvement", "Changed"},
+	{"bug", "Fixed"},
+	{"chore", "Maintenance"},
+}
+
+// Changelog is one release's section of a changelog. It is the data a
+// changelog template is executed with.
+type Changelog struct {
+	Version  string             `json:"version"`
+	Date     string             `json:"date,omitempty"` // YYYY-MM-DD
+	RepoURL  string             `json:"repo_url,omitempty"`
+	Sections []ChangelogSection `json:"sections"`
+}
+
+// ChangelogSection groups the entries of one task type.
+type ChangelogSection struct {
+	Title   string           `json:"title"`
+	Type    string           `json:"type"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// ChangelogEntry is one finished task. The URLs are set when the project has
+// a GitHub remote: TaskURL searches the repository's commits for the ref.
+type ChangelogEntry struct {
+	RefID       string `json:"ref_id"`
+	Title       string `json:"title"`
+	Milestone   string `json:"milestone,omitempty"`
+	Commit      string `json:"commit,omitempty"`
+	ShortCommit string `json:"short_commit,omitempty"`
+	TaskURL     string `json:"task_url,omitempty"`
+	CommitURL   string `json:"commit_url,omitempty"`
+}
+
+// NewChangelog groups tasks by type into the section for version, keeping
+// their order within each section. types is the workflow's type order and
+// repoURL a GitHub repository URL, or "" for no links.
+func NewChangelog(version, date, repoURL string, tasks []Task, types []string) *Changelog {
+	if version == "" {
+		version = Unreleased
+	}
+	c := &Changelog{Version: version, Date: date, RepoURL: repoURL, Sections: []ChangelogSection{}}
+
+	order := make([]string, 0, len(changelogSections)+len(types)+1)
+	titles := make(map[string]string)
+	for _, s := range changelogSections {
+		order = append(order, s.typ)
+		titles[s.typ] = s.title
+	}
+	for _, t := range types {
+		if _, ok := titles[t]; !ok {
+			order = append(order, t)
+			titles[t] = typeHeading(t)
+		}
+	}
+	titles[""] = "Other"
+
+	byType := make(map[string][]ChangelogEntry)
+	for _, t := range tasks {
+		typ := t.Type
+		if _, ok := titles[typ]; !ok {
+			// A type the workflow no longer lists.
+			order = append(order, typ)
+			titles[typ] = typeHeading(typ)
+		}
+		byType[typ] = append(byType[typ], newChangelogEntry(&t, repoURL))
+	}
+	order = append(order, "")
+	for _, typ := range order {
+		if entries := byType[typ]; len(entries) > 0 {
+			c.Sections = append(c.Sections, ChangelogSection{Title: titles[typ], Type: typ, Entries: entries})
+		}
+	}
+	return c
+}
+
+// typeHeading turns a task type such as "tech_debt" into "Tech debt".
+func typeHeading(typ string) string {
+	return strings.ToUpper(typ[:1]) + strings.ReplaceAll(typ[1:], "_", " ")
+}
+
+func newChangelogEntry(t *Task, repoURL string) ChangelogEntry {
+	e := ChangelogEntry{RefID: t.RefID, Title: t.Title, Milestone: t.Milestone, Commit: t.CommitHash}
+	if len(e.Commit) > 7 {
+		e.ShortCommit = e.Commit[:7]
+	} else {
+		e.ShortCommit = e.Commit
+	}
+	if repoURL != "" {
+		e.TaskURL = repoURL + "/search?type=commits&q=" + url.QueryEscape(t.RefID)
+		if e.Commit != "" {
+			e.CommitURL = repoURL + "/commit/" + e.Commit
+		}
+	}
+	return e
+}
+
+// Render executes tmpl, a text/template, with the changelog; an empty tmpl
+// means DefaultChangelogTemplate. The result ends in exactly one newline.
+func (c *Changelog) Render(tmpl string) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultChangelogTemplate
+	}
+	t, err := template.New("changelog").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing changelog template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, c); err != nil {
+		return "", fmt.Errorf("rendering changelog: %w", err)
+	}
+	return strings.TrimRight(buf.String(), "\n") + "\n", nil
+}
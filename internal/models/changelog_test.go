@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewChangelog(t *testing.T) {
+	tasks := []Task{
+		{RefID: "P-1", Title: "Fix crash", Type: "bug", CommitHash: "0123456789abcdef"},
+		{RefID: "P-2", Title: "Dark mode", Type: "feature"},
+		{RefID: "P-3", Title: "Untyped"},
+		{RefID: "P-4", Title: "Pay down", Type: "tech_debt"},
+		{RefID: "P-5", Title: "Export", Type: "feature"},
+	}
+	c := NewChangelog("", "", "https://github.com/o/r", tasks, []string{"bug", "feature", "tech_debt"})
+	if c.Version != Unreleased {
+		t.Errorf("expected an unnamed release to be %s, got %q", Unreleased, c.Version)
+	}
+	var titles []string
+	for _, s := range c.Sections {
+		titles = append(titles, s.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Added,Fixed,Tech debt,Other" {
+		t.Errorf("unexpected sections: %s", got)
+	}
+	if added := c.Sections[0].Entries; len(added) != 2 || added[0].RefID != "P-2" || added[1].RefID != "P-5" {
+		t.Errorf("expected features in task order, got %+v", added)
+	}
+	fix := c.Sections[1].Entries[0]
+	if fix.ShortCommit != "0123456" || fix.CommitURL != "https://github.com/o/r/commit/0123456789abcdef" || !strings.HasSuffix(fix.TaskURL, "q=P-1") {
+		t.Errorf("unexpected links: %+v", fix)
+	}
+
+	out, err := NewChangelog("1.2", "2026-10-01", "", tasks[:1], nil).Render("")
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "## [1.2] - 2026-10-01\n\n### Fixed\n\n- Fix crash (P-1, 0123456)\n"
+	if out != want {
+		t.Errorf("unexpected render:\n%s\nwant:\n%s", out, want)
+	}
+	if _, err := c.Render("{{.Nope}}"); err == nil {
+		t.Error("expected a template using an unknown field to fail")
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Commit message trailers that the commit-msg hook adds for each task a
+// message mentions, so the link survives in git history.
+const (
+	TrailerTask   = "Ghist-Task"
+	TrailerCloses = "Ghist-Closes"
+)
+
+// CommitRef is a task ref mentioned in a commit message.
+type CommitRef struct {
+	Ref    string // as written, e.g. "API-12"
+	ID     int64
+	Closes bool // some mention follows a closing keyword, as in "Fixes API-12"
+}
+
+// closingKeywords are the words that, before a ref, mark the task as done by
+// the commit. A colon may follow them, so "Fixes: API-12" and the
+// Ghist-Closes trailer count too.
+const closingKeywords = `close|closes|closed|fix|fixes|fixed|resolve|resolves|resolved`
+
+// ParseCommitRefs returns the task refs in a commit message, in the order
+// they are first mentioned, once each. Refs use one of prefixes or the
+// legacy GHST prefix and must be written in upper case; lines starting with
+// '#' are git comments and are skipped.
+func ParseCommitRefs(msg string, prefixes ...string) []CommitRef {
+	alts := []string{LegacyRefPrefix}
+	for _, p := range prefixes {
+		if p != "" && p != LegacyRefPrefix {
+			alts = append(alts, regexp.QuoteMeta(strings.ToUpper(p)))
+		}
+	}
+	re := regexp.MustCompile(`(?:\b(?i:(` + closingKeywords + `))\s*:?\s+)?\b((?:` + strings.Join(alts, "|") + `)-(\d+))\b`)
+
+	var refs []CommitRef
+	index := make(map[int64]int)
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, m := range re.FindAllStringSubmatch(line, -1) {
+			id, err := strconv.ParseInt(m[3], 10, 64)
+			if err != nil {
+				continue
+			}
+			closes := m[1] != ""
+			if i, ok := index[id]; ok {
+				refs[i].Closes = refs[i].Closes || closes
+				continue
+			}
+			index[id] = len(refs)
+			refs = append(refs, CommitRef{Ref: m[2], ID: id, Closes: closes})
+		}
+	}
+	return refs
+}
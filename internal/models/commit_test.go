@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestParseCommitRefs(t *testing.T) {
+	msg := `Fix login redirect (API-12)
+
+Fixes GHST-3, refs API-4 and api-5.
+Also closes: API-4. Not XAPI-6 or API-7a.
+# Mentioning API-8 in a comment does nothing.
+
+Ghist-Closes: API-9
+`
+	refs := ParseCommitRefs(msg, "API")
+	want := []CommitRef{
+		{Ref: "API-12", ID: 12},
+		{Ref: "GHST-3", ID: 3, Closes: true},
+		{Ref: "API-4", ID: 4, Closes: true},
+		{Ref: "API-9", ID: 9, Closes: true},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("expected %d refs, got %+v", len(want), refs)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("ref %d: expected %+v, got %+v", i, want[i], refs[i])
+		}
+	}
+	if refs := ParseCommitRefs("Bump deps"); len(refs) != 0 {
+		t.Errorf("expected no refs, got %+v", refs)
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NormalizeLabels lower-cases and trims labels, drops duplicates and returns
+// them sorted. Labels are single words: an empty label or one containing
+// whitespace or a comma is an error.
+func NormalizeLabels(labels []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, raw := range labels {
+		l := strings.ToLower(strings.TrimSpace(raw))
+		if l == "" || strings.ContainsAny(l, " \t\n,") {
+			return nil, fmt.Errorf("invalid label %q: labels are single words", raw)
+		}
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// HasLabel reports whether t carries label.
+func (t *Task) HasLabel(label string) bool {
+	for _, l := range t.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelCount is the number of tasks carrying a label.
+type LabelCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
@@ -7,11 +7,22 @@ import (
 	"time"
 )
 
-// ParseTaskID accepts a bare numeric ID ("19") or a prefixed ref ("GHST-19")
-// and returns the numeric task ID.
-func ParseTaskID(raw string) (int64, error) {
-	s := strings.TrimSpace(raw)
-	s = strings.TrimPrefix(strings.ToUpper(s), "GHST-")
+// LegacyRefPrefix is the task reference prefix used before prefixes became
+// configurable. It is always accepted by ParseTaskID so old refs in commit
+// messages and notes keep resolving.
+const LegacyRefPrefix = "GHST"
+
+// ParseTaskID accepts a bare numeric ID ("19") or a prefixed ref ("GHST-19",
+// or "API-19" when "API" is one of prefixes) and returns the numeric task ID.
+func ParseTaskID(raw string, prefixes ...string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, strings.ToUpper(p)+"-"); ok && p != "" {
+			s = rest
+			break
+		}
+	}
+	s = strings.TrimPrefix(s, LegacyRefPrefix+"-")
 	id, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
 		return 0, fmt.Errorf("invalid task id: %s", raw)
@@ -19,33 +30,147 @@ func ParseTaskID(raw string) (int64, error) {
 	return id, nil
 }
 
+// NormalizeRefPrefix upper-cases a task reference prefix and drops a trailing
+// dash, so "api-" and "API" are the same prefix. It returns an error unless
+// the result is 1–10 letters and digits starting with a letter.
+func NormalizeRefPrefix(raw string) (string, error) {
+	p := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(raw)), "-")
+	if p == "" || len(p) > 10 {
+		return "", fmt.Errorf("invalid ref prefix %q: must be 1-10 characters", raw)
+	}
+	for i, r := range p {
+		switch {
+		case r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return "", fmt.Errorf("invalid ref prefix %q: use letters and digits, starting with a letter", raw)
+		}
+	}
+	return p, nil
+}
+
+// FormatRef returns the display reference for a task ID, e.g. "API-19".
+func FormatRef(prefix string, id int64) string {
+	return fmt.Sprintf("%s-%d", prefix, id)
+}
+
 type Task struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Plan        string    `json:"plan"`
-	Status      string    `json:"status"`
-	Milestone   string    `json:"milestone"`
-	CommitHash  string    `json:"commit_hash"`
-	Priority    string    `json:"priority"`
-	Type        string    `json:"type"`
-	RefID       string    `json:"ref_id"`
-	LegacyID    string    `json:"legacy_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64    `json:"id"`
+	UID         string   `json:"uid,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Plan        string   `json:"plan"`
+	Status      string   `json:"status"`
+	Milestone   string   `json:"milestone"`
+	CommitHash  string   `json:"commit_hash"`
+	Priority    string   `json:"priority"`
+	Type        string   `json:"type"`
+	RefID       string   `json:"ref_id"`
+	LegacyID    string   `json:"legacy_id"`
+	Labels      []string `json:"labels,omitempty"`
+	Assignee    string   `json:"assignee,omitempty"`
+	ParentID    *int64   `json:"parent_id,omitempty"`
+	BlockedBy   []int64  `json:"blocked_by,omitempty"`
+	Blocks      []int64  `json:"blocks,omitempty"`
+	Archived    bool     `json:"archived,omitempty"` // hidden from listings and context, history kept
+	Revision    int64    `json:"revision"`           // bumped on every write, for optimistic concurrency
+	// StartedAt is when the task first reached a started status and
+	// CompletedAt when it last reached a done status; see Workflow.IsStarted.
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type Event struct {
 	ID        int64     `json:"id"`
+	UID       string    `json:"uid,omitempty"`
 	Type      string    `json:"type"`
 	Message   string    `json:"message"`
 	Metadata  string    `json:"metadata"`
 	TaskID    *int64    `json:"task_id"`
+	TaskUID   string    `json:"task_uid,omitempty"`
+	Actor     string    `json:"actor,omitempty"` // who or what wrote it; see ActorAgentPrefix
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ActorAgentPrefix marks an actor as an AI agent session rather than a
+// person, e.g. "agent:claude-code". People are recorded as their git
+// identity, "Name <email>".
+const ActorAgentPrefix = "agent:"
+
+// IsAgentActor reports whether actor names an AI agent.
+func IsAgentActor(actor string) bool {
+	return strings.HasPrefix(actor, ActorAgentPrefix)
+}
+
+// PlanRevision is one saved version of a task's plan. Rev numbers start at
+// 1 and follow creation order.
+type PlanRevision struct {
+	Rev       int       `json:"rev"`
+	UID       string    `json:"uid"`
+	TaskID    int64     `json:"task_id"`
+	Plan      string    `json:"plan"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Audit event types emitted automatically when a task is created or its
+// fields change.
+const (
+	EventTaskCreated            = "task.created"
+	EventTaskTitleChanged       = "task.title_changed"
+	EventTaskDescriptionChanged = "task.description_changed"
+	EventTaskPlanUpdated        = "task.plan_updated"
+	EventTaskStatusChanged      = "task.status_changed"
+	EventTaskMilestoneChanged   = "task.milestone_changed"
+	EventTaskCommitLinked       = "task.commit_linked"
+	EventTaskPriorityChanged    = "task.priority_changed"
+	EventTaskTypeChanged        = "task.type_changed"
+	EventTaskLegacyIDChanged    = "task.legacy_id_changed"
+	EventTaskParentChanged      = "task.parent_changed"
+	EventTaskLabelsChanged      = "task.labels_changed"
+	EventTaskArchivedChanged    = "task.archived_changed"
+	EventTaskAssigneeChanged    = "task.assignee_changed"
+	EventTaskLinked             = "task.linked"
+	EventTaskUnlinked           = "task.unlinked"
+)
+
+// Audit event types emitted automatically when opportunities and milestones
+// are written, so that who changed them is on record.
+const (
+	EventOpportunityCreated = "opportunity.created"
+	EventOpportunityUpdated = "opportunity.updated"
+	EventOpportunityDeleted = "opportunity.deleted"
+	EventMilestoneCreated   = "milestone.created"
+	EventMilestoneUpdated   = "milestone.updated"
+	EventMilestoneDeleted   = "milestone.deleted"
+)
+
+// IsAuditEvent reports whether an event type was emitted automatically by a
+// change to a task, opportunity or milestone rather than logged explicitly.
+func IsAuditEvent(typ string) bool {
+	return strings.HasPrefix(typ, "task.") || strings.HasPrefix(typ, "opportunity.") || strings.HasPrefix(typ, "milestone.")
+}
+
+// RecordChange is the metadata stored on opportunity and milestone audit
+// events: which record changed.
+type RecordChange struct {
+	ID   int64  `json:"id"`
+	UID  string `json:"uid,omitempty"`
+	Name string `json:"name"`
+}
+
+// FieldChange is the metadata stored on audit events: which task field
+// changed and its values before and after.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
 type Opportunity struct {
 	ID        int64     `json:"id"`
+	UID       string    `json:"uid,omitempty"`
 	Name      string    `json:"name"`
 	Notes     string    `json:"notes"`
 	CreatedAt time.Time `json:"created_at"`
@@ -65,8 +190,45 @@ type StatusSummary struct {
 	RecentEvents   []Event          `json:"recent_events"`
 }
 
+// MilestoneInfo is a milestone's progress, counted over leaf tasks, with the
+// dates and state from its record if it has one.
 type MilestoneInfo struct {
-	Name  string `json:"name"`
-	Total int    `json:"total"`
-	Done  int    `json:"done"`
+	Name       string `json:"name"`
+	Total      int    `json:"total"`
+	Done       int    `json:"done"`
+	State      string `json:"state"`
+	StartDate  string `json:"start_date,omitempty"`
+	TargetDate string `json:"target_date,omitempty"`
+	Overdue    bool   `json:"overdue,omitempty"`
+}
+
+// Milestone states.
+const (
+	MilestoneOpen   = "open"
+	MilestoneClosed = "closed"
+)
+
+// DateLayout is the format of milestone start and target dates.
+const DateLayout = "2006-01-02"
+
+// Milestone is the record behind a milestone name. Tasks refer to milestones
+// by name; a name used by tasks but with no record is an open milestone with
+// no dates.
+type Milestone struct {
+	ID          int64      `json:"id"`
+	UID         string     `json:"uid,omitempty"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	StartDate   string     `json:"start_date,omitempty"`  // YYYY-MM-DD
+	TargetDate  string     `json:"target_date,omitempty"` // YYYY-MM-DD
+	State       string     `json:"state"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Overdue reports whether m is still open after its target date, as of the
+// local calendar day of now.
+func (m *Milestone) Overdue(now time.Time) bool {
+	return m.State != MilestoneClosed && m.TargetDate != "" && m.TargetDate < now.Format(DateLayout)
 }
@@ -33,3 +33,58 @@ func TestParseTaskID(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTaskIDWithPrefix(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"API-19", 19, false},
+		{"api-19", 19, false},
+		{"WEB2-3", 3, false},
+		{"GHST-19", 19, false},
+		{"19", 19, false},
+		{"FOO-19", 0, true},
+		{"API-", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTaskID(tt.input, "API", "WEB2")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTaskID(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTaskID(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRefPrefix(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"API", "API", false},
+		{"api-", "API", false},
+		{" Web2 ", "WEB2", false},
+		{"", "", true},
+		{"-", "", true},
+		{"2FA", "", true},
+		{"MY-APP", "", true},
+		{"ABCDEFGHIJK", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeRefPrefix(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeRefPrefix(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeRefPrefix(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
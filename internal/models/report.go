@@ -0,0 +1,127 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Burndown is a milestone's remaining work day by day, counted over leaf
+// tasks in the milestone as it stands today.
+type Burndown struct {
+	Milestone  string        `json:"milestone"`
+	StartDate  string        `json:"start_date"`
+	TargetDate string        `json:"target_date,omitempty"`
+	Days       []BurndownDay `json:"days"`
+}
+
+// BurndownDay is the state of a milestone at the end of one day.
+type BurndownDay struct {
+	Date      string `json:"date"`
+	Scope     int    `json:"scope"` // tasks created by then
+	Done      int    `json:"done"`
+	Remaining int    `json:"remaining"`
+	// Ideal is the remaining work on a straight line from the start date
+	// to the target date, if the milestone has one.
+	Ideal *float64 `json:"ideal,omitempty"`
+}
+
+// ThroughputWeek counts the tasks finished in one week.
+type ThroughputWeek struct {
+	Week string `json:"week"` // the Monday it starts on, YYYY-MM-DD
+	Done int    `json:"done"`
+}
+
+// TaskDuration is how long one finished task took, from Start to End.
+type TaskDuration struct {
+	RefID string    `json:"ref_id"`
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  float64   `json:"days"`
+}
+
+// DurationReport summarises lead or cycle times over finished tasks.
+type DurationReport struct {
+	Tasks      []TaskDuration `json:"tasks"`
+	Count      int            `json:"count"`
+	MeanDays   float64        `json:"mean_days"`
+	MedianDays float64        `json:"median_days"`
+	P85Days    float64        `json:"p85_days"`
+}
+
+// NewDurationReport summarises tasks, which it orders by when they ended.
+func NewDurationReport(tasks []TaskDuration) *DurationReport {
+	r := &DurationReport{Tasks: tasks, Count: len(tasks)}
+	if r.Tasks == nil {
+		r.Tasks = []TaskDuration{}
+	}
+	if len(tasks) == 0 {
+		return r
+	}
+	sort.SliceStable(r.Tasks, func(i, j int) bool { return r.Tasks[i].End.Before(r.Tasks[j].End) })
+	days := make([]float64, len(tasks))
+	sum := 0.0
+	for i, t := range tasks {
+		days[i] = t.Days
+		sum += t.Days
+	}
+	sort.Float64s(days)
+	r.MeanDays = roundDays(sum / float64(len(days)))
+	r.MedianDays = percentile(days, 50)
+	r.P85Days = percentile(days, 85)
+	return r
+}
+
+// DaysBetween is the time from start to end in days, to two decimals.
+func DaysBetween(start, end time.Time) float64 {
+	return roundDays(end.Sub(start).Hours() / 24)
+}
+
+// percentile interpolates the p-th percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return roundDays(sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo)))
+}
+
+func roundDays(d float64) float64 {
+	return math.Round(d*100) / 100
+}
+
+// CSV returns the burndown as CSV records, header first.
+func (b *Burndown) CSV() [][]string {
+	rows := [][]string{{"milestone", "date", "scope", "done", "remaining", "ideal"}}
+	for _, d := range b.Days {
+		ideal := ""
+		if d.Ideal != nil {
+			ideal = formatDays(*d.Ideal)
+		}
+		rows = append(rows, []string{b.Milestone, d.Date, strconv.Itoa(d.Scope), strconv.Itoa(d.Done), strconv.Itoa(d.Remaining), ideal})
+	}
+	return rows
+}
+
+// ThroughputCSV returns weekly throughput as CSV records, header first.
+func ThroughputCSV(weeks []ThroughputWeek) [][]string {
+	rows := [][]string{{"week", "done"}}
+	for _, w := range weeks {
+		rows = append(rows, []string{w.Week, strconv.Itoa(w.Done)})
+	}
+	return rows
+}
+
+// CSV returns one record per task, header first.
+func (r *DurationReport) CSV() [][]string {
+	rows := [][]string{{"ref_id", "title", "start", "end", "days"}}
+	for _, t := range r.Tasks {
+		rows = append(rows, []string{t.RefID, t.Title, t.Start.Format(time.RFC3339), t.End.Format(time.RFC3339), formatDays(t.Days)})
+	}
+	return rows
+}
+
+func formatDays(d float64) string {
+	return strconv.FormatFloat(d, 'f', 2, 64)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestNewDurationReport(t *testing.T) {
+	var tasks []TaskDuration
+	for _, d := range []float64{4, 1, 2, 10} {
+		tasks = append(tasks, TaskDuration{Days: d})
+	}
+	r := NewDurationReport(tasks)
+	if r.Count != 4 || r.MeanDays != 4.25 || r.MedianDays != 3 || r.P85Days != 7.3 {
+		t.Errorf("unexpected summary: %+v", r)
+	}
+	if empty := NewDurationReport(nil); empty.Count != 0 || empty.Tasks == nil {
+		t.Errorf("expected an empty report with an empty task list, got %+v", empty)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+// EventRetention controls automatic event compaction. It is stored under
+// "event_retention" in .ghist/settings.json.
+type EventRetention struct {
+	// ArchiveAfterDays moves events older than this many days into the
+	// monthly archive. 0 turns automatic compaction off; "ghist events
+	// compact" then uses DefaultArchiveAfterDays.
+	ArchiveAfterDays int `json:"archive_after_days"`
+	// KeepTypes lists event types that stay in events/ when they are linked
+	// to a task. Unset means DefaultKeepTypes; an empty list keeps nothing.
+	KeepTypes []string `json:"keep_types"`
+}
+
+// DefaultArchiveAfterDays is the compaction age used when none is configured.
+const DefaultArchiveAfterDays = 90
+
+// DefaultKeepTypes are kept by compaction unless the project says otherwise:
+// decisions tied to a task are what agents most often need to look back on.
+var DefaultKeepTypes = []string{"decision"}
+
+// Keeps reports whether compaction should leave e in place.
+func (r EventRetention) Keeps(e *Event) bool {
+	if e.TaskID == nil {
+		return false
+	}
+	keep := r.KeepTypes
+	if keep == nil {
+		keep = DefaultKeepTypes
+	}
+	for _, t := range keep {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
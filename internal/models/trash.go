@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RecordRef identifies a task or event by display ID and, when it has one,
+// UID. The UID survives a merge-fix renumbering; the ID is a fallback for
+// legacy records.
+type RecordRef struct {
+	ID  int64  `json:"id"`
+	UID string `json:"uid,omitempty"`
+}
+
+// TrashedTask is a deleted task together with the links its deletion cut, so
+// that restoring it can put them back.
+type TrashedTask struct {
+	Task      Task      `json:"task"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// UnlinkedEvents had their task_id cleared by the deletion.
+	UnlinkedEvents []RecordRef `json:"unlinked_events,omitempty"`
+	// Children were subtasks of the task and moved up to its parent.
+	Children []RecordRef `json:"children,omitempty"`
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Workflow defines the statuses, priorities and types a project allows. It is
+// stored under "workflow" in .ghist/settings.json; any part left out falls
+// back to DefaultWorkflow.
+type Workflow struct {
+	// Statuses in board order. The first is the status new tasks start in.
+	Statuses []WorkflowStatus `json:"statuses"`
+	// Transitions maps a status to the statuses it may move to. Statuses
+	// without an entry (or an empty map) may move anywhere.
+	Transitions map[string][]string `json:"transitions,omitempty"`
+	// Priorities from lowest to highest.
+	Priorities []string `json:"priorities"`
+	Types      []string `json:"types"`
+}
+
+// WorkflowStatus is one allowed task status.
+type WorkflowStatus struct {
+	Name string `json:"name"`
+	// Done marks statuses that count as finished work in progress summaries.
+	Done bool `json:"done,omitempty"`
+	// Started marks statuses where work is under way: cycle time runs from
+	// the first time a task reaches one. See IsStarted for the default.
+	Started bool `json:"started,omitempty"`
+}
+
+// DefaultWorkflow returns the workflow used when settings.json defines none.
+func DefaultWorkflow() Workflow {
+	return Workflow{
+		Statuses: []WorkflowStatus{
+			{Name: "todo"},
+			{Name: "in_planning"},
+			{Name: "in_progress", Started: true},
+			{Name: "done", Done: true},
+			{Name: "blocked"},
+		},
+		Priorities: []string{"low", "medium", "high", "urgent"},
+		Types:      []string{"bug", "feature", "improvement", "chore"},
+	}
+}
+
+// WithDefaults fills any part of w that is unset from DefaultWorkflow.
+func (w Workflow) WithDefaults() Workflow {
+	def := DefaultWorkflow()
+	if len(w.Statuses) == 0 {
+		w.Statuses = def.Statuses
+	}
+	if len(w.Priorities) == 0 {
+		w.Priorities = def.Priorities
+	}
+	if len(w.Types) == 0 {
+		w.Types = def.Types
+	}
+	return w
+}
+
+// StatusNames returns the allowed statuses in board order.
+func (w Workflow) StatusNames() []string {
+	names := make([]string, len(w.Statuses))
+	for i, st := range w.Statuses {
+		names[i] = st.Name
+	}
+	return names
+}
+
+// InitialStatus is the status new tasks get when none is given.
+func (w Workflow) InitialStatus() string {
+	if len(w.Statuses) == 0 {
+		return "todo"
+	}
+	return w.Statuses[0].Name
+}
+
+// DoneStatus is the status a task moves to when something else finishes it,
+// such as a commit that fixes it: the first done status.
+func (w Workflow) DoneStatus() string {
+	for _, st := range w.Statuses {
+		if st.Done {
+			return st.Name
+		}
+	}
+	return "done"
+}
+
+// HasStatus reports whether status is defined by the workflow.
+func (w Workflow) HasStatus(status string) bool {
+	return slices.Contains(w.StatusNames(), status)
+}
+
+// IsDone reports whether status counts as finished work.
+func (w Workflow) IsDone(status string) bool {
+	for _, st := range w.Statuses {
+		if st.Name == status {
+			return st.Done
+		}
+	}
+	return false
+}
+
+// IsStarted reports whether status means work on a task is under way. A
+// workflow that marks no status Started counts "in_progress" if it has one,
+// or else every status but the initial one and the done ones.
+func (w Workflow) IsStarted(status string) bool {
+	marked := false
+	for _, st := range w.Statuses {
+		if st.Started {
+			marked = true
+			if st.Name == status {
+				return true
+			}
+		}
+	}
+	switch {
+	case marked:
+		return false
+	case w.HasStatus("in_progress"):
+		return status == "in_progress"
+	default:
+		return w.HasStatus(status) && status != w.InitialStatus() && !w.IsDone(status)
+	}
+}
+
+// PriorityRank returns the position of priority from lowest (0) to highest,
+// or -1 for an empty or unknown priority.
+func (w Workflow) PriorityRank(priority string) int {
+	return slices.Index(w.Priorities, priority)
+}
+
+// ValidateStatus checks that status is allowed.
+func (w Workflow) ValidateStatus(status string) error {
+	if !w.HasStatus(status) {
+		return fmt.Errorf("unknown status %q (allowed: %s)", status, strings.Join(w.StatusNames(), ", "))
+	}
+	return nil
+}
+
+// ValidateTransition checks that a task may move from one status to another.
+func (w Workflow) ValidateTransition(from, to string) error {
+	if err := w.ValidateStatus(to); err != nil {
+		return err
+	}
+	allowed, ok := w.Transitions[from]
+	if from == to || !ok || slices.Contains(allowed, to) {
+		return nil
+	}
+	return fmt.Errorf("cannot move from %q to %q (allowed: %s)", from, to, strings.Join(allowed, ", "))
+}
+
+// ValidatePriority checks that priority is empty or allowed.
+func (w Workflow) ValidatePriority(priority string) error {
+	if priority != "" && !slices.Contains(w.Priorities, priority) {
+		return fmt.Errorf("unknown priority %q (allowed: %s)", priority, strings.Join(w.Priorities, ", "))
+	}
+	return nil
+}
+
+// ValidateType checks that a task type is empty or allowed.
+func (w Workflow) ValidateType(typ string) error {
+	if typ != "" && !slices.Contains(w.Types, typ) {
+		return fmt.Errorf("unknown type %q (allowed: %s)", typ, strings.Join(w.Types, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestWorkflowValidation(t *testing.T) {
+	w := Workflow{
+		Statuses: []WorkflowStatus{{Name: "open"}, {Name: "review"}, {Name: "closed", Done: true}},
+		Transitions: map[string][]string{
+			"open":   {"review"},
+			"review": {"open", "closed"},
+		},
+	}.WithDefaults()
+
+	if w.InitialStatus() != "open" {
+		t.Errorf("expected initial status 'open', got %q", w.InitialStatus())
+	}
+	if !w.IsDone("closed") || w.IsDone("open") {
+		t.Error("expected only 'closed' to count as done")
+	}
+	if err := w.ValidateStatus("in-progress"); err == nil {
+		t.Error("expected error for unknown status")
+	}
+	if err := w.ValidateTransition("open", "review"); err != nil {
+		t.Errorf("expected open → review to be allowed: %v", err)
+	}
+	if err := w.ValidateTransition("open", "closed"); err == nil {
+		t.Error("expected open → closed to be rejected")
+	}
+	if err := w.ValidateTransition("closed", "open"); err != nil {
+		t.Errorf("expected statuses without transitions to move freely: %v", err)
+	}
+	if err := w.ValidatePriority("urgent"); err != nil {
+		t.Errorf("expected default priorities to apply: %v", err)
+	}
+	if err := w.ValidatePriority("critical"); err == nil {
+		t.Error("expected error for unknown priority")
+	}
+	if err := w.ValidateType(""); err != nil {
+		t.Errorf("expected empty type to be allowed: %v", err)
+	}
+	if w.PriorityRank("high") <= w.PriorityRank("low") {
+		t.Error("expected high to rank above low")
+	}
+}
+
+func TestIsStarted(t *testing.T) {
+	def := DefaultWorkflow()
+	if !def.IsStarted("in_progress") || def.IsStarted("in_planning") || def.IsStarted("done") {
+		t.Error("expected only in_progress to count as started by default")
+	}
+	// A saved workflow without Started marks still counts in_progress.
+	saved := Workflow{Statuses: []WorkflowStatus{{Name: "todo"}, {Name: "in_planning"}, {Name: "in_progress"}, {Name: "done", Done: true}}}
+	if !saved.IsStarted("in_progress") || saved.IsStarted("in_planning") {
+		t.Error("expected in_progress to count as started without marks")
+	}
+	custom := Workflow{Statuses: []WorkflowStatus{{Name: "open"}, {Name: "review"}, {Name: "closed", Done: true}}}
+	if !custom.IsStarted("review") || custom.IsStarted("open") || custom.IsStarted("closed") {
+		t.Error("expected every status but the first and the done ones to count as started")
+	}
+	marked := Workflow{Statuses: []WorkflowStatus{{Name: "open"}, {Name: "doing", Started: true}, {Name: "review"}, {Name: "closed", Done: true}}}
+	if !marked.IsStarted("doing") || marked.IsStarted("review") {
+		t.Error("expected only marked statuses to count as started")
+	}
+}
@@ -1,32 +1,45 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/search"
 )
 
 func PrintTaskTable(tasks []models.Task) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "REF\tTITLE\tSTATUS\tPRIORITY\tTYPE\tMILESTONE\tPLAN")
-	fmt.Fprintln(w, "---\t-----\t------\t--------\t----\t---------\t----")
+	fmt.Fprintln(w, "REF\tTITLE\tSTATUS\tPRIORITY\tTYPE\tMILESTONE\tLABELS\tPLAN")
+	fmt.Fprintln(w, "---\t-----\t------\t--------\t----\t---------\t------\t----")
 	for _, t := range tasks {
 		plan := ""
 		if t.Plan != "" {
 			plan = "yes"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.RefID, t.Title, StatusLabel(t.Status), t.Priority, t.Type, t.Milestone, plan)
+		status := t.Status
+		if t.Archived {
+			status += " (archived)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.RefID, t.Title, status, t.Priority, t.Type, t.Milestone, strings.Join(t.Labels, ","), plan)
 	}
 	w.Flush()
 }
 
-func PrintTaskDetail(t *models.Task, events []models.Event) {
+// PrintTaskDetail prints a task, its subtask tree and its events. subtasks
+// holds every descendant of t; it is arranged into a tree by ParentID.
+func PrintTaskDetail(t *models.Task, events []models.Event, subtasks []models.Task) {
 	fmt.Printf("Task %s\n", t.RefID)
 	fmt.Printf("  Title:       %s\n", t.Title)
-	fmt.Printf("  Status:      %s\n", StatusLabel(t.Status))
+	if t.Archived {
+		fmt.Printf("  Status:      %s (archived)\n", t.Status)
+	} else {
+		fmt.Printf("  Status:      %s\n", t.Status)
+	}
 	if t.Priority != "" {
 		fmt.Printf("  Priority:    %s\n", t.Priority)
 	}
@@ -39,6 +52,12 @@ func PrintTaskDetail(t *models.Task, events []models.Event) {
 	if t.Milestone != "" {
 		fmt.Printf("  Milestone:   %s\n", t.Milestone)
 	}
+	if len(t.Labels) > 0 {
+		fmt.Printf("  Labels:      %s\n", strings.Join(t.Labels, ", "))
+	}
+	if t.Assignee != "" {
+		fmt.Printf("  Assignee:    %s\n", t.Assignee)
+	}
 	if t.Plan != "" {
 		fmt.Printf("  Plan:\n")
 		for _, line := range strings.Split(t.Plan, "\n") {
@@ -51,31 +70,288 @@ func PrintTaskDetail(t *models.Task, events []models.Event) {
 	if t.LegacyID != "" {
 		fmt.Printf("  Legacy ID:   %s\n", t.LegacyID)
 	}
+	if t.ParentID != nil {
+		fmt.Printf("  Parent:      #%d\n", *t.ParentID)
+	}
+	if len(t.BlockedBy) > 0 {
+		fmt.Printf("  Blocked by:  %s\n", formatIDs(t.BlockedBy))
+	}
+	if len(t.Blocks) > 0 {
+		fmt.Printf("  Blocks:      %s\n", formatIDs(t.Blocks))
+	}
 	fmt.Printf("  Created:     %s\n", t.CreatedAt.Format("2006-01-02 15:04"))
 	fmt.Printf("  Updated:     %s\n", t.UpdatedAt.Format("2006-01-02 15:04"))
+	fmt.Printf("  Revision:    %d\n", t.Revision)
+
+	if len(subtasks) > 0 {
+		children := make(map[int64][]models.Task)
+		for _, c := range subtasks {
+			if c.ParentID != nil {
+				children[*c.ParentID] = append(children[*c.ParentID], c)
+			}
+		}
+		done, total := 0, 0
+		for _, c := range subtasks {
+			if len(children[c.ID]) == 0 {
+				total++
+				if c.Status == "done" {
+					done++
+				}
+			}
+		}
+		fmt.Println()
+		fmt.Printf("  Subtasks (%d/%d done):\n", done, total)
+		printSubtaskTree(children, t.ID, "    ")
+	}
 
 	if len(events) > 0 {
 		fmt.Println()
 		fmt.Println("  Events:")
 		for _, e := range events {
-			fmt.Printf("    [%s] %s (%s)\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Message, e.Type)
+			fmt.Printf("    [%s] %s (%s)%s\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Message, e.Type, byActor(e.Actor))
+		}
+	}
+}
+
+func PrintPlanRevisions(revs []models.PlanRevision) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REV\tSAVED\tLINES\tFIRST LINE")
+	fmt.Fprintln(w, "---\t-----\t-----\t----------")
+	for _, r := range revs {
+		first, _, _ := strings.Cut(strings.TrimSpace(r.Plan), "\n")
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", r.Rev, r.CreatedAt.Format("2006-01-02 15:04"), lineCount(r.Plan), first)
+	}
+	w.Flush()
+}
+
+// PrintTaskHistory prints a task's audit events as a timeline, oldest first.
+func PrintTaskHistory(t *models.Task, history []models.Event) {
+	fmt.Printf("History for %s: %s\n", t.RefID, t.Title)
+	if len(history) == 0 {
+		fmt.Println("  No recorded changes.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range history {
+		var c models.FieldChange
+		if err := json.Unmarshal([]byte(e.Metadata), &c); err != nil || c.Field == "" {
+			fmt.Fprintf(w, "  %s\t%s\t%s%s\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Type, e.Message, byActor(e.Actor))
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s%s\n", e.CreatedAt.Format("2006-01-02 15:04"), c.Field, describeChange(c), byActor(e.Actor))
+	}
+	w.Flush()
+}
+
+// byActor is the " by <actor>" suffix for an event line, or "" if the event
+// predates actors.
+func byActor(actor string) string {
+	if actor == "" {
+		return ""
+	}
+	return " by " + actor
+}
+
+// describeChange summarises a field change for the history timeline.
+func describeChange(c models.FieldChange) string {
+	switch c.Field {
+	case "plan", "description":
+		switch {
+		case c.Old == "":
+			return fmt.Sprintf("written (%d lines)", lineCount(c.New))
+		case c.New == "":
+			return "cleared"
+		default:
+			return fmt.Sprintf("rewritten (%d → %d lines)", lineCount(c.Old), lineCount(c.New))
 		}
 	}
+	old, cur := c.Old, c.New
+	if old == "" {
+		old = "(none)"
+	}
+	if cur == "" {
+		cur = "(none)"
+	}
+	return old + " → " + cur
+}
+
+func lineCount(s string) int {
+	return len(strings.Split(strings.TrimRight(s, "\n"), "\n"))
+}
+
+func printSubtaskTree(children map[int64][]models.Task, parent int64, indent string) {
+	for _, c := range children[parent] {
+		fmt.Printf("%s%s  [%s]  %s\n", indent, c.RefID, c.Status, c.Title)
+		printSubtaskTree(children, c.ID, indent+"  ")
+	}
 }
 
-func StatusLabel(status string) string {
-	switch status {
-	case "todo":
-		return "todo"
-	case "in_planning":
-		return "in_planning"
-	case "in_progress":
-		return "in_progress"
-	case "done":
-		return "done"
-	case "blocked":
-		return "blocked"
-	default:
-		return status
+func formatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("#%d", id)
 	}
+	return strings.Join(parts, ", ")
+}
+
+func PrintOpportunityTable(opps []models.Opportunity) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tNOTES\tUPDATED")
+	fmt.Fprintln(w, "--\t----\t-----\t-------")
+	for _, o := range opps {
+		notes := o.Notes
+		if i := strings.IndexByte(notes, '\n'); i >= 0 {
+			notes = notes[:i] + " …"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", o.ID, o.Name, notes, o.UpdatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func PrintOpportunityDetail(o *models.Opportunity) {
+	fmt.Printf("Opportunity #%d\n", o.ID)
+	fmt.Printf("  Name:        %s\n", o.Name)
+	if o.Notes != "" {
+		fmt.Printf("  Notes:\n")
+		for _, line := range strings.Split(o.Notes, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	fmt.Printf("  Created:     %s\n", o.CreatedAt.Format("2006-01-02 15:04"))
+	fmt.Printf("  Updated:     %s\n", o.UpdatedAt.Format("2006-01-02 15:04"))
+}
+
+func PrintMilestoneTable(milestones []models.MilestoneInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tSTART\tTARGET\tDONE")
+	fmt.Fprintln(w, "----\t-----\t-----\t------\t----")
+	for _, m := range milestones {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\n", m.Name, m.State, m.StartDate, targetDate(m), m.Done, m.Total)
+	}
+	w.Flush()
+}
+
+// PrintMilestoneDetail prints a milestone with the tasks that name it.
+func PrintMilestoneDetail(m models.MilestoneInfo, description string, closedAt *time.Time, tasks []models.Task) {
+	fmt.Printf("Milestone %s\n", m.Name)
+	if closedAt != nil {
+		fmt.Printf("  State:       %s (%s)\n", m.State, closedAt.Local().Format("2006-01-02"))
+	} else {
+		fmt.Printf("  State:       %s\n", m.State)
+	}
+	if description != "" {
+		fmt.Printf("  Description: %s\n", description)
+	}
+	if m.StartDate != "" {
+		fmt.Printf("  Start:       %s\n", m.StartDate)
+	}
+	if m.TargetDate != "" {
+		fmt.Printf("  Target:      %s\n", targetDate(m))
+	}
+	fmt.Printf("  Progress:    %d/%d done\n", m.Done, m.Total)
+	if len(tasks) > 0 {
+		fmt.Println()
+		PrintTaskTable(tasks)
+	}
+}
+
+// targetDate is a milestone's target date, flagged when it has passed.
+func targetDate(m models.MilestoneInfo) string {
+	if m.Overdue {
+		return m.TargetDate + " (overdue)"
+	}
+	return m.TargetDate
+}
+
+// PrintBurndown prints a milestone's burndown, one row per day.
+func PrintBurndown(b *models.Burndown) {
+	fmt.Printf("Burndown for %s from %s", b.Milestone, b.StartDate)
+	if b.TargetDate != "" {
+		fmt.Printf(", target %s", b.TargetDate)
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tSCOPE\tDONE\tREMAINING\tIDEAL")
+	fmt.Fprintln(w, "----\t-----\t----\t---------\t-----")
+	for _, d := range b.Days {
+		ideal := ""
+		if d.Ideal != nil {
+			ideal = fmt.Sprintf("%.1f", *d.Ideal)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", d.Date, d.Scope, d.Done, d.Remaining, ideal)
+	}
+	w.Flush()
+}
+
+// PrintThroughput prints tasks finished per week with a bar for each.
+func PrintThroughput(weeks []models.ThroughputWeek) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WEEK OF\tDONE\t")
+	fmt.Fprintln(w, "-------\t----\t")
+	for _, wk := range weeks {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", wk.Week, wk.Done, strings.Repeat("#", wk.Done))
+	}
+	w.Flush()
+}
+
+// PrintDurationReport prints a lead or cycle time summary and the tasks
+// behind it. what names the span measured, e.g. "created → done".
+func PrintDurationReport(title, what string, r *models.DurationReport) {
+	if r.Count == 0 {
+		fmt.Printf("%s (%s): no finished tasks\n", title, what)
+		return
+	}
+	fmt.Printf("%s (%s) over %d task(s): mean %.1fd, median %.1fd, 85th percentile %.1fd\n\n", title, what, r.Count, r.MeanDays, r.MedianDays, r.P85Days)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REF\tTITLE\tDAYS\tFINISHED")
+	fmt.Fprintln(w, "---\t-----\t----\t--------")
+	for _, t := range r.Tasks {
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%s\n", t.RefID, t.Title, t.Days, t.End.Local().Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func PrintLabelTable(labels []models.LabelCount) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LABEL\tTASKS")
+	fmt.Fprintln(w, "-----\t-----")
+	for _, l := range labels {
+		fmt.Fprintf(w, "%s\t%d\n", l.Label, l.Count)
+	}
+	w.Flush()
+}
+
+// PrintTrashTable lists deleted tasks with when they were deleted and how
+// many event links their deletion cut.
+func PrintTrashTable(trashed []models.TrashedTask) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REF\tTITLE\tSTATUS\tDELETED\tEVENTS")
+	fmt.Fprintln(w, "---\t-----\t------\t-------\t------")
+	for _, t := range trashed {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", t.Task.RefID, t.Task.Title, t.Task.Status, t.DeletedAt.Local().Format("2006-01-02 15:04"), len(t.UnlinkedEvents))
+	}
+	w.Flush()
+}
+
+// PrintSearchResults prints search hits with their highlighted snippets.
+// Task refs are rendered with prefix.
+func PrintSearchResults(results []search.Result, prefix string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REF\tKIND\tFIELD\tMATCH")
+	fmt.Fprintln(w, "---\t----\t-----\t-----")
+	for _, r := range results {
+		ref := models.FormatRef(prefix, r.ID)
+		match := r.Snippet
+		if r.Kind == "event" {
+			ref = fmt.Sprintf("#%d", r.ID)
+			match = fmt.Sprintf("[%s] %s", r.Title, r.Snippet)
+			if r.TaskID != nil {
+				match += fmt.Sprintf(" (%s)", models.FormatRef(prefix, *r.TaskID))
+			}
+		} else if r.Field != "title" {
+			match = fmt.Sprintf("%s: %s", r.Title, r.Snippet)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ref, r.Kind, r.Field, match)
+	}
+	w.Flush()
 }
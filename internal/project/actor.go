@@ -0,0 +1,68 @@
+package project
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// ActorEnv names the environment variable that sets who ghist records as
+// making changes, overriding the git identity.
+const ActorEnv = "GHIST_ACTOR"
+
+// agentEnvs maps environment variables that AI coding agents set in the
+// shells they run to the name ghist records them under.
+var agentEnvs = []struct{ env, name string }{
+	{"CLAUDECODE", "claude-code"},
+	{"CURSOR_AGENT", "cursor"},
+	{"GEMINI_CLI", "gemini-cli"},
+}
+
+// Identity returns the person using ghist in the project at root: as if it
+// is non-empty (the --as flag), else $GHIST_ACTOR, else the git user as
+// "Name <email>". It is what "me" means in --assignee me. It returns "" if
+// none of them is set.
+func Identity(root, as string) string {
+	if as = strings.TrimSpace(as); as != "" {
+		return as
+	}
+	if env := strings.TrimSpace(os.Getenv(ActorEnv)); env != "" {
+		return env
+	}
+	name, email := gitConfig(root, "user.name"), gitConfig(root, "user.email")
+	switch {
+	case name != "" && email != "":
+		return name + " <" + email + ">"
+	case name != "":
+		return name
+	default:
+		return email
+	}
+}
+
+// ResolveActor returns who to record as making changes: the Identity, unless
+// it came from git and the command runs inside a known AI agent, in which
+// case the agent is recorded as "agent:<name>". An explicit --as or
+// $GHIST_ACTOR is always taken as given.
+func ResolveActor(root, as string) string {
+	if strings.TrimSpace(as) == "" && strings.TrimSpace(os.Getenv(ActorEnv)) == "" {
+		for _, a := range agentEnvs {
+			if os.Getenv(a.env) != "" {
+				return models.ActorAgentPrefix + a.name
+			}
+		}
+	}
+	return Identity(root, as)
+}
+
+func gitConfig(root, key string) string {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,77 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChangelogFile is the changelog that 'ghist changelog --prepend' writes to
+// by default, relative to the project root.
+const ChangelogFile = "CHANGELOG.md"
+
+const changelogHeader = `# Changelog
+
+All notable changes to this project are documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/).
+`
+
+// GitRefTime returns the commit time of ref (a tag, branch or commit) in the
+// git repository at root.
+func GitRefTime(root, ref string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%cI", ref, "--")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (YYYY-MM-DD) nor a git tag or commit", ref)
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+}
+
+// PrependChangelog inserts section, which starts with its "## " heading,
+// above the newest release in the changelog at path, below an
+// "## [Unreleased]" section if the file keeps one. A missing file is created
+// with a Keep a Changelog header. It refuses to add a release the file
+// already has a section for, so running a release twice does not duplicate
+// it.
+func PrependChangelog(path, section string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("reading %s: %w", filepath.Base(path), err)
+		}
+		return os.WriteFile(path, []byte(changelogHeader+"\n"+section), 0644)
+	}
+
+	heading, _, _ := strings.Cut(section, "\n")
+	release, _, _ := strings.Cut(heading, " - ")
+	lines := strings.SplitAfter(string(content), "\n")
+	at := len(lines)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == release || strings.HasPrefix(line, release+" - ") {
+			return fmt.Errorf("%s already has a %q section", filepath.Base(path), release)
+		}
+		if at == len(lines) && strings.HasPrefix(line, "## ") && !isUnreleasedHeading(line) {
+			at = i
+		}
+	}
+
+	before := strings.Join(lines[:at], "")
+	after := strings.Join(lines[at:], "")
+	if before != "" && !strings.HasSuffix(before, "\n\n") {
+		before = strings.TrimRight(before, "\n") + "\n\n"
+	}
+	if after != "" {
+		section += "\n"
+	}
+	return os.WriteFile(path, []byte(before+section+after), 0644)
+}
+
+func isUnreleasedHeading(line string) bool {
+	return strings.HasPrefix(strings.ToLower(line), "## [unreleased]") || strings.EqualFold(line, "## unreleased")
+}
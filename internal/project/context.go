@@ -10,7 +10,7 @@ import (
 )
 
 // UpdateContext reads current state from the store and writes current_context.json.
-func UpdateContext(root string, s *store.Store) error {
+func UpdateContext(root string, s store.Store) error {
 	tasks, err := s.ListTasks("", "", "", "")
 	if err != nil {
 		return fmt.Errorf("listing tasks: %w", err)
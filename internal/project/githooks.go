@@ -0,0 +1,164 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitHookMarker identifies the hook scripts ghist installed, so it never
+// overwrites or removes a hook it did not write.
+const gitHookMarker = "# Installed by ghist"
+
+// gitHookNames are the git hooks ghist installs. Each runs 'ghist hook
+// <name>'.
+var gitHookNames = []string{"commit-msg", "post-commit"}
+
+// gitHookScript is a hook that runs ghist, and does nothing if ghist is not
+// installed, so a commit never fails for want of it.
+func gitHookScript(ghist, command string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s: links commits to the tasks they mention (ghist git install-hooks).
+GHIST=%s
+command -v "$GHIST" >/dev/null 2>&1 || GHIST=ghist
+command -v "$GHIST" >/dev/null 2>&1 || exit 0
+exec "$GHIST" %s
+`, gitHookMarker, "'"+strings.ReplaceAll(ghist, "'", `'\''`)+"'", command)
+}
+
+// GitHooksDir returns the directory git runs hooks from in the repository at
+// root, honouring core.hooksPath and worktrees.
+func GitHooksDir(root string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", root)
+	}
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// InstallGitHooks writes the commit-msg and post-commit hooks into the
+// repository at root and returns their paths. With noClose, commits are
+// linked to the tasks they fix without moving them to done. A hook ghist did
+// not write is left alone, and reported as an error, unless force is set;
+// then it is kept as <hook>.pre-ghist.
+func InstallGitHooks(root string, force, noClose bool) ([]string, error) {
+	dir, err := GitHooksDir(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	// Use absolute path so the hook works from git GUIs with a bare PATH
+	ghist, err := exec.LookPath("ghist")
+	if err != nil {
+		ghist = "ghist"
+	}
+
+	commands := map[string]string{
+		"commit-msg":  `hook commit-msg "$1"`,
+		"post-commit": "hook post-commit",
+	}
+	if noClose {
+		commands["post-commit"] += " --no-close"
+	}
+
+	for _, name := range gitHookNames {
+		path := filepath.Join(dir, name)
+		if content, err := os.ReadFile(path); err == nil && !bytes.Contains(content, []byte(gitHookMarker)) && !force {
+			return nil, fmt.Errorf("%s already has a %s hook; add `ghist %s` to it, or rerun with --force to replace it", dir, name, commands[name])
+		}
+	}
+	var paths []string
+	for _, name := range gitHookNames {
+		path := filepath.Join(dir, name)
+		if content, err := os.ReadFile(path); err == nil && !bytes.Contains(content, []byte(gitHookMarker)) {
+			if err := os.Rename(path, path+".pre-ghist"); err != nil {
+				return paths, fmt.Errorf("backing up %s hook: %w", name, err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(gitHookScript(ghist, commands[name])), 0755); err != nil {
+			return paths, fmt.Errorf("writing %s hook: %w", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// UninstallGitHooks removes the hooks InstallGitHooks wrote, putting back
+// any hook it replaced, and returns their paths.
+func UninstallGitHooks(root string) ([]string, error) {
+	dir, err := GitHooksDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range gitHookNames {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil || !bytes.Contains(content, []byte(gitHookMarker)) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return paths, fmt.Errorf("removing %s hook: %w", name, err)
+		}
+		if _, err := os.Stat(path + ".pre-ghist"); err == nil {
+			if err := os.Rename(path+".pre-ghist", path); err != nil {
+				return paths, fmt.Errorf("restoring %s hook: %w", name, err)
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// GitHooksInstalled reports whether the post-commit hook that links commits
+// is installed in the repository at root.
+func GitHooksInstalled(root string) bool {
+	dir, err := GitHooksDir(root)
+	if err != nil {
+		return false
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "post-commit"))
+	return err == nil && bytes.Contains(content, []byte(gitHookMarker))
+}
+
+// AddCommitTrailers appends trailers ("Key: value") to the commit message in
+// file, skipping any the message already has.
+func AddCommitTrailers(root, file string, trailers []string) error {
+	if len(trailers) == 0 {
+		return nil
+	}
+	args := []string{"interpret-trailers", "--in-place", "--if-exists", "addIfDifferent"}
+	for _, t := range trailers {
+		args = append(args, "--trailer", t)
+	}
+	cmd := exec.Command("git", append(args, file)...)
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("adding trailers: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// LastCommit returns the hash and message of HEAD in the repository at root.
+func LastCommit(root string) (hash, message string, err error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%H%n%B")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("reading the last commit: %w", err)
+	}
+	hash, message, _ = strings.Cut(string(out), "\n")
+	return hash, message, nil
+}
@@ -37,7 +37,7 @@ This project uses [ghist](https://github.com/unnecessary-special-projects/ghist)
 
 ### Available Skills
 - ` + "`ghist skills show context-sync`" + ` — session start/end protocol
-- ` + "`ghist skills show task-workflow`" + ` — find → plan → execute → complete loop (statuses: todo, in_planning, in_progress, done, blocked)
+- ` + "`ghist skills show task-workflow`" + ` — find → plan → execute → complete loop (default statuses: todo, in_planning, in_progress, done, blocked; see ` + "`ghist workflow`" + `)
 - ` + "`ghist skills show auto-completion`" + ` — auto-detect task completion
 - ` + "`ghist skills show log-thinking`" + ` — log decisions and reasoning
 - ` + "`ghist skills show commit-link`" + ` — link git commits to tasks automatically
@@ -76,7 +76,7 @@ func Init(projectRoot string, stdin io.Reader) error {
 	return nil
 }
 
-// Refresh re-runs the setup steps (DB migration, context, CLAUDE.md injection)
+// Refresh re-runs the setup steps (schema migrations, context, CLAUDE.md injection)
 // and prompts for any new optional features not yet configured.
 func Refresh(projectRoot string, stdin io.Reader) error {
 	if err := setup(projectRoot); err != nil {
@@ -93,12 +93,19 @@ func setup(projectRoot string) error {
 		return fmt.Errorf("creating %s: %w", GhistDir, err)
 	}
 
-	// Open the store (also runs SQLite migration if needed).
+	// Open the store (also runs any pending schema migrations).
 	s, err := store.Open(ghistDir)
 	if err != nil {
 		return fmt.Errorf("initializing database: %w", err)
 	}
 	defer s.Close()
+	if ms := s.Migrations(); len(ms) > 0 {
+		for _, m := range ms {
+			fmt.Println("  " + FormatMigration(m))
+		}
+	} else {
+		fmt.Printf("  Store schema is up to date (version %d)\n", store.SchemaVersion)
+	}
 
 	// Write current_context.json
 	if err := UpdateContext(projectRoot, s); err != nil {
@@ -117,6 +124,18 @@ func setup(projectRoot string) error {
 	return nil
 }
 
+// FormatMigration describes a migration run by store.Open in one line.
+func FormatMigration(m store.MigrationResult) string {
+	line := fmt.Sprintf("Migrated .ghist/ to schema version %d (%s)", m.Version, m.Name)
+	if m.Summary != "" {
+		line += ": " + m.Summary
+	}
+	if m.Backup != "" {
+		line += "; originals backed up to .ghist/" + m.Backup
+	}
+	return line
+}
+
 // injectAgentFiles injects ghist content into all relevant agent instruction
 // files. Files in alwaysInject are created if they don't exist. Files in
 // injectIfExists are only updated if they already exist. Returns the list
@@ -0,0 +1,262 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Env is the context a query is evaluated in.
+type Env struct {
+	Workflow models.Workflow // orders statuses and priorities
+	Now      time.Time       // reference point for relative ages like 7d
+	Me       string          // who assignee:me stands for; "" matches nobody
+}
+
+// Validate checks the query against env: ordered comparisons on status and
+// priority need values the workflow defines.
+func (q *Query) Validate(env Env) error {
+	for _, t := range q.Terms {
+		if t.Op == OpEq || fields[t.Field] != kindOrdered {
+			continue
+		}
+		if rank(env.Workflow, t.Field, t.Values[0]) < 0 {
+			return fmt.Errorf("unknown %s %q in %s%s comparison", t.Field, t.Values[0], t.Field, t.Op)
+		}
+	}
+	return nil
+}
+
+// Match reports whether task matches every term of the query.
+func (q *Query) Match(task *models.Task, env Env) bool {
+	for _, t := range q.Terms {
+		if t.match(task, env) == t.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Term) match(task *models.Task, env Env) bool {
+	if t.Field == "" {
+		v := t.Values[0]
+		return strings.Contains(strings.ToLower(task.Title), v) ||
+			strings.Contains(strings.ToLower(task.Description), v)
+	}
+
+	switch fields[t.Field] {
+	case kindText:
+		return slices.ContainsFunc(t.Values, func(v string) bool {
+			switch t.Field {
+			case "milestone":
+				return strings.EqualFold(task.Milestone, v)
+			case "type":
+				return strings.EqualFold(task.Type, v)
+			case "assignee":
+				if strings.EqualFold(v, "me") {
+					return env.Me != "" && strings.EqualFold(task.Assignee, env.Me)
+				}
+				return strings.EqualFold(task.Assignee, v)
+			default: // label
+				return task.HasLabel(strings.ToLower(v))
+			}
+		})
+
+	case kindOrdered:
+		cur := task.Status
+		if t.Field == "priority" {
+			cur = task.Priority
+		}
+		if t.Op == OpEq {
+			return slices.Contains(t.Values, cur)
+		}
+		have := rank(env.Workflow, t.Field, cur)
+		return have >= 0 && compare(cmp.Compare(have, rank(env.Workflow, t.Field, t.Values[0])), t.Op)
+
+	case kindNumber:
+		var cur int64
+		switch {
+		case t.Field == "id":
+			cur = task.ID
+		case task.ParentID != nil:
+			cur = *task.ParentID
+		default:
+			return false
+		}
+		return slices.ContainsFunc(t.Values, func(v string) bool {
+			n, _ := parseNumber(v)
+			return compare(cmp.Compare(cur, n), t.Op)
+		})
+
+	case kindDate:
+		ts := task.UpdatedAt
+		if t.Field == "created" {
+			ts = task.CreatedAt
+		}
+		return slices.ContainsFunc(t.Values, func(v string) bool {
+			age, day, _ := parseDate(v)
+			if day.IsZero() {
+				// Relative: compare how long ago it happened, so
+				// updated:<7d is "less than 7 days ago" and updated:7d
+				// means "within the last 7 days".
+				op := t.Op
+				if op == OpEq {
+					op = OpLe
+				}
+				return compare(cmp.Compare(env.Now.Sub(ts), age), op)
+			}
+			d := ts.UTC().Truncate(24 * time.Hour)
+			return compare(d.Compare(day), t.Op)
+		})
+
+	case kindHas:
+		return slices.ContainsFunc(t.Values, func(v string) bool {
+			switch v {
+			case "plan":
+				return task.Plan != ""
+			case "description":
+				return task.Description != ""
+			case "commit":
+				return task.CommitHash != ""
+			case "milestone":
+				return task.Milestone != ""
+			case "parent":
+				return task.ParentID != nil
+			case "labels":
+				return len(task.Labels) > 0
+			case "blockers":
+				return len(task.BlockedBy) > 0
+			case "priority":
+				return task.Priority != ""
+			case "type":
+				return task.Type != ""
+			case "assignee":
+				return task.Assignee != ""
+			}
+			return false
+		})
+	}
+	return false
+}
+
+// compare applies op to c, the result of comparing the task's value with the
+// query's (-1, 0 or +1).
+func compare(c int, op Op) bool {
+	switch op {
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	}
+	return c == 0
+}
+
+// rank is the position of a status in board order or of a priority from
+// lowest to highest, or -1 if the workflow does not define it.
+func rank(wf models.Workflow, field, value string) int {
+	if field == "priority" {
+		return wf.PriorityRank(value)
+	}
+	return slices.Index(wf.StatusNames(), value)
+}
+
+// Sort orders query results.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+var sortFields = []string{"id", "title", "status", "priority", "type", "milestone", "created_at", "updated_at"}
+
+// ParseSort parses a sort spec: a field name optionally followed by "asc" or
+// "desc" ("updated_at desc"), or prefixed with "-" for descending. An empty
+// spec sorts by ID.
+func ParseSort(spec string) (Sort, error) {
+	parts := strings.Fields(strings.ToLower(spec))
+	if len(parts) == 0 {
+		return Sort{Field: "id"}, nil
+	}
+	var s Sort
+	s.Field, s.Desc = strings.CutPrefix(parts[0], "-")
+	if s.Field == "created" || s.Field == "updated" {
+		s.Field += "_at"
+	}
+	if !slices.Contains(sortFields, s.Field) {
+		return s, fmt.Errorf("cannot sort by %q (one of %s)", parts[0], strings.Join(sortFields, ", "))
+	}
+	switch {
+	case len(parts) == 1:
+	case len(parts) == 2 && parts[1] == "asc":
+		s.Desc = false
+	case len(parts) == 2 && parts[1] == "desc":
+		s.Desc = true
+	default:
+		return s, fmt.Errorf("invalid sort %q: want \"<field> [asc|desc]\"", spec)
+	}
+	return s, nil
+}
+
+// SortTasks sorts tasks in place. Ties keep ID order.
+func SortTasks(tasks []models.Task, s Sort, wf models.Workflow) {
+	byField := func(a, b *models.Task) int {
+		switch s.Field {
+		case "id":
+			return cmp.Compare(a.ID, b.ID)
+		case "title":
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case "status", "priority":
+			av, bv := a.Status, b.Status
+			if s.Field == "priority" {
+				av, bv = a.Priority, b.Priority
+			}
+			return cmp.Compare(rank(wf, s.Field, av), rank(wf, s.Field, bv))
+		case "type":
+			return strings.Compare(a.Type, b.Type)
+		case "milestone":
+			return strings.Compare(a.Milestone, b.Milestone)
+		case "created_at":
+			return a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		return 0
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		c := byField(&tasks[i], &tasks[j])
+		if c == 0 {
+			return tasks[i].ID < tasks[j].ID
+		}
+		return (c < 0) != s.Desc
+	})
+}
+
+// Apply filters tasks by q (which may be nil), sorts them and keeps at most
+// limit results (0 means no limit).
+func Apply(tasks []models.Task, q *Query, s Sort, limit int, env Env) ([]models.Task, error) {
+	out := tasks
+	if q != nil {
+		if err := q.Validate(env); err != nil {
+			return nil, err
+		}
+		out = nil
+		for i := range tasks {
+			if q.Match(&tasks[i], env) {
+				out = append(out, tasks[i])
+			}
+		}
+	}
+	SortTasks(out, s, env.Workflow)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
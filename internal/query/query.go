@@ -0,0 +1,274 @@
+// Package query parses and evaluates the compact task query language used by
+// "ghist task list --query" and "GET /api/tasks?q=".
+//
+// A query is a space-separated list of terms, all of which must match:
+//
+//	status:in_progress,blocked   field is any of the comma-separated values
+//	priority>=high               ordered comparison (priority, status, id, dates)
+//	milestone:"v2 beta"          quoted values may contain spaces
+//	assignee:me                  "me" is the user running the query
+//	updated:<7d                  updated less than 7 days ago
+//	created>=2026-01-01          absolute dates are compared as UTC days
+//	has:plan                     field is set
+//	-type:chore                  a leading "-" negates any term
+//	login                        bare words match the title or description
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Op is a comparison operator.
+type Op string
+
+const (
+	OpEq Op = ":"
+	OpLt Op = "<"
+	OpLe Op = "<="
+	OpGt Op = ">"
+	OpGe Op = ">="
+)
+
+// Term is one condition in a query. A term with an empty Field is free text.
+type Term struct {
+	Field  string
+	Op     Op
+	Values []string
+	Negate bool
+}
+
+// Query is a parsed query expression.
+type Query struct {
+	Terms []Term
+}
+
+type fieldKind int
+
+const (
+	kindText    fieldKind = iota // exact match, ":" only
+	kindOrdered                  // workflow-ordered: priority, status
+	kindNumber                   // task IDs
+	kindDate                     // created, updated
+	kindHas                      // has:<attribute>
+)
+
+var fields = map[string]fieldKind{
+	"status":    kindOrdered,
+	"priority":  kindOrdered,
+	"milestone": kindText,
+	"type":      kindText,
+	"label":     kindText,
+	"assignee":  kindText,
+	"id":        kindNumber,
+	"parent":    kindNumber,
+	"created":   kindDate,
+	"updated":   kindDate,
+	"has":       kindHas,
+}
+
+// hasAttributes are the values accepted by has:.
+var hasAttributes = []string{"plan", "description", "commit", "milestone", "parent", "labels", "blockers", "priority", "type", "assignee"}
+
+// Parse parses a query expression. An empty expression matches every task.
+func Parse(expr string) (*Query, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{}
+	for _, tok := range tokens {
+		term, err := parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	return q, nil
+}
+
+// tokenize splits expr on whitespace outside double quotes. Quotes are kept
+// so parseTerm can tell a quoted value from a bare one.
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in query %q", expr)
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+func parseTerm(tok string) (Term, error) {
+	var t Term
+	if len(tok) > 1 && tok[0] == '-' {
+		t.Negate = true
+		tok = tok[1:]
+	}
+
+	i := 0
+	for i < len(tok) && (tok[i] >= 'a' && tok[i] <= 'z' || tok[i] == '_') {
+		i++
+	}
+	name, rest := tok[:i], tok[i:]
+	op, rest, ok := cutOp(rest)
+	if name == "" || !ok {
+		// Not field:value — free text.
+		v, err := unquote(tok)
+		if err != nil {
+			return t, err
+		}
+		t.Values = []string{strings.ToLower(v)}
+		return t, nil
+	}
+
+	kind, known := fields[name]
+	if !known {
+		return t, fmt.Errorf("unknown query field %q", name)
+	}
+	if op != OpEq && (kind == kindText || kind == kindHas) {
+		return t, fmt.Errorf("field %q only supports %q", name, OpEq)
+	}
+	values := splitValues(rest)
+	if len(values) == 0 {
+		return t, fmt.Errorf("missing value for %q", name)
+	}
+	if op != OpEq && len(values) > 1 {
+		return t, fmt.Errorf("%s%s takes a single value", name, op)
+	}
+	for _, v := range values {
+		if err := checkValue(name, kind, v); err != nil {
+			return t, err
+		}
+	}
+	t.Field, t.Op, t.Values = name, op, values
+	return t, nil
+}
+
+// cutOp strips the operator from the front of s. "updated:<7d" and
+// "updated<7d" are the same term.
+func cutOp(s string) (Op, string, bool) {
+	if rest, ok := strings.CutPrefix(s, ":"); ok {
+		if op, rest2, ok := cutCompare(rest); ok {
+			return op, rest2, true
+		}
+		return OpEq, rest, true
+	}
+	if rest, ok := strings.CutPrefix(s, "="); ok {
+		return OpEq, rest, true
+	}
+	return cutCompare(s)
+}
+
+func cutCompare(s string) (Op, string, bool) {
+	for _, op := range []Op{OpLe, OpGe, OpLt, OpGt} {
+		if rest, ok := strings.CutPrefix(s, string(op)); ok {
+			return op, rest, true
+		}
+	}
+	return "", s, false
+}
+
+// splitValues splits a comma-separated value list, honouring quotes.
+func splitValues(s string) []string {
+	var values []string
+	var cur strings.Builder
+	inQuote := false
+	flush := func() {
+		if cur.Len() > 0 {
+			values = append(values, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ',' && !inQuote:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return values
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return "", fmt.Errorf("malformed quoted term %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	return s, nil
+}
+
+func checkValue(field string, kind fieldKind, v string) error {
+	switch kind {
+	case kindNumber:
+		if _, err := parseNumber(v); err != nil {
+			return fmt.Errorf("invalid %s value %q: want a task ID", field, v)
+		}
+	case kindDate:
+		if _, _, err := parseDate(v); err != nil {
+			return fmt.Errorf("invalid %s value %q: %w", field, v, err)
+		}
+	case kindHas:
+		for _, a := range hasAttributes {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown has: value %q (one of %s)", v, strings.Join(hasAttributes, ", "))
+	}
+	return nil
+}
+
+// parseDate parses a relative age ("36h", "7d", "2w") or an absolute date
+// ("2026-01-31"). Exactly one of age and day is set.
+func parseDate(v string) (age time.Duration, day time.Time, err error) {
+	if day, err := time.Parse("2006-01-02", v); err == nil {
+		return 0, day, nil
+	}
+	if len(v) < 2 {
+		return 0, time.Time{}, fmt.Errorf("want an age like 7d or a date like 2026-01-31")
+	}
+	n, err := strconv.Atoi(v[:len(v)-1])
+	if err != nil || n < 0 {
+		return 0, time.Time{}, fmt.Errorf("want an age like 7d or a date like 2026-01-31")
+	}
+	unit := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[v[len(v)-1]]
+	if unit == 0 {
+		return 0, time.Time{}, fmt.Errorf("age unit must be h, d or w")
+	}
+	return time.Duration(n) * unit, time.Time{}, nil
+}
+
+// parseNumber parses a task ID given bare ("12") or as a ref ("API-12").
+func parseNumber(v string) (int64, error) {
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		v = v[i+1:]
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
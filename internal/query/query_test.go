@@ -0,0 +1,192 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`status:in_progress,blocked priority>=high milestone:"v2 beta" updated:<7d has:plan -type:chore login`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Term{
+		{Field: "status", Op: OpEq, Values: []string{"in_progress", "blocked"}},
+		{Field: "priority", Op: OpGe, Values: []string{"high"}},
+		{Field: "milestone", Op: OpEq, Values: []string{"v2 beta"}},
+		{Field: "updated", Op: OpLt, Values: []string{"7d"}},
+		{Field: "has", Op: OpEq, Values: []string{"plan"}},
+		{Field: "type", Op: OpEq, Values: []string{"chore"}, Negate: true},
+		{Values: []string{"login"}},
+	}
+	if len(q.Terms) != len(want) {
+		t.Fatalf("expected %d terms, got %d: %+v", len(want), len(q.Terms), q.Terms)
+	}
+	for i, w := range want {
+		got := q.Terms[i]
+		if got.Field != w.Field || got.Op != w.Op || got.Negate != w.Negate || len(got.Values) != len(w.Values) {
+			t.Errorf("term %d: got %+v, want %+v", i, got, w)
+			continue
+		}
+		for j := range w.Values {
+			if got.Values[j] != w.Values[j] {
+				t.Errorf("term %d value %d: got %q, want %q", i, j, got.Values[j], w.Values[j])
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		`owner:me`,
+		`milestone>v1`,
+		`status:`,
+		`updated:<7y`,
+		`created>yesterday`,
+		`has:wings`,
+		`id:abc`,
+		`priority>=high,urgent`,
+		`"unterminated`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	env := Env{Workflow: models.DefaultWorkflow(), Now: now, Me: "Ada <ada@example.com>"}
+	parent := int64(1)
+	task := models.Task{
+		ID:        4,
+		Title:     "Fix login redirect",
+		Status:    "in_progress",
+		Priority:  "high",
+		Type:      "bug",
+		Milestone: "v2",
+		Labels:    []string{"auth"},
+		Assignee:  "Ada <ada@example.com>",
+		Plan:      "1. do it",
+		ParentID:  &parent,
+		CreatedAt: now.Add(-30 * 24 * time.Hour),
+		UpdatedAt: now.Add(-2 * 24 * time.Hour),
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"status:in_progress,blocked", true},
+		{"status:todo", false},
+		{"priority>=high", true},
+		{"priority>high", false},
+		{"priority<urgent", true},
+		{"status>=in_progress", true},
+		{"milestone:V2", true},
+		{"label:auth", true},
+		{"label:infra", false},
+		{"-type:chore", true},
+		{"-type:bug", false},
+		{"updated:<7d", true},
+		{"updated:<1d", false},
+		{"updated:3d", true},
+		{"created>2w", true},
+		{"created:2026-02-08", true},
+		{"created<2026-02-08", false},
+		{"updated>=2026-03-08", true},
+		{"has:plan", true},
+		{"has:commit", false},
+		{"assignee:me", true},
+		{`assignee:"ada <ada@example.com>"`, true},
+		{"assignee:bob", false},
+		{"has:assignee", true},
+		{"-has:commit", true},
+		{"parent:GHST-1", true},
+		{"id>3 id<5", true},
+		{"LOGIN", true},
+		{`"login redirect"`, true},
+		{"-login", false},
+		{"logout", false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := q.Match(&task, env); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	env := Env{Workflow: models.DefaultWorkflow()}
+	q, _ := Parse("priority>=critical")
+	if err := q.Validate(env); err == nil {
+		t.Error("expected error for unknown priority in comparison")
+	}
+	q, _ = Parse("priority:critical")
+	if err := q.Validate(env); err != nil {
+		t.Errorf("equality on an unknown value should be allowed: %v", err)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Sort
+		wantErr bool
+	}{
+		{"", Sort{Field: "id"}, false},
+		{"updated_at desc", Sort{Field: "updated_at", Desc: true}, false},
+		{"-priority", Sort{Field: "priority", Desc: true}, false},
+		{"created", Sort{Field: "created_at"}, false},
+		{"title asc", Sort{Field: "title"}, false},
+		{"owner", Sort{}, true},
+		{"title sideways", Sort{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSort(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseSort(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	now := time.Now()
+	env := Env{Workflow: models.DefaultWorkflow(), Now: now}
+	tasks := []models.Task{
+		{ID: 1, Title: "a", Status: "todo", Priority: "low", UpdatedAt: now.Add(-3 * time.Hour)},
+		{ID: 2, Title: "b", Status: "todo", Priority: "urgent", UpdatedAt: now.Add(-1 * time.Hour)},
+		{ID: 3, Title: "c", Status: "done", Priority: "high", UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: 4, Title: "d", Status: "todo", Priority: "high", UpdatedAt: now},
+	}
+
+	q, _ := Parse("status:todo")
+	got, err := Apply(tasks, q, Sort{Field: "priority", Desc: true}, 2, env)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 4 {
+		t.Errorf("expected [2 4], got %+v", got)
+	}
+
+	got, _ = Apply(tasks, nil, Sort{Field: "updated_at", Desc: true}, 0, env)
+	var ids []int64
+	for _, task := range got {
+		ids = append(ids, task.ID)
+	}
+	if len(ids) != 4 || ids[0] != 4 || ids[1] != 2 || ids[2] != 3 || ids[3] != 1 {
+		t.Errorf("expected updated_at desc order [4 2 3 1], got %v", ids)
+	}
+}
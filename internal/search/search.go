@@ -0,0 +1,282 @@
+// Package search is a small inverted index over task and event text, used by
+// "ghist search" and "GET /api/search".
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fieldWeights scores a hit in a task title above one buried in a plan.
+var fieldWeights = map[string]float64{
+	"title":       3,
+	"labels":      2,
+	"description": 1.5,
+	"message":     1.5,
+	"plan":        1,
+}
+
+// prefixWeight discounts a query term that only matches the start of a word
+// ("auth" finding "authentication").
+const prefixWeight = 0.6
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// Doc is one indexed record: a task or an event.
+type Doc struct {
+	Kind   string            `json:"kind"` // "task" or "event"
+	ID     int64             `json:"id"`
+	TaskID *int64            `json:"task_id,omitempty"`
+	Title  string            `json:"title"`
+	Fields map[string]string `json:"fields"`
+}
+
+// Index maps terms to the documents and fields they occur in.
+type Index struct {
+	Docs map[string]*Doc `json:"docs"`
+	// Terms maps term → document key → field → occurrences.
+	Terms map[string]map[string]map[string]int `json:"terms"`
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Docs: make(map[string]*Doc), Terms: make(map[string]map[string]map[string]int)}
+}
+
+// Add indexes doc under key, replacing any document already stored there.
+func (ix *Index) Add(key string, doc *Doc) {
+	ix.Remove(key)
+	ix.Docs[key] = doc
+	for field, text := range doc.Fields {
+		for _, tok := range Tokenize(text) {
+			docs := ix.Terms[tok]
+			if docs == nil {
+				docs = make(map[string]map[string]int)
+				ix.Terms[tok] = docs
+			}
+			if docs[key] == nil {
+				docs[key] = make(map[string]int)
+			}
+			docs[key][field]++
+		}
+	}
+}
+
+// Remove drops the document stored under key, if any.
+func (ix *Index) Remove(key string) {
+	doc, ok := ix.Docs[key]
+	if !ok {
+		return
+	}
+	for _, text := range doc.Fields {
+		for _, tok := range Tokenize(text) {
+			delete(ix.Terms[tok], key)
+			if len(ix.Terms[tok]) == 0 {
+				delete(ix.Terms, tok)
+			}
+		}
+	}
+	delete(ix.Docs, key)
+}
+
+// Result is one search hit.
+type Result struct {
+	Kind    string   `json:"kind"`
+	ID      int64    `json:"id"`
+	TaskID  *int64   `json:"task_id,omitempty"`
+	Title   string   `json:"title"`
+	Field   string   `json:"field"`  // the best-matching field
+	Fields  []string `json:"fields"` // every field that matched
+	Snippet string   `json:"snippet"`
+	Score   float64  `json:"score"`
+}
+
+// Search returns documents containing every term of q, best first. Terms of
+// three or more letters also match words they are a prefix of. Matched words
+// in the snippet are wrapped in "**". limit <= 0 means no limit.
+func (ix *Index) Search(q string, limit int) []Result {
+	qterms := Tokenize(q)
+	if len(qterms) == 0 {
+		return nil
+	}
+
+	type hit struct {
+		score  float64
+		fields map[string]float64
+	}
+	var hits map[string]*hit
+	matched := make(map[string]bool) // index terms that matched, for snippets
+	n := float64(len(ix.Docs))
+
+	for _, qt := range qterms {
+		termHits := make(map[string]*hit)
+		for term, docs := range ix.Terms {
+			w := 1.0
+			switch {
+			case term == qt:
+			case len(qt) >= 3 && strings.HasPrefix(term, qt):
+				w = prefixWeight
+			default:
+				continue
+			}
+			matched[term] = true
+			idf := math.Log(1 + n/float64(len(docs)))
+			for key, fields := range docs {
+				h := termHits[key]
+				if h == nil {
+					h = &hit{fields: make(map[string]float64)}
+					termHits[key] = h
+				}
+				for field, tf := range fields {
+					s := w * fieldWeights[field] * idf * float64(tf) / (float64(tf) + 1.2)
+					h.fields[field] += s
+					h.score += s
+				}
+			}
+		}
+		// Every query term must match.
+		if hits == nil {
+			hits = termHits
+			continue
+		}
+		for key, h := range hits {
+			th, ok := termHits[key]
+			if !ok {
+				delete(hits, key)
+				continue
+			}
+			h.score += th.score
+			for f, s := range th.fields {
+				h.fields[f] += s
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(hits))
+	for key, h := range hits {
+		doc := ix.Docs[key]
+		r := Result{Kind: doc.Kind, ID: doc.ID, TaskID: doc.TaskID, Title: doc.Title, Score: math.Round(h.score*1000) / 1000}
+		best := -1.0
+		for f, s := range h.fields {
+			r.Fields = append(r.Fields, f)
+			if s > best || s == best && f < r.Field {
+				best, r.Field = s, f
+			}
+		}
+		sort.Strings(r.Fields)
+		r.Snippet = Snippet(doc.Fields[r.Field], matched)
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Kind != b.Kind {
+			return a.Kind > b.Kind // tasks before events
+		}
+		return a.ID > b.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// snippetRadius is roughly how many bytes of context a snippet keeps on each
+// side of the first match.
+const snippetRadius = 60
+
+// Snippet returns a one-line excerpt of text around the first word in terms,
+// with every such word wrapped in "**".
+func Snippet(text string, terms map[string]bool) string {
+	text = strings.Join(strings.Fields(text), " ")
+	spans := tokenSpans(text)
+	first := -1
+	var sb strings.Builder
+	for _, sp := range spans {
+		if terms[sp.tok] && first < 0 {
+			first = sp.start
+		}
+	}
+	start, end := 0, len(text)
+	if first >= 0 {
+		start = max(0, first-snippetRadius)
+	}
+	end = min(len(text), start+2*snippetRadius+40)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	if i := strings.IndexByte(text[start:], ' '); start > 0 && i >= 0 && start+i < first {
+		start += i + 1
+	}
+	if i := strings.LastIndexByte(text[:end], ' '); end < len(text) && i > start {
+		end = i
+	}
+
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, sp := range spans {
+		if sp.start < start || sp.end > end || !terms[sp.tok] {
+			continue
+		}
+		sb.WriteString(text[pos:sp.start])
+		sb.WriteString("**")
+		sb.WriteString(text[sp.start:sp.end])
+		sb.WriteString("**")
+		pos = sp.end
+	}
+	sb.WriteString(text[pos:end])
+	if end < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+type span struct {
+	tok        string
+	start, end int
+}
+
+// tokenSpans splits text into lower-cased words with their byte offsets.
+func tokenSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			spans = append(spans, span{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return spans
+}
+
+// Tokenize splits text into lower-cased words, dropping common stop words.
+func Tokenize(text string) []string {
+	var toks []string
+	for _, sp := range tokenSpans(text) {
+		if !stopWords[sp.tok] {
+			toks = append(toks, sp.tok)
+		}
+	}
+	return toks
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func testIndex() *Index {
+	ix := New()
+	ix.Add("tasks/1.json", &Doc{Kind: "task", ID: 1, Title: "Fix login redirect", Fields: map[string]string{
+		"title":       "Fix login redirect",
+		"description": "Users land on a blank page after signing in.",
+	}})
+	ix.Add("tasks/2.json", &Doc{Kind: "task", ID: 2, Title: "Rate limiting", Fields: map[string]string{
+		"title": "Rate limiting",
+		"plan":  "Add a token bucket in front of the login endpoint and the API gateway.",
+	}})
+	taskID := int64(1)
+	ix.Add("events/7.json", &Doc{Kind: "event", ID: 7, TaskID: &taskID, Title: "decision", Fields: map[string]string{
+		"message": "Decided to keep session cookies for login; JWT adds no value here.",
+	}})
+	return ix
+}
+
+func TestSearchRanksTitleAboveBody(t *testing.T) {
+	results := testIndex().Search("login", 0)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d: %+v", len(results), results)
+	}
+	if results[0].Kind != "task" || results[0].ID != 1 || results[0].Field != "title" {
+		t.Errorf("expected title hit on task 1 first, got %+v", results[0])
+	}
+	var sawEvent bool
+	for _, r := range results {
+		if r.Kind == "event" {
+			sawEvent = true
+			if r.Field != "message" || r.TaskID == nil || *r.TaskID != 1 {
+				t.Errorf("unexpected event hit %+v", r)
+			}
+		}
+	}
+	if !sawEvent {
+		t.Error("expected an event hit")
+	}
+}
+
+func TestSearchRequiresAllTerms(t *testing.T) {
+	results := testIndex().Search("login gateway", 0)
+	if len(results) != 1 || results[0].ID != 2 || results[0].Field != "plan" {
+		t.Errorf("expected only task 2's plan, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "**login**") || !strings.Contains(results[0].Snippet, "**gateway**") {
+		t.Errorf("expected highlighted snippet, got %q", results[0].Snippet)
+	}
+}
+
+func TestSearchPrefixAndRemove(t *testing.T) {
+	ix := testIndex()
+	if results := ix.Search("redir", 0); len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("expected prefix match on task 1, got %+v", results)
+	}
+	ix.Remove("tasks/1.json")
+	if results := ix.Search("redirect", 0); len(results) != 0 {
+		t.Errorf("expected no results after remove, got %+v", results)
+	}
+	if _, ok := ix.Terms["redirect"]; ok {
+		t.Error("expected removed document's terms to be dropped")
+	}
+	if results := ix.Search("the", 0); results != nil {
+		t.Errorf("expected stop-word-only query to return nothing, got %+v", results)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("lorem ipsum ", 30) + "the needle is here " + strings.Repeat("dolor sit ", 30)
+	got := Snippet(text, map[string]bool{"needle": true})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected elided snippet, got %q", got)
+	}
+	if !strings.Contains(got, "the **needle** is here") {
+		t.Errorf("expected highlighted match, got %q", got)
+	}
+	if len(got) > 200 {
+		t.Errorf("snippet too long (%d bytes)", len(got))
+	}
+}
@@ -0,0 +1,359 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Archived events live in append-only JSONL files, one per month of
+// created_at: .ghist/archive/events/2025-11.jsonl. Lines are only ever
+// appended, so archives merge cleanly across branches.
+const archiveMonth = "2006-01"
+
+// compactionInterval is how often automatic compaction runs at most.
+const compactionInterval = 24 * time.Hour
+
+// ArchiveReport summarises an ArchiveEvents or CompactEvents run.
+type ArchiveReport struct {
+	Archived int      `json:"archived"`
+	Kept     int      `json:"kept"`  // old enough but kept by the retention policy
+	Files    []string `json:"files"` // archive files appended to
+}
+
+func (s *FileStore) eventArchiveDir() string {
+	return filepath.Join(s.root, "archive", "events")
+}
+
+// ArchiveEvents moves events created before the cutoff out of events/ into the
+// monthly archives. Events the retention policy keeps stay put unless all is
+// set. The newest event always stays in events/ so that new events never
+// reuse an archived ID. With dryRun nothing is written.
+func (s *FileStore) ArchiveEvents(before time.Time, all, dryRun bool) (*ArchiveReport, error) {
+	policy, err := s.EventRetention()
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		policy.KeepTypes = []string{}
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.archiveEvents(before, policy, dryRun)
+}
+
+// CompactEvents archives events older than the retention policy's
+// archive_after_days (DefaultArchiveAfterDays if unset), keeping the event
+// types the policy keeps.
+func (s *FileStore) CompactEvents(dryRun bool) (*ArchiveReport, error) {
+	policy, err := s.EventRetention()
+	if err != nil {
+		return nil, err
+	}
+	days := policy.ArchiveAfterDays
+	if days <= 0 {
+		days = models.DefaultArchiveAfterDays
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.archiveEvents(time.Now().AddDate(0, 0, -days), policy, dryRun)
+}
+
+// autoCompact runs compaction when the project has a retention policy and it
+// has not run in the last compactionInterval. Failures are ignored; the next
+// write tries again. Callers must hold the store lock.
+func (s *FileStore) autoCompact() {
+	policy, err := s.EventRetention()
+	if err != nil || policy.ArchiveAfterDays <= 0 {
+		return
+	}
+	marker := filepath.Join(s.root, indexDir, "compacted_at")
+	if info, err := s.fs.stat(marker); err == nil && time.Since(info.ModTime()) < compactionInterval {
+		return
+	}
+	if _, err := s.archiveEvents(time.Now().AddDate(0, 0, -policy.ArchiveAfterDays), policy, false); err != nil {
+		return
+	}
+	if err := s.fs.mkdirAll(filepath.Dir(marker)); err == nil {
+		s.fs.writeFile(marker, []byte(time.Now().UTC().Format(time.RFC3339))) //nolint:errcheck
+	}
+}
+
+// archiveEvents does the work of ArchiveEvents; callers must hold the lock.
+func (s *FileStore) archiveEvents(before time.Time, policy models.EventRetention, dryRun bool) (*ArchiveReport, error) {
+	events, err := s.readAllEvents()
+	if err != nil {
+		return nil, err
+	}
+	var newest int64
+	for _, e := range events {
+		newest = max(newest, e.ID)
+	}
+
+	report := &ArchiveReport{Files: []string{}}
+	byMonth := make(map[string][]models.Event)
+	for _, e := range events {
+		if !e.CreatedAt.Before(before) || e.ID == newest {
+			continue
+		}
+		if policy.Keeps(&e) {
+			report.Kept++
+			continue
+		}
+		month := e.CreatedAt.UTC().Format(archiveMonth)
+		byMonth[month] = append(byMonth[month], e)
+		report.Archived++
+	}
+
+	months := make([]string, 0, len(byMonth))
+	for m := range byMonth {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	for _, m := range months {
+		report.Files = append(report.Files, filepath.ToSlash(filepath.Join("archive", "events", m+".jsonl")))
+	}
+	if dryRun || report.Archived == 0 {
+		return report, nil
+	}
+
+	if err := s.fs.mkdirAll(s.eventArchiveDir()); err != nil {
+		return nil, fmt.Errorf("creating event archive: %w", err)
+	}
+	for _, m := range months {
+		batch := byMonth[m]
+		sort.Slice(batch, func(i, j int) bool { return batch[i].ID < batch[j].ID })
+		path := filepath.Join(s.eventArchiveDir(), m+".jsonl")
+		s.journalAppend(path)
+		if err := s.appendArchive(path, batch); err != nil {
+			return nil, err
+		}
+		// Only remove the originals once they are safely in the archive. A
+		// crash in between leaves an event in both places; readers prefer
+		// the copy in events/.
+		for i := range batch {
+			if err := s.removeFile(s.eventPath(&batch[i])); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("removing archived event %d: %w", batch[i].ID, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// appendArchive appends events to a JSONL archive file and waits until they
+// are durable.
+func (s *FileStore) appendArchive(path string, events []models.Event) error {
+	data, err := encodeArchive(events)
+	if err != nil {
+		return err
+	}
+	if err := s.fs.appendFile(path, data); err != nil {
+		return fmt.Errorf("appending to archive %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeArchive replaces an archive file with events. Only repairs do this;
+// archiving itself only ever appends.
+func (s *FileStore) writeArchive(path string, events []models.Event) error {
+	data, err := encodeArchive(events)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(path, data)
+}
+
+// encodeArchive encodes events as JSONL archive lines.
+func encodeArchive(events []models.Event) ([]byte, error) {
+	var buf strings.Builder
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling event %d: %w", e.ID, err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return []byte(buf.String()), nil
+}
+
+// archiveCache keeps parsed archive files keyed by file name and stamp.
+type archiveCache struct {
+	mu    sync.Mutex
+	files map[string]archiveFile
+}
+
+type archiveFile struct {
+	stamp  fileStamp
+	events []models.Event
+}
+
+// archiveMonths returns the months that have an archive file, newest first.
+func (s *FileStore) archiveMonths() ([]string, error) {
+	entries, err := s.fs.readDir(s.eventArchiveDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing event archive: %w", err)
+	}
+	var months []string
+	for _, e := range entries {
+		if m, ok := strings.CutSuffix(e.Name(), ".jsonl"); ok && !e.IsDir() {
+			if _, err := time.Parse(archiveMonth, m); err == nil {
+				months = append(months, m)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	return months, nil
+}
+
+// readArchive returns the events archived for month.
+func (s *FileStore) readArchive(month string) ([]models.Event, error) {
+	path := filepath.Join(s.eventArchiveDir(), month+".jsonl")
+	info, err := s.fs.stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading event archive %s: %w", month, err)
+	}
+
+	c := &s.archiveCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.files[month]; ok && f.stamp == stampOf(info) {
+		return cloneEvents(f.events), nil
+	}
+
+	data, err := s.fs.readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading event archive %s: %w", month, err)
+	}
+	var events []models.Event
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e models.Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parsing event archive %s line %d: %w", month, line, err)
+		}
+		events = append(events, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading event archive %s: %w", month, err)
+	}
+	if c.files == nil {
+		c.files = make(map[string]archiveFile)
+	}
+	c.files[month] = archiveFile{stamp: stampOf(info), events: events}
+	return cloneEvents(events), nil
+}
+
+func cloneEvents(events []models.Event) []models.Event {
+	out := make([]models.Event, len(events))
+	for i, e := range events {
+		out[i] = cloneEvent(e)
+	}
+	return out
+}
+
+// mergeArchived appends the archived events of the given months to live,
+// skipping any that are also still in events/ (left there by an interrupted
+// archive run) or that appear twice in the archive.
+func mergeArchived(live []models.Event, archived ...[]models.Event) []models.Event {
+	seen := make(map[string]bool, len(live))
+	key := func(e *models.Event) string {
+		if e.UID != "" {
+			return e.UID
+		}
+		return fmt.Sprintf("#%d", e.ID)
+	}
+	for i := range live {
+		seen[key(&live[i])] = true
+	}
+	out := live
+	for _, batch := range archived {
+		for i := range batch {
+			if k := key(&batch[i]); !seen[k] {
+				seen[k] = true
+				out = append(out, batch[i])
+			}
+		}
+	}
+	return out
+}
+
+// readEventsWithArchive returns live and archived events. It reads archive
+// months newest first and, when limit > 0, stops once no older month can
+// contribute to the limit newest events.
+func (s *FileStore) readEventsWithArchive(limit int, keep func(*models.Event) bool) ([]models.Event, error) {
+	live, err := s.readAllEvents()
+	if err != nil {
+		return nil, err
+	}
+	events := slices.DeleteFunc(live, func(e models.Event) bool { return !keep(&e) })
+	months, err := s.archiveMonths()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range months {
+		if limit > 0 && len(events) >= limit {
+			sortEventsNewestFirst(events)
+			monthEnd, _ := time.Parse(archiveMonth, m)
+			if !monthEnd.AddDate(0, 1, 0).After(events[limit-1].CreatedAt) {
+				break
+			}
+		}
+		archived, err := s.readArchive(m)
+		if err != nil {
+			return nil, err
+		}
+		events = mergeArchived(events, slices.DeleteFunc(archived, func(e models.Event) bool { return !keep(&e) }))
+	}
+	sortEventsNewestFirst(events)
+	return events, nil
+}
+
+func sortEventsNewestFirst(events []models.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+}
+
+// getArchivedEvent looks an event up in the archive, newest month first.
+func (s *FileStore) getArchivedEvent(id int64) (*models.Event, error) {
+	months, err := s.archiveMonths()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range months {
+		events, err := s.readArchive(m)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			if events[i].ID == id {
+				return &events[i], nil
+			}
+		}
+	}
+	return nil, notFound(fmt.Errorf("event %d not found", id))
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// auditedField describes a task field whose changes are recorded as events.
+type auditedField struct {
+	name      string
+	eventType string
+	value     func(t *models.Task) string
+}
+
+var auditedFields = []auditedField{
+	{"title", models.EventTaskTitleChanged, func(t *models.Task) string { return t.Title }},
+	{"description", models.EventTaskDescriptionChanged, func(t *models.Task) string { return t.Description }},
+	{"plan", models.EventTaskPlanUpdated, func(t *models.Task) string { return t.Plan }},
+	{"status", models.EventTaskStatusChanged, func(t *models.Task) string { return t.Status }},
+	{"milestone", models.EventTaskMilestoneChanged, func(t *models.Task) string { return t.Milestone }},
+	{"commit_hash", models.EventTaskCommitLinked, func(t *models.Task) string { return t.CommitHash }},
+	{"priority", models.EventTaskPriorityChanged, func(t *models.Task) string { return t.Priority }},
+	{"type", models.EventTaskTypeChanged, func(t *models.Task) string { return t.Type }},
+	{"legacy_id", models.EventTaskLegacyIDChanged, func(t *models.Task) string { return t.LegacyID }},
+	{"parent_id", models.EventTaskParentChanged, func(t *models.Task) string {
+		if t.ParentID == nil {
+			return ""
+		}
+		return strconv.FormatInt(*t.ParentID, 10)
+	}},
+	{"labels", models.EventTaskLabelsChanged, func(t *models.Task) string { return strings.Join(t.Labels, ",") }},
+	{"archived", models.EventTaskArchivedChanged, func(t *models.Task) string { return strconv.FormatBool(t.Archived) }},
+	{"assignee", models.EventTaskAssigneeChanged, func(t *models.Task) string { return t.Assignee }},
+}
+
+// recordChanges emits one audit event per field that differs between before
+// and after. Callers must hold the store lock.
+func (s *FileStore) recordChanges(before, after *models.Task, actor string) error {
+	for _, f := range auditedFields {
+		old, cur := f.value(before), f.value(after)
+		if old == cur {
+			continue
+		}
+		meta, err := json.Marshal(models.FieldChange{Field: f.name, Old: old, New: cur})
+		if err != nil {
+			return fmt.Errorf("marshaling %s change: %w", f.name, err)
+		}
+		id := after.ID
+		if _, err := s.createEvent(actor, f.eventType, changeMessage(after, f.name, old, cur), string(meta), &id, after.UID); err != nil {
+			return fmt.Errorf("recording %s change: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// recordCreated emits the audit event for a new task. Callers must hold the
+// store lock.
+func (s *FileStore) recordCreated(t *models.Task, actor string) error {
+	id := t.ID
+	if _, err := s.createEvent(actor, models.EventTaskCreated, fmt.Sprintf("%s created: %s", t.RefID, t.Title), "{}", &id, t.UID); err != nil {
+		return fmt.Errorf("recording task creation: %w", err)
+	}
+	return nil
+}
+
+// recordWrite emits an audit event of type typ, by actor (empty for the
+// store's actor), for a change to the opportunity or milestone identified by
+// id, uid and name. Callers must hold the store lock.
+func (s *FileStore) recordWrite(actor, typ string, id int64, uid, name, message string) error {
+	meta, err := json.Marshal(models.RecordChange{ID: id, UID: uid, Name: name})
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", typ, err)
+	}
+	if _, err := s.createEvent(actor, typ, message, string(meta), nil, ""); err != nil {
+		return fmt.Errorf("recording %s: %w", typ, err)
+	}
+	return nil
+}
+
+// changeMessage renders a one-line summary of a field change. Long free-text
+// fields are summarised rather than quoted in full; the full values are in
+// the event metadata.
+func changeMessage(t *models.Task, field, old, cur string) string {
+	switch field {
+	case "plan":
+		if old == "" {
+			return fmt.Sprintf("%s plan written", t.RefID)
+		}
+		return fmt.Sprintf("%s plan updated", t.RefID)
+	case "description":
+		return fmt.Sprintf("%s description updated", t.RefID)
+	case "commit_hash":
+		if cur == "" {
+			return fmt.Sprintf("%s commit unlinked", t.RefID)
+		}
+		return fmt.Sprintf("%s linked to commit %s", t.RefID, cur)
+	case "archived":
+		if t.Archived {
+			return fmt.Sprintf("%s archived", t.RefID)
+		}
+		return fmt.Sprintf("%s unarchived", t.RefID)
+	}
+	if old == "" {
+		old = "(none)"
+	}
+	if cur == "" {
+		cur = "(none)"
+	}
+	return fmt.Sprintf("%s %s: %s → %s", t.RefID, strings.ReplaceAll(field, "_", " "), old, cur)
+}
+
+// TaskHistory returns the audit events for a task, oldest first.
+func (s *FileStore) TaskHistory(taskID int64) ([]models.Event, error) {
+	events, err := s.ListEventsByTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	var history []models.Event
+	for _, e := range events {
+		if models.IsAuditEvent(e.Type) {
+			history = append(history, e)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		if !history[i].CreatedAt.Equal(history[j].CreatedAt) {
+			return history[i].CreatedAt.Before(history[j].CreatedAt)
+		}
+		return history[i].ID < history[j].ID
+	})
+	return history, nil
+}
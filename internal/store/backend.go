@@ -0,0 +1,142 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backend names, as stored under "backend" in settings.json.
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// backend is where a FileStore keeps its files. Every path is absolute,
+// under the store root, and uses the host's separators; the semantics follow
+// the os package, including fs.ErrNotExist for missing paths. Writes replace
+// a file in one step, so readers never see part of one.
+type backend interface {
+	name() string
+	readFile(path string) ([]byte, error)
+	writeFile(path string, data []byte) error
+	// appendFile appends data to path, creating it if needed, and returns
+	// once the data is durable.
+	appendFile(path string, data []byte) error
+	truncate(path string, size int64) error
+	// remove removes a file or an empty directory.
+	remove(path string) error
+	readDir(path string) ([]fs.DirEntry, error)
+	stat(path string) (fs.FileInfo, error)
+	mkdirAll(path string) error
+	// lock takes the cross-process write lock, returning its release func.
+	lock() (func(), error)
+	close() error
+}
+
+// dirBackend keeps files as they are: a directory of JSON files that can be
+// committed and merged with git.
+type dirBackend struct {
+	root string
+}
+
+func (b *dirBackend) name() string                               { return BackendJSON }
+func (b *dirBackend) readFile(path string) ([]byte, error)       { return os.ReadFile(path) }
+func (b *dirBackend) writeFile(path string, data []byte) error   { return writeFileAtomic(path, data) }
+func (b *dirBackend) truncate(path string, size int64) error     { return os.Truncate(path, size) }
+func (b *dirBackend) remove(path string) error                   { return os.Remove(path) }
+func (b *dirBackend) readDir(path string) ([]fs.DirEntry, error) { return os.ReadDir(path) }
+func (b *dirBackend) stat(path string) (fs.FileInfo, error)      { return os.Stat(path) }
+func (b *dirBackend) mkdirAll(path string) error                 { return os.MkdirAll(path, 0755) }
+func (b *dirBackend) close() error                               { return nil }
+
+func (b *dirBackend) appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lock serialises writers across processes (CLI, hooks, ghist serve) via an
+// OS lock on a file under .ghist/. The OS drops the lock when its holder
+// exits, however it exits, so a crash never leaves the store locked and a
+// slow writer never has its lock taken from it.
+func (b *dirBackend) lock() (func(), error) {
+	path := filepath.Join(b.root, lockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring store lock: %w", err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("acquiring store lock: %w", err)
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("acquiring store lock: timed out waiting for %s", path)
+		}
+		time.Sleep(lockRetryDelay)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// fileInfo describes a file or directory held by a backend that is not the
+// file system.
+type fileInfo struct {
+	base    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *fileInfo) Name() string       { return i.base }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func notExist(op, path string) error {
+	return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+}
+
+// clock hands out modification times that strictly increase, so a file
+// rewritten within the clock's resolution still gets a new stamp.
+type clock struct {
+	last int64
+}
+
+func (c *clock) now() time.Time {
+	n := time.Now().UnixNano()
+	if n <= c.last {
+		n = c.last + 1
+	}
+	c.last = n
+	return time.Unix(0, n)
+}
@@ -0,0 +1,272 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openSQLiteTestStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{"backend": "sqlite"}`), 0644)
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("opening sqlite store: %v", err)
+	}
+	return s
+}
+
+// backendStores returns a fresh, empty store on every backend.
+func backendStores(t *testing.T) map[string]*FileStore {
+	mem, err := OpenMemory()
+	if err != nil {
+		t.Fatalf("opening memory store: %v", err)
+	}
+	stores := map[string]*FileStore{
+		BackendJSON:   newTestStore(t),
+		BackendMemory: mem,
+		BackendSQLite: openSQLiteTestStore(t, t.TempDir()),
+	}
+	for _, s := range stores {
+		t.Cleanup(func() { s.Close() })
+	}
+	return stores
+}
+
+func TestBackends(t *testing.T) {
+	for name, s := range backendStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if s.Backend() != name {
+				t.Fatalf("expected backend %s, got %s", name, s.Backend())
+			}
+			if got := schemaVersion(t, s); got != SchemaVersion {
+				t.Errorf("expected a new store at schema %d, got %d", SchemaVersion, got)
+			}
+
+			a, err := s.CreateTask(CreateTaskInput{Title: "Write the parser", Labels: []string{"core"}})
+			if err != nil {
+				t.Fatalf("creating task: %v", err)
+			}
+			b, _ := s.CreateTask(CreateTaskInput{Title: "Ship it", ParentID: &a.ID})
+			if b.ID != 2 {
+				t.Errorf("expected the second task to get ID 2, got %d", b.ID)
+			}
+			plan := "Step one"
+			if _, err := s.UpdateTask(a.ID, TaskUpdate{Plan: &plan}); err != nil {
+				t.Fatalf("updating task: %v", err)
+			}
+			plan = "Step two"
+			s.UpdateTask(a.ID, TaskUpdate{Plan: &plan})
+			if revs, _ := s.ListPlanRevisions(a.ID); len(revs) != 2 {
+				t.Errorf("expected 2 plan revisions, got %+v", revs)
+			}
+			if tasks, _ := s.FilterTasks(TaskFilter{Labels: []string{"core"}}); len(tasks) != 1 || tasks[0].ID != a.ID {
+				t.Errorf("expected the labelled task, got %+v", tasks)
+			}
+			if subs, _ := s.ListSubtasks(a.ID); len(subs) != 1 {
+				t.Errorf("expected one subtask, got %+v", subs)
+			}
+
+			if _, err := s.CreateEvent("decision", "Chose a hand-written parser", "{}", &a.ID); err != nil {
+				t.Fatalf("creating event: %v", err)
+			}
+			if events, _ := s.ListEventsByTask(a.ID); len(events) == 0 {
+				t.Error("expected events on the task")
+			}
+			if results, err := s.Search("hand-written", 10); err != nil || len(results) != 1 {
+				t.Errorf("expected one search hit, got %+v (%v)", results, err)
+			}
+
+			o, _ := s.CreateOpportunity("Acme", "", "")
+			notes := "Call back"
+			if got, err := s.UpdateOpportunity(o.ID, OpportunityUpdate{Notes: &notes}); err != nil || got.Notes != notes {
+				t.Errorf("expected the opportunity updated, got %+v (%v)", got, err)
+			}
+			if err := s.SetMilestoneOrder([]string{"v1", "v2"}); err != nil {
+				t.Fatalf("setting milestone order: %v", err)
+			}
+			if order, _ := s.GetMilestoneOrder(); len(order) != 2 {
+				t.Errorf("expected the milestone order saved, got %v", order)
+			}
+
+			if err := s.DeleteTask(b.ID); err != nil {
+				t.Fatalf("deleting task: %v", err)
+			}
+			if trashed, _ := s.ListTrash(); len(trashed) != 1 {
+				t.Fatalf("expected the task in the trash, got %+v", trashed)
+			}
+			if _, err := s.RestoreTask(b.ID); err != nil {
+				t.Fatalf("restoring task: %v", err)
+			}
+
+			s.BeginOperation("ghist task add Third")
+			s.CreateTask(CreateTaskInput{Title: "Third"})
+			if err := s.commitOperation(); err != nil {
+				t.Fatalf("recording operation: %v", err)
+			}
+			if op, err := s.Undo(); err != nil || op.Name != "ghist task add Third" {
+				t.Fatalf("expected to undo the add, got %+v (%v)", op, err)
+			}
+			if tasks, _ := s.ListTasks("", "", "", ""); len(tasks) != 2 {
+				t.Errorf("expected 2 tasks after undo, got %d", len(tasks))
+			}
+
+			if _, err := s.ArchiveEvents(time.Now().Add(time.Hour), true, false); err != nil {
+				t.Fatalf("archiving events: %v", err)
+			}
+			if events, _ := s.ListEventsByTask(a.ID); len(events) == 0 {
+				t.Error("expected archived events still listed")
+			}
+			if report, err := s.Doctor(false); err != nil || report.Unresolved() != 0 {
+				t.Errorf("expected a clean doctor report, got %+v (%v)", report, err)
+			}
+		})
+	}
+}
+
+func TestChangeStamp(t *testing.T) {
+	for name, s := range backendStores(t) {
+		t.Run(name, func(t *testing.T) {
+			before, err := s.ChangeStamp()
+			if err != nil {
+				t.Fatalf("ChangeStamp: %v", err)
+			}
+			task, _ := s.CreateTask(CreateTaskInput{Title: "Watched"})
+			created, _ := s.ChangeStamp()
+			if created == before {
+				t.Error("expected creating a task to change the stamp")
+			}
+			title := "Watched closely"
+			s.UpdateTask(task.ID, TaskUpdate{Title: &title})
+			if updated, _ := s.ChangeStamp(); updated == created {
+				t.Error("expected updating a task to change the stamp")
+			}
+		})
+	}
+}
+
+func TestDirLock(t *testing.T) {
+	dir := t.TempDir()
+	// A lock file left by a process that died does not hold the lock.
+	os.WriteFile(filepath.Join(dir, lockFile), []byte("12345"), 0644)
+	b := &dirBackend{root: dir}
+	release, err := b.lock()
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, err := b.lock()
+		if err != nil {
+			t.Errorf("second lock: %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected the second lock to wait while the first is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case release := <-acquired:
+		if release != nil {
+			release()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second lock once the first was released")
+	}
+}
+
+func TestSQLiteKeepsRecordsInOneFile(t *testing.T) {
+	dir := t.TempDir()
+	s := openSQLiteTestStore(t, dir)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "In the database"})
+	s.Close()
+
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tasks")); len(entries) != 0 {
+		t.Errorf("expected no task files on disk, got %d", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, sqliteFile)); err != nil {
+		t.Fatalf("expected %s: %v", sqliteFile, err)
+	}
+	s2, err := Open(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s2.Close()
+	if got, err := s2.GetTask(task.ID); err != nil || got.Title != "In the database" {
+		t.Errorf("expected the task after reopening, got %+v (%v)", got, err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Survives conversion"})
+	plan := "A plan"
+	s.UpdateTask(task.ID, TaskUpdate{Plan: &plan})
+	s.CreateEvent("note", "and its event", "{}", &task.ID)
+	s.Close()
+
+	report, err := Convert(dir, BackendSQLite)
+	if err != nil {
+		t.Fatalf("converting to sqlite: %v", err)
+	}
+	if report.From != BackendJSON || report.Files == 0 || !strings.HasPrefix(report.Backup, "backups/json-") {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tasks")); !os.IsNotExist(err) {
+		t.Errorf("expected tasks/ moved to the backup, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, report.Backup, "tasks")); err != nil {
+		t.Errorf("expected tasks/ in the backup: %v", err)
+	}
+	if _, err := Convert(dir, BackendSQLite); err == nil {
+		t.Error("expected converting to the current backend to fail")
+	}
+
+	check := func(backend string) {
+		t.Helper()
+		s, err := Open(dir)
+		if err != nil {
+			t.Fatalf("opening %s store: %v", backend, err)
+		}
+		defer s.Close()
+		if s.Backend() != backend {
+			t.Errorf("expected backend %s, got %s", backend, s.Backend())
+		}
+		if got, err := s.GetTask(task.ID); err != nil || got.UID != task.UID || got.Plan != plan {
+			t.Errorf("expected the task carried over, got %+v (%v)", got, err)
+		}
+		if revs, _ := s.ListPlanRevisions(task.ID); len(revs) != 1 {
+			t.Errorf("expected the plan history carried over, got %+v", revs)
+		}
+		if events, _ := s.ListEventsByTask(task.ID); len(events) == 0 {
+			t.Error("expected the events carried over")
+		}
+	}
+	check(BackendSQLite)
+
+	report, err = Convert(dir, BackendJSON)
+	if err != nil {
+		t.Fatalf("converting back to json: %v", err)
+	}
+	if !strings.HasPrefix(report.Backup, "backups/ghist-") {
+		t.Errorf("expected the database moved to the backups, got %+v", report)
+	}
+	check(BackendJSON)
+
+	// A database left where the conversion would write is not overwritten.
+	data, _ := os.ReadFile(filepath.Join(dir, report.Backup))
+	os.WriteFile(filepath.Join(dir, sqliteFile), data, 0644)
+	if _, err := Convert(dir, BackendSQLite); err == nil || !strings.Contains(err.Error(), "already holds data") {
+		t.Errorf("expected converting onto a full database to fail, got %v", err)
+	}
+	check(BackendJSON)
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+const (
+	benchTasks  = 10_000
+	benchEvents = 100_000
+)
+
+// benchStore writes nTasks tasks and nEvents events straight to disk, which is
+// much faster than going through CreateTask for fixtures this size.
+func benchStore(b *testing.B, nTasks, nEvents int) *FileStore {
+	b.Helper()
+	dir := b.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		b.Fatalf("opening store: %v", err)
+	}
+	now := time.Now().UTC()
+	statuses := []string{"todo", "in_progress", "done", "blocked"}
+	for i := 1; i <= nTasks; i++ {
+		t := models.Task{
+			ID:          int64(i),
+			UID:         newUID(),
+			Title:       fmt.Sprintf("Task %d", i),
+			Description: "Benchmark fixture task with a short description.",
+			Status:      statuses[i%len(statuses)],
+			Milestone:   fmt.Sprintf("v%d", i%5),
+			RefID:       fmt.Sprintf("GHST-%d", i),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		writeBenchRecord(b, s.taskPath(&t), t)
+	}
+	for i := 1; i <= nEvents; i++ {
+		taskID := int64(i%nTasks + 1)
+		e := models.Event{ID: int64(i), UID: newUID(), Type: "log", Message: "benchmark event", Metadata: "{}", TaskID: &taskID, CreatedAt: now}
+		writeBenchRecord(b, s.eventPath(&e), e)
+	}
+	return s
+}
+
+func writeBenchRecord(b *testing.B, path string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// uncached returns a store over the same directory with empty caches, the
+// equivalent of a fresh CLI invocation.
+func uncached(s *FileStore) *FileStore {
+	return &FileStore{root: s.root, fs: &dirBackend{root: s.root}}
+}
+
+func BenchmarkListTasks(b *testing.B) {
+	s := benchStore(b, benchTasks, 0)
+	b.Run("uncached", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := uncached(s).ListTasks("", "", "", ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		s.ListTasks("", "", "", "")
+		for b.Loop() {
+			if _, err := s.ListTasks("", "", "", ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStatusSummary(b *testing.B) {
+	s := benchStore(b, benchTasks, 0)
+	summary := func(s *FileStore) {
+		if _, err := s.TaskCountsByStatus(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.MilestoneInfo(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("uncached", func(b *testing.B) {
+		for b.Loop() {
+			summary(uncached(s))
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		summary(s)
+		for b.Loop() {
+			summary(s)
+		}
+	})
+}
+
+func BenchmarkListEvents(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping 100k-event fixture in short mode")
+	}
+	s := benchStore(b, 1, benchEvents)
+	b.Run("uncached", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := uncached(s).ListEvents(20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		s.ListEvents(20)
+		for b.Loop() {
+			if _, err := s.ListEvents(20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkListTasksAfterWrite measures the common CLI pattern of one write
+// followed by several reads: only the rewritten file is parsed again.
+func BenchmarkListTasksAfterWrite(b *testing.B) {
+	s := benchStore(b, benchTasks, 0)
+	s.ListTasks("", "", "", "")
+	title := "renamed"
+	for b.Loop() {
+		if _, err := s.UpdateTask(1, TaskUpdate{Title: &title}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.ListTasks("", "", "", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// recordCache keeps the decoded contents of every record file in one
+// directory, keyed by file name. An entry is reused for as long as the file's
+// modification time and size are unchanged, so a long-running process (ghist
+// serve) or a command that lists tasks several times parses each file once.
+// Changes made by other processes — another CLI call, a git checkout — show
+// up as a new stamp and are re-read. The zero value is ready to use.
+//
+// Stat-ing every file still costs one syscall per record, so for a short
+// while after a full check, a load whose directory stamp is unchanged skips
+// the per-file stats: a command that lists tasks three times in a row pays
+// for them once. Every write through the store, a git checkout or an editor
+// saving via rename replaces a directory entry and so changes the directory
+// stamp.
+type recordCache[T any] struct {
+	mu        sync.Mutex
+	entries   map[string]cacheEntry[T]
+	names     []string // entries' keys in directory order
+	dirStamp  fileStamp
+	checkedAt time.Time
+}
+
+// recheckAfter bounds how long a load may trust an unchanged directory stamp
+// without stat-ing the files in it.
+const recheckAfter = time.Second
+
+type cacheEntry[T any] struct {
+	stamp fileStamp
+	value T
+}
+
+// load returns the decoded records in dir in directory order, re-reading
+// only files whose stamp changed since the last call. Each value is passed
+// through clone so callers cannot alias the cached copy's slices or pointers.
+// kind is used in errors.
+func (c *recordCache[T]) load(b backend, dir, kind string, clone func(T) T) ([]T, error) {
+	dirInfo, err := b.stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing %ss: %w", kind, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries != nil && stampOf(dirInfo) == c.dirStamp && time.Since(c.checkedAt) < recheckAfter {
+		out := make([]T, 0, len(c.names))
+		for _, name := range c.names {
+			out = append(out, clone(c.entries[name].value))
+		}
+		return out, nil
+	}
+
+	checkedAt := time.Now()
+	entries, err := b.readDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing %ss: %w", kind, err)
+	}
+	fresh := make(map[string]cacheEntry[T], len(entries))
+	names := make([]string, 0, len(entries))
+	out := make([]T, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if os.IsNotExist(err) {
+			continue // removed since ReadDir
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s file %s: %w", kind, e.Name(), err)
+		}
+		stamp := stampOf(info)
+		entry, ok := c.entries[e.Name()]
+		if !ok || entry.stamp != stamp {
+			data, err := b.readFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s file %s: %w", kind, e.Name(), err)
+			}
+			entry = cacheEntry[T]{stamp: stamp}
+			if err := json.Unmarshal(data, &entry.value); err != nil {
+				return nil, fmt.Errorf("parsing %s file %s: %w", kind, e.Name(), err)
+			}
+		}
+		fresh[e.Name()] = entry
+		names = append(names, e.Name())
+		out = append(out, clone(entry.value))
+	}
+	c.entries, c.names = fresh, names
+	c.dirStamp, c.checkedAt = stampOf(dirInfo), checkedAt
+	return out, nil
+}
+
+// invalidate drops the entry for a file this process has written or removed,
+// so a rewrite within the file system's timestamp resolution that keeps the
+// size unchanged is still seen.
+func (c *recordCache[T]) invalidate(name string) {
+	c.mu.Lock()
+	delete(c.entries, name)
+	c.checkedAt = time.Time{}
+	c.mu.Unlock()
+}
+
+func cloneTask(t models.Task) models.Task {
+	t.Labels = slices.Clone(t.Labels)
+	t.BlockedBy = slices.Clone(t.BlockedBy)
+	t.Blocks = slices.Clone(t.Blocks)
+	if t.ParentID != nil {
+		p := *t.ParentID
+		t.ParentID = &p
+	}
+	if t.StartedAt != nil {
+		at := *t.StartedAt
+		t.StartedAt = &at
+	}
+	if t.CompletedAt != nil {
+		at := *t.CompletedAt
+		t.CompletedAt = &at
+	}
+	return t
+}
+
+func cloneEvent(e models.Event) models.Event {
+	if e.TaskID != nil {
+		id := *e.TaskID
+		e.TaskID = &id
+	}
+	return e
+}
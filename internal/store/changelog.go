@@ -0,0 +1,43 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// ChangelogTasks returns the done tasks, archived ones included, in
+// milestone (any milestone if it is empty) that were finished after since
+// (at any time if it is zero), in the order they were finished. A subtask is
+// left out when its parent is listed, so a feature appears once rather than
+// once per step.
+func (s *FileStore) ChangelogTasks(milestone string, since time.Time) ([]models.Task, error) {
+	tasks, err := s.reportTasks(milestone, false)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[int64]bool)
+	var done []models.Task
+	for _, t := range tasks {
+		if wf.IsDone(t.Status) && t.CompletedAt != nil && (since.IsZero() || t.CompletedAt.After(since)) {
+			listed[t.ID] = true
+			done = append(done, t)
+		}
+	}
+	done = slices.DeleteFunc(done, func(t models.Task) bool {
+		return t.ParentID != nil && listed[*t.ParentID]
+	})
+	slices.SortStableFunc(done, func(a, b models.Task) int {
+		if c := a.CompletedAt.Compare(*b.CompletedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return done, nil
+}
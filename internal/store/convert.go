@@ -0,0 +1,180 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ConvertReport describes a backend conversion.
+type ConvertReport struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Files int    `json:"files"`
+	// Backup is where the source backend's data was moved, relative to
+	// .ghist/.
+	Backup string `json:"backup"`
+}
+
+// Convert moves every record of the store rooted at ghistDir into the
+// backend named to (BackendJSON or BackendSQLite) and switches settings.json
+// over to it. The old copy is moved under backups/ rather than deleted. It
+// refuses to overwrite a target that already holds records.
+func Convert(ghistDir, to string) (*ConvertReport, error) {
+	if to != BackendJSON && to != BackendSQLite {
+		return nil, invalid(fmt.Errorf("unknown backend %q (want %s or %s)", to, BackendJSON, BackendSQLite))
+	}
+	s, err := Open(ghistDir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	from := s.fs.name()
+	if from == to {
+		return nil, invalid(fmt.Errorf("the store already uses the %s backend", to))
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	dst, err := openBackend(ghistDir, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEmpty(ghistDir, dst); err != nil {
+		dst.close()
+		return nil, err
+	}
+	report := &ConvertReport{From: from, To: to}
+	if report.Files, err = s.copyData(dst); err != nil {
+		dst.close()
+		discardTarget(ghistDir, to)
+		return nil, err
+	}
+	if err := dst.close(); err != nil {
+		return nil, err
+	}
+
+	// Switching the setting is the commit point: until then the source is
+	// untouched and still in use.
+	st, err := s.readSettings()
+	if err != nil {
+		return nil, err
+	}
+	st.Backend = to
+	if to == BackendJSON {
+		st.Backend = ""
+	}
+	if err := s.writeSettings(st); err != nil {
+		return nil, fmt.Errorf("switching backend: %w", err)
+	}
+	os.Remove(filepath.Join(ghistDir, indexDir, "search.json"))
+
+	if report.Backup, err = s.backupSource(); err != nil {
+		return report, fmt.Errorf("converted, but moving the old %s data aside failed: %w", from, err)
+	}
+	return report, nil
+}
+
+// checkEmpty refuses a conversion target that already holds records, such
+// as the ghist.db left behind by an earlier conversion.
+func checkEmpty(ghistDir string, dst backend) error {
+	for _, dir := range dataDirs {
+		entries, err := dst.readDir(filepath.Join(ghistDir, dir))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if len(entries) > 0 {
+			return fmt.Errorf("the %s backend already holds data in %s/; move it away first", dst.name(), dir)
+		}
+	}
+	return nil
+}
+
+// copyData copies every record file into dst and returns how many it copied.
+// Callers must hold the store lock.
+func (s *FileStore) copyData(dst backend) (int, error) {
+	n := 0
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := s.fs.readDir(dir)
+		if err != nil {
+			return err
+		}
+		if err := dst.mkdirAll(dir); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if e.IsDir() {
+				if err := walk(path); err != nil {
+					return err
+				}
+				continue
+			}
+			if filepath.Ext(e.Name()) == ".tmp" {
+				continue
+			}
+			data, err := s.fs.readFile(path)
+			if err != nil {
+				return err
+			}
+			if err := dst.writeFile(path, data); err != nil {
+				return fmt.Errorf("copying %s: %w", path, err)
+			}
+			n++
+		}
+		return nil
+	}
+	for _, dir := range dataDirs {
+		err := walk(filepath.Join(s.root, dir))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// discardTarget removes what a failed conversion wrote to the to backend,
+// which was empty when it started.
+func discardTarget(ghistDir, to string) {
+	if to == BackendSQLite {
+		os.Remove(filepath.Join(ghistDir, sqliteFile))
+		return
+	}
+	for _, dir := range dataDirs {
+		os.RemoveAll(filepath.Join(ghistDir, dir))
+	}
+}
+
+// backupSource moves the source backend's data under backups/ once the
+// store has switched away from it, returning where it went.
+func (s *FileStore) backupSource() (string, error) {
+	stamp := time.Now().UTC().Format("20060102-150405")
+	if err := os.MkdirAll(filepath.Join(s.root, backupsDir), 0755); err != nil {
+		return "", err
+	}
+	if s.fs.name() == BackendSQLite {
+		if err := s.fs.close(); err != nil {
+			return "", err
+		}
+		rel := filepath.Join(backupsDir, "ghist-"+stamp+".db")
+		return filepath.ToSlash(rel), os.Rename(filepath.Join(s.root, sqliteFile), filepath.Join(s.root, rel))
+	}
+	rel := filepath.Join(backupsDir, "json-"+stamp)
+	if err := os.MkdirAll(filepath.Join(s.root, rel), 0755); err != nil {
+		return "", err
+	}
+	for _, dir := range dataDirs {
+		err := os.Rename(filepath.Join(s.root, dir), filepath.Join(s.root, rel, dir))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return filepath.ToSlash(rel), nil
+}
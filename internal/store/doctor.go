@@ -0,0 +1,383 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Kinds of problem reported by Doctor.
+const (
+	ProblemMalformedJSON  = "malformed_json"
+	ProblemIDMismatch     = "id_mismatch"
+	ProblemDuplicateID    = "duplicate_id"
+	ProblemDuplicateRef   = "duplicate_ref"
+	ProblemDanglingTask   = "dangling_task_id"
+	ProblemDanglingParent = "dangling_parent_id"
+	ProblemDanglingLink   = "dangling_link"
+	ProblemUnknownStatus  = "unknown_status"
+	ProblemMissingContext = "missing_context"
+	// Two milestone records with one name, e.g. created on two branches.
+	ProblemDuplicateMilestone = "duplicate_milestone"
+)
+
+// Problem is one inconsistency found by Doctor.
+type Problem struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path,omitempty"` // relative to .ghist/
+	Message string `json:"message"`
+	Fixed   bool   `json:"fixed"`
+}
+
+// DoctorReport lists the problems Doctor found, fixed or not.
+type DoctorReport struct {
+	Problems []Problem `json:"problems"`
+}
+
+// Add records a problem.
+func (r *DoctorReport) Add(kind, path, message string, fixed bool) {
+	r.Problems = append(r.Problems, Problem{Kind: kind, Path: path, Message: message, Fixed: fixed})
+}
+
+// Unresolved counts the problems that are still there.
+func (r *DoctorReport) Unresolved() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// CheckSettings reports a settings.json in ghistDir that Open would refuse.
+// Doctor runs on an open store, so callers check this first: until the file
+// parses, no other check can run. It returns nil when the file is missing or
+// parses.
+func CheckSettings(ghistDir string) *Problem {
+	data, err := os.ReadFile(filepath.Join(ghistDir, "settings.json"))
+	if err != nil {
+		return nil
+	}
+	var st settings
+	if err := json.Unmarshal(data, &st); err != nil {
+		return &Problem{Kind: ProblemMalformedJSON, Path: "settings.json", Message: fmt.Sprintf("settings.json is not valid JSON (%v); fix it by hand, then run 'ghist doctor' again to check the records", err)}
+	}
+	return nil
+}
+
+// scannedRecord is one record file as found on disk.
+type scannedRecord[T any] struct {
+	path  string
+	value T
+}
+
+// Doctor checks the record files for problems that the store's normal reads
+// either choke on or silently ignore: unparseable JSON, files whose name
+// disagrees with the ID inside, duplicate IDs and refs, links to tasks that
+// do not exist and statuses the workflow does not know. With fix, it repairs
+// what it can without guessing: files are renamed to match their contents,
+// duplicate refs are reset to the task's own ID, and dangling links are
+// dropped (or re-pointed when the task is known by UID), including in the
+// event archive. Malformed files,
+// duplicate IDs and unknown statuses are only reported.
+func (s *FileStore) Doctor(fix bool) (*DoctorReport, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	report := &DoctorReport{Problems: []Problem{}}
+
+	tasks, err := scanRecords[models.Task](s, report, s.tasksDir(), fix, func(t *models.Task) (int64, string) { return t.ID, t.UID })
+	if err != nil {
+		return nil, err
+	}
+	events, err := scanRecords[models.Event](s, report, s.eventsDir(), fix, func(e *models.Event) (int64, string) { return e.ID, e.UID })
+	if err != nil {
+		return nil, err
+	}
+	if _, err := scanRecords[models.Opportunity](s, report, s.opportunitiesDir(), fix, func(o *models.Opportunity) (int64, string) { return o.ID, o.UID }); err != nil {
+		return nil, err
+	}
+	milestones, err := scanRecords[models.Milestone](s, report, s.milestonesDir(), fix, func(m *models.Milestone) (int64, string) { return m.ID, m.UID })
+	if err != nil {
+		return nil, err
+	}
+	milestoneByName := make(map[string]*models.Milestone)
+	for i := range milestones {
+		m := &milestones[i].value
+		if first, ok := milestoneByName[m.Name]; ok {
+			report.Add(ProblemDuplicateMilestone, relPath(s, milestones[i].path), fmt.Sprintf("milestones %d and %d are both named %q; remove one of the files", first.ID, m.ID, m.Name), false)
+			continue
+		}
+		milestoneByName[m.Name] = m
+	}
+
+	st, err := s.readSettings()
+	if err != nil {
+		return nil, err
+	}
+	prefix := refPrefix(st)
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*models.Task, len(tasks))
+	byUID := make(map[string]*models.Task, len(tasks))
+	for i := range tasks {
+		t := &tasks[i].value
+		byID[t.ID] = t
+		if t.UID != "" {
+			byUID[t.UID] = t
+		}
+	}
+	// A record still stored under the wrong name is left alone: writing it
+	// would create a second file.
+	canFix := func(r *scannedRecord[models.Task]) bool { return fix && r.path == s.taskPath(&r.value) }
+	changed := make(map[int]bool)
+
+	// Duplicate refs: the task whose ref names its own ID keeps it, the
+	// others get their own.
+	byRef := make(map[string][]int)
+	for i := range tasks {
+		if ref := tasks[i].value.RefID; ref != "" {
+			byRef[ref] = append(byRef[ref], i)
+		}
+	}
+	refs := make([]string, 0, len(byRef))
+	for ref, group := range byRef {
+		if len(group) > 1 {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	prefixes := append([]string{prefix}, st.RefPrefixAliases...)
+	for _, ref := range refs {
+		group := byRef[ref]
+		sort.SliceStable(group, func(i, j int) bool {
+			return tasks[group[i]].value.CreatedAt.Before(tasks[group[j]].value.CreatedAt)
+		})
+		keep := group[0]
+		for _, i := range group {
+			if id, err := models.ParseTaskID(ref, prefixes...); err == nil && id == tasks[i].value.ID {
+				keep = i
+				break
+			}
+		}
+		for _, i := range group {
+			if i == keep {
+				continue
+			}
+			t := &tasks[i].value
+			msg := fmt.Sprintf("task %d has ref %s, which task %d also uses", t.ID, ref, tasks[keep].value.ID)
+			fixed := canFix(&tasks[i])
+			if fixed {
+				t.RefID = models.FormatRef(prefix, t.ID)
+				changed[i] = true
+				msg += fmt.Sprintf("; renamed to %s", t.RefID)
+			}
+			report.Add(ProblemDuplicateRef, relPath(s, tasks[i].path), msg, fixed)
+		}
+	}
+
+	for i := range tasks {
+		t := &tasks[i].value
+		path := relPath(s, tasks[i].path)
+		fixed := canFix(&tasks[i])
+		if err := wf.ValidateStatus(t.Status); err != nil {
+			report.Add(ProblemUnknownStatus, path, fmt.Sprintf("task %d: %v", t.ID, err), false)
+		}
+		if t.ParentID != nil && byID[*t.ParentID] == nil {
+			msg := fmt.Sprintf("task %d has parent %d, which does not exist", t.ID, *t.ParentID)
+			if fixed {
+				t.ParentID = nil
+				changed[i] = true
+				msg += "; detached"
+			}
+			report.Add(ProblemDanglingParent, path, msg, fixed)
+		}
+		for _, list := range []*[]int64{&t.Blocks, &t.BlockedBy} {
+			for _, id := range *list {
+				if byID[id] != nil {
+					continue
+				}
+				msg := fmt.Sprintf("task %d links to task %d, which does not exist", t.ID, id)
+				if fixed {
+					*list = removeID(*list, id)
+					changed[i] = true
+					msg += "; link removed"
+				}
+				report.Add(ProblemDanglingLink, path, msg, fixed)
+			}
+		}
+	}
+	for i := range tasks {
+		if changed[i] {
+			if err := s.writeTask(&tasks[i].value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range events {
+		e := &events[i].value
+		if e.TaskID == nil || byID[*e.TaskID] != nil {
+			continue
+		}
+		msg := fmt.Sprintf("event %d is linked to task %d, which does not exist", e.ID, *e.TaskID)
+		fixed := fix && events[i].path == s.eventPath(e)
+		if fixed {
+			if t := byUID[e.TaskUID]; t != nil && e.TaskUID != "" {
+				e.TaskID = &t.ID
+				msg += fmt.Sprintf("; re-linked to task %d by UID", t.ID)
+			} else {
+				e.TaskID, e.TaskUID = nil, ""
+				msg += "; unlinked"
+			}
+			if err := s.writeEvent(e); err != nil {
+				return nil, err
+			}
+		}
+		report.Add(ProblemDanglingTask, relPath(s, events[i].path), msg, fixed)
+	}
+
+	if err := s.checkArchivedEvents(report, fix, byID, byUID); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkArchivedEvents reports archived events linked to a task that no
+// longer exists, neither live nor in the trash. Archives are append-only in
+// normal use; fixing unlinks the events by rewriting their archive file.
+func (s *FileStore) checkArchivedEvents(report *DoctorReport, fix bool, byID map[int64]*models.Task, byUID map[string]*models.Task) error {
+	trash, err := s.readTrash()
+	if err != nil {
+		return err
+	}
+	trashedID := make(map[int64]bool, len(trash))
+	trashedUID := make(map[string]bool, len(trash))
+	for _, e := range trash {
+		trashedID[e.Task.ID] = true
+		trashedUID[e.Task.UID] = true
+	}
+	exists := func(e *models.Event) bool {
+		if e.TaskUID != "" {
+			return byUID[e.TaskUID] != nil || trashedUID[e.TaskUID]
+		}
+		return byID[*e.TaskID] != nil || trashedID[*e.TaskID]
+	}
+
+	months, err := s.archiveMonths()
+	if err != nil {
+		return err
+	}
+	for _, month := range months {
+		events, err := s.readArchive(month)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(s.eventArchiveDir(), month+".jsonl")
+		dirty := false
+		for i := range events {
+			e := &events[i]
+			if e.TaskID == nil || exists(e) {
+				continue
+			}
+			msg := fmt.Sprintf("archived event %d is linked to task %d, which does not exist", e.ID, *e.TaskID)
+			if fix {
+				e.TaskID, e.TaskUID = nil, ""
+				dirty = true
+				msg += "; unlinked"
+			}
+			report.Add(ProblemDanglingTask, relPath(s, path), msg, fix)
+		}
+		if dirty {
+			if err := s.writeArchive(path, events); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanRecords reads every record file in dir, reporting files that do not
+// parse, whose name disagrees with the ID and UID inside, or whose ID another
+// file also has. With fix, mismatched files are renamed to match their
+// contents when the right name is free. It returns the records that parsed.
+func scanRecords[T any](s *FileStore, report *DoctorReport, dir string, fix bool, ident func(*T) (int64, string)) ([]scannedRecord[T], error) {
+	entries, err := s.fs.readDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %w", filepath.Base(dir), err)
+	}
+	var out []scannedRecord[T]
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := s.fs.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", e.Name(), err)
+		}
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			report.Add(ProblemMalformedJSON, relPath(s, path), fmt.Sprintf("cannot parse %s: %v", e.Name(), err), false)
+			continue
+		}
+
+		id, uid := ident(&v)
+		if want := recordFileName(id, uid); want != e.Name() {
+			msg := fmt.Sprintf("%s holds id %d", e.Name(), id)
+			if uid != "" {
+				msg += " and uid " + uid
+			}
+			fixed := false
+			if _, err := s.fs.stat(filepath.Join(dir, want)); fix && os.IsNotExist(err) {
+				if err := s.writeFile(filepath.Join(dir, want), data); err != nil {
+					return nil, err
+				}
+				if err := s.removeFile(path); err != nil {
+					return nil, fmt.Errorf("removing %s: %w", e.Name(), err)
+				}
+				path, fixed = filepath.Join(dir, want), true
+				msg += "; renamed to " + want
+			}
+			report.Add(ProblemIDMismatch, relPath(s, path), msg, fixed)
+		}
+		out = append(out, scannedRecord[T]{path: path, value: v})
+	}
+
+	seen := make(map[int64][]string)
+	for _, r := range out {
+		id, _ := ident(&r.value)
+		seen[id] = append(seen[id], filepath.Base(r.path))
+	}
+	ids := make([]int64, 0, len(seen))
+	for id, names := range seen {
+		if len(names) > 1 {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		report.Add(ProblemDuplicateID, filepath.Base(dir), fmt.Sprintf("%d files share id %d (%v); run 'ghist merge-fix'", len(seen[id]), id, seen[id]), false)
+	}
+	return out, nil
+}
+
+func relPath(s *FileStore, path string) string {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
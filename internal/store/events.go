@@ -3,39 +3,70 @@ package store
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
-	"sort"
 	"time"
 
 	"github.com/unnecessary-special-projects/ghist/internal/models"
 )
 
-func (s *Store) eventsDir() string {
+func (s *FileStore) eventsDir() string {
 	return filepath.Join(s.root, "events")
 }
 
-func (s *Store) eventPath(id int64) string {
-	return filepath.Join(s.eventsDir(), fmt.Sprintf("%d.json", id))
+func (s *FileStore) eventPath(e *models.Event) string {
+	return filepath.Join(s.eventsDir(), recordFileName(e.ID, e.UID))
 }
 
-func (s *Store) CreateEvent(typ, message, metadata string, taskID *int64) (*models.Event, error) {
+// CreateEvent records an event by the store's actor; see SetActor.
+func (s *FileStore) CreateEvent(typ, message, metadata string, taskID *int64) (*models.Event, error) {
+	return s.CreateEventAs("", typ, message, metadata, taskID)
+}
+
+// CreateEventAs records an event by actor, or by the store's actor if actor
+// is empty.
+func (s *FileStore) CreateEventAs(actor, typ, message, metadata string, taskID *int64) (*models.Event, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	taskUID := ""
+	// Record the task's UID alongside its display ID so that MergeFix can tell
+	// which of two same-numbered tasks this event belongs to.
+	if taskID != nil {
+		if t, err := s.GetTask(*taskID); err == nil {
+			taskUID = t.UID
+		}
+	}
+	return s.createEvent(actor, typ, message, metadata, taskID, taskUID)
+}
+
+// createEvent allocates an ID and writes a new event; callers must hold the
+// store lock.
+func (s *FileStore) createEvent(actor, typ, message, metadata string, taskID *int64, taskUID string) (*models.Event, error) {
 	if typ == "" {
 		typ = "log"
 	}
 	if metadata == "" {
 		metadata = "{}"
 	}
-	id, err := nextID(s.eventsDir())
+	if actor == "" {
+		actor = s.actor
+	}
+	id, err := s.nextID(s.eventsDir())
 	if err != nil {
 		return nil, fmt.Errorf("getting next id: %w", err)
 	}
 	e := models.Event{
 		ID:        id,
+		UID:       newUID(),
 		Type:      typ,
 		Message:   message,
 		Metadata:  metadata,
 		TaskID:    taskID,
+		TaskUID:   taskUID,
+		Actor:     actor,
 		CreatedAt: time.Now().UTC(),
 	}
 	if err := s.writeEvent(&e); err != nil {
@@ -44,12 +75,22 @@ func (s *Store) CreateEvent(typ, message, metadata string, taskID *int64) (*mode
 	return &e, nil
 }
 
-func (s *Store) GetEvent(id int64) (*models.Event, error) {
-	data, err := os.ReadFile(s.eventPath(id))
+// GetEvent returns an event by ID, looking in the archive if it is no longer
+// in events/.
+func (s *FileStore) GetEvent(id int64) (*models.Event, error) {
+	names, err := s.findRecordFiles(s.eventsDir(), id)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return s.getArchivedEvent(id)
+	}
+	path, err := s.recordFile(s.eventsDir(), "event", id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.fs.readFile(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("event not found")
-		}
 		return nil, fmt.Errorf("reading event %d: %w", id, err)
 	}
 	var e models.Event
@@ -59,82 +100,92 @@ func (s *Store) GetEvent(id int64) (*models.Event, error) {
 	return &e, nil
 }
 
-func (s *Store) ListEvents(limit int) ([]models.Event, error) {
+// ListEvents returns the limit most recent events, including archived ones.
+func (s *FileStore) ListEvents(limit int) ([]models.Event, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	events, err := s.readAllEvents()
+	events, err := s.readEventsWithArchive(limit, func(*models.Event) bool { return true })
 	if err != nil {
 		return nil, err
 	}
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].CreatedAt.After(events[j].CreatedAt)
-	})
 	if len(events) > limit {
 		events = events[:limit]
 	}
 	return events, nil
 }
 
-func (s *Store) ListEventsByTask(taskID int64) ([]models.Event, error) {
-	events, err := s.readAllEvents()
+// ListEventsByActor returns the most recent events written by actor, newest
+// first, including archived ones.
+func (s *FileStore) ListEventsByActor(actor string, limit int) ([]models.Event, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	events, err := s.readEventsWithArchive(limit, func(e *models.Event) bool { return e.Actor == actor })
 	if err != nil {
 		return nil, err
 	}
-	var filtered []models.Event
-	for _, e := range events {
-		if e.TaskID != nil && *e.TaskID == taskID {
-			filtered = append(filtered, e)
-		}
+	if len(events) > limit {
+		events = events[:limit]
 	}
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i].CreatedAt.After(filtered[j].CreatedAt)
+	return events, nil
+}
+
+// ListEventsByTask returns every event linked to a task, newest first,
+// including archived ones. Archived events are never rewritten, so they keep
+// the task_id they were written with; matching by task UID keeps the history
+// of a deleted or renumbered task from attaching to whichever task has its ID
+// now.
+func (s *FileStore) ListEventsByTask(taskID int64) ([]models.Event, error) {
+	uid := ""
+	if t, err := s.GetTask(taskID); err == nil {
+		uid = t.UID
+	}
+	return s.readEventsWithArchive(0, func(e *models.Event) bool {
+		return eventOfTask(e, taskID, uid)
 	})
-	return filtered, nil
 }
 
-func (s *Store) readAllEvents() ([]models.Event, error) {
-	entries, err := os.ReadDir(s.eventsDir())
-	if err != nil {
-		return nil, fmt.Errorf("listing events: %w", err)
-	}
-	var events []models.Event
-	for _, e := range entries {
-		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(s.eventsDir(), e.Name()))
-		if err != nil {
-			return nil, fmt.Errorf("reading event file %s: %w", e.Name(), err)
-		}
-		var ev models.Event
-		if err := json.Unmarshal(data, &ev); err != nil {
-			return nil, fmt.Errorf("parsing event file %s: %w", e.Name(), err)
-		}
-		events = append(events, ev)
-	}
-	return events, nil
+// eventOfTask reports whether e belongs to the task with the given ID and
+// UID: by task_uid if the event has one, else (for events written before
+// UIDs) by task_id.
+func eventOfTask(e *models.Event, id int64, uid string) bool {
+	if e.TaskUID != "" {
+		return e.TaskUID == uid
+	}
+	return e.TaskID != nil && *e.TaskID == id
+}
+
+// readAllEvents returns the events in events/, without the archive.
+func (s *FileStore) readAllEvents() ([]models.Event, error) {
+	return s.eventCache.load(s.fs, s.eventsDir(), "event", cloneEvent)
 }
 
-// clearEventTaskID sets task_id to nil on all events referencing taskID.
-// Used as a cascade when a task is deleted.
-func (s *Store) clearEventTaskID(taskID int64) {
+// clearEventTaskID sets task_id to nil on all events referencing taskID and
+// returns the events it changed. Used as a cascade when a task is deleted;
+// callers must hold the store lock.
+func (s *FileStore) clearEventTaskID(taskID int64) []models.RecordRef {
 	events, err := s.readAllEvents()
 	if err != nil {
-		return
+		return nil
 	}
+	var cleared []models.RecordRef
 	for i := range events {
 		if events[i].TaskID != nil && *events[i].TaskID == taskID {
 			events[i].TaskID = nil
-			s.writeEvent(&events[i]) //nolint:errcheck
+			events[i].TaskUID = ""
+			if s.writeEvent(&events[i]) == nil {
+				cleared = append(cleared, models.RecordRef{ID: events[i].ID, UID: events[i].UID})
+			}
 		}
 	}
+	return cleared
 }
 
-func (s *Store) writeEvent(e *models.Event) error {
+func (s *FileStore) writeEvent(e *models.Event) error {
 	data, err := json.MarshalIndent(e, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling event: %w", err)
 	}
-	return os.WriteFile(s.eventPath(e.ID), data, 0644)
+	return s.writeFile(s.eventPath(e), data)
 }
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockFile       = ".lock"
+	lockRetryDelay = 5 * time.Millisecond
+	lockTimeout    = 30 * time.Second
+)
+
+// lock acquires the store-wide write lock. It serialises writers within this
+// process via s.mu and across processes (CLI, hooks, ghist serve) via the
+// backend's lock. The returned func releases it.
+func (s *FileStore) lock() (func(), error) {
+	s.mu.Lock()
+	release, err := s.fs.lock()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		// Only a command that wrote something pays for compaction.
+		if len(s.indexPending) > 0 {
+			s.autoCompact()
+		}
+		s.flushSearchIndex()
+		release()
+		s.mu.Unlock()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames
+// it over path, so readers never observe a partially written file. The temp
+// file has a .tmp extension and is therefore skipped by directory listings.
+func writeFileAtomic(path string, data []byte) error {
+	dir, base := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file for %s: %w", base, err)
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", base, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", base, err)
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("setting permissions on %s: %w", base, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("renaming %s into place: %w", base, err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The undo journal lives in .ghist/journal/, one file per operation, named by
+// the time it was recorded so that file names sort in order. It is local to
+// the machine and listed in .ghist/.gitignore.
+const journalDir = "journal"
+
+// journalLimit is how many operations the journal keeps.
+const journalLimit = 50
+
+// Operation is one journaled command: the state every file it changed had
+// before it ran, and a fingerprint of the state it left behind.
+type Operation struct {
+	Name  string        `json:"name"`
+	At    time.Time     `json:"at"`
+	Files []journalFile `json:"files"`
+}
+
+type journalFile struct {
+	Path    string `json:"path"` // relative to .ghist/
+	Existed bool   `json:"existed"`
+	Data    []byte `json:"data,omitempty"`
+	// Appended files are only ever appended to, so only their previous
+	// length is kept.
+	Appended bool  `json:"appended,omitempty"`
+	Size     int64 `json:"size,omitempty"`
+	// After is the SHA-256 of the file once the operation finished, or ""
+	// if it was removed. Undo refuses to touch a file that changed since.
+	After string `json:"after"`
+}
+
+// BeginOperation starts recording the files changed through this store under
+// name, until Close writes them to the undo journal. Only the CLI journals;
+// a store that never calls BeginOperation keeps no undo history.
+func (s *FileStore) BeginOperation(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.op = &Operation{Name: name, At: time.Now().UTC()}
+	s.opSeen = make(map[string]bool)
+}
+
+// journalBefore records path's current contents, the first time the current
+// operation touches it. Callers must hold the store lock.
+func (s *FileStore) journalBefore(path string) {
+	rel, ok := s.journalKey(path)
+	if !ok {
+		return
+	}
+	f := journalFile{Path: rel}
+	if data, err := s.fs.readFile(path); err == nil {
+		f.Existed, f.Data = true, data
+	}
+	s.op.Files = append(s.op.Files, f)
+}
+
+// journalAppend records path's current length before the current operation
+// appends to it. Callers must hold the store lock.
+func (s *FileStore) journalAppend(path string) {
+	rel, ok := s.journalKey(path)
+	if !ok {
+		return
+	}
+	f := journalFile{Path: rel, Appended: true}
+	if info, err := s.fs.stat(path); err == nil {
+		f.Existed, f.Size = true, info.Size()
+	}
+	s.op.Files = append(s.op.Files, f)
+}
+
+// journalKey returns path relative to the store root if an operation is being
+// recorded and has not seen path yet.
+func (s *FileStore) journalKey(path string) (string, bool) {
+	if s.op == nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if s.opSeen[rel] {
+		return "", false
+	}
+	s.opSeen[rel] = true
+	return rel, true
+}
+
+// writeFile atomically replaces a store file, journaling what it replaces.
+// Callers must hold the store lock.
+func (s *FileStore) writeFile(path string, data []byte) error {
+	s.journalBefore(path)
+	defer s.noteWrite(path)
+	return s.fs.writeFile(path, data)
+}
+
+// removeFile removes a store file, journaling its contents. Callers must hold
+// the store lock.
+func (s *FileStore) removeFile(path string) error {
+	s.journalBefore(path)
+	defer s.noteWrite(path)
+	return s.fs.remove(path)
+}
+
+// commitOperation writes the current operation to the journal if it changed
+// anything, and drops the oldest entries beyond journalLimit.
+func (s *FileStore) commitOperation() error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	op := s.op
+	s.op, s.opSeen = nil, nil
+	if op == nil || len(op.Files) == 0 {
+		return nil
+	}
+	for i := range op.Files {
+		op.Files[i].After = s.fileHash(filepath.Join(s.root, filepath.FromSlash(op.Files[i].Path)))
+	}
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry: %w", err)
+	}
+	dir := filepath.Join(s.root, journalDir)
+	if err := s.fs.mkdirAll(dir); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+	names, err := s.journalEntries()
+	if err != nil {
+		return err
+	}
+	name := journalEntryName(names)
+	if err := s.fs.writeFile(filepath.Join(dir, name), data); err != nil {
+		return err
+	}
+	names = append(names, name)
+	for len(names) > journalLimit {
+		s.fs.remove(filepath.Join(dir, names[0]))
+		names = names[1:]
+	}
+	return nil
+}
+
+// journalEntryName names a new journal entry so that it sorts after names,
+// the existing ones: by the time in nanoseconds, moved past the newest entry
+// when operations follow each other faster than the clock ticks.
+func journalEntryName(names []string) string {
+	at := time.Now().UnixNano()
+	if len(names) > 0 {
+		last, err := strconv.ParseInt(strings.TrimSuffix(names[len(names)-1], ".json"), 10, 64)
+		if err == nil && last >= at {
+			at = last + 1
+		}
+	}
+	return fmt.Sprintf("%d.json", at)
+}
+
+// journalEntries lists journal file names, oldest first.
+func (s *FileStore) journalEntries() ([]string, error) {
+	entries, err := s.fs.readDir(filepath.Join(s.root, journalDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// fileHash returns the hex SHA-256 of the file at path, or "" if it does not
+// exist.
+func (s *FileStore) fileHash(path string) string {
+	data, err := s.fs.readFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Undo reverts the most recent journaled operation, restoring every file it
+// changed, and returns it. It fails without changing anything if one of those
+// files has been modified since, for example by a later command from another
+// process or a git checkout.
+func (s *FileStore) Undo() (*Operation, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	names, err := s.journalEntries()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	entryPath := filepath.Join(s.root, journalDir, names[len(names)-1])
+	data, err := s.fs.readFile(entryPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("parsing journal entry %s: %w", names[len(names)-1], err)
+	}
+
+	for _, f := range op.Files {
+		if s.fileHash(filepath.Join(s.root, filepath.FromSlash(f.Path))) != f.After {
+			return nil, fmt.Errorf("cannot undo %q: .ghist/%s has changed since", op.Name, f.Path)
+		}
+	}
+	for i := len(op.Files) - 1; i >= 0; i-- {
+		if err := s.restoreJournalFile(op.Files[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.fs.remove(entryPath); err != nil {
+		return nil, fmt.Errorf("removing journal entry: %w", err)
+	}
+	return &op, nil
+}
+
+// restoreJournalFile puts one file back the way the journal recorded it.
+// Callers must hold the store lock.
+func (s *FileStore) restoreJournalFile(f journalFile) error {
+	path := filepath.Join(s.root, filepath.FromSlash(f.Path))
+	defer s.noteWrite(path)
+	switch {
+	case !f.Existed:
+		if err := s.fs.remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", f.Path, err)
+		}
+	case f.Appended:
+		if err := s.fs.truncate(path, f.Size); err != nil {
+			return fmt.Errorf("truncating %s: %w", f.Path, err)
+		}
+	default:
+		if err := s.fs.mkdirAll(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("restoring %s: %w", f.Path, err)
+		}
+		if err := s.fs.writeFile(path, f.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
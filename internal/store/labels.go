@@ -0,0 +1,57 @@
+package store
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// applyLabels sets t's labels from the label fields of u.
+func applyLabels(t *models.Task, u TaskUpdate) error {
+	labels := t.Labels
+	if u.Labels != nil {
+		labels = *u.Labels
+	}
+	labels = append(slices.Clone(labels), u.AddLabels...)
+	labels, err := models.NormalizeLabels(labels)
+	if err != nil {
+		return invalid(err)
+	}
+	remove, err := models.NormalizeLabels(u.RemoveLabels)
+	if err != nil {
+		return invalid(err)
+	}
+	labels = slices.DeleteFunc(labels, func(l string) bool { return slices.Contains(remove, l) })
+	if len(labels) == 0 {
+		labels = nil
+	}
+	t.Labels = labels
+	return nil
+}
+
+// LabelCounts returns every label in use with the number of tasks carrying
+// it, most used first.
+func (s *FileStore) LabelCounts() ([]models.LabelCount, error) {
+	tasks, err := s.ListTasks("", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, t := range tasks {
+		for _, l := range t.Labels {
+			counts[l]++
+		}
+	}
+	out := make([]models.LabelCount, 0, len(counts))
+	for l, n := range counts {
+		out = append(out, models.LabelCount{Label: l, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out, nil
+}
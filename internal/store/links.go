@@ -0,0 +1,243 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// LinkTasks records that blocker blocks blocked, updating both tasks and
+// recording actor (empty for the store's actor) in their history. Links that
+// would create a cycle (including a task blocking itself) are rejected.
+func (s *FileStore) LinkTasks(blocker, blocked int64, actor string) error {
+	if blocker == blocked {
+		return invalid(fmt.Errorf("task %d cannot block itself", blocker))
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	from, err := s.GetTask(blocker)
+	if err != nil {
+		return err
+	}
+	to, err := s.GetTask(blocked)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(from.Blocks, blocked) {
+		return nil
+	}
+
+	tasks, err := s.allTasks()
+	if err != nil {
+		return err
+	}
+	if reachable(tasks, blocked, blocker) {
+		return invalid(fmt.Errorf("cannot make task %d block task %d: task %d already depends on task %d", blocker, blocked, blocker, blocked))
+	}
+
+	now := time.Now().UTC()
+	beforeFrom, beforeTo := *from, *to
+	from.Blocks = appendSorted(from.Blocks, blocked)
+	from.UpdatedAt = now
+	to.BlockedBy = appendSorted(to.BlockedBy, blocker)
+	to.UpdatedAt = now
+	if err := s.writeTask(from); err != nil {
+		return err
+	}
+	if err := s.writeTask(to); err != nil {
+		return err
+	}
+	if err := s.recordLink(actor, models.EventTaskLinked, from, "blocks", beforeFrom.Blocks, from.Blocks, fmt.Sprintf("%s now blocks %s", from.RefID, to.RefID)); err != nil {
+		return err
+	}
+	return s.recordLink(actor, models.EventTaskLinked, to, "blocked_by", beforeTo.BlockedBy, to.BlockedBy, fmt.Sprintf("%s now blocked by %s", to.RefID, from.RefID))
+}
+
+// UnlinkTasks removes a blocker → blocked relation from both tasks, recording
+// actor (empty for the store's actor) in their history.
+func (s *FileStore) UnlinkTasks(blocker, blocked int64, actor string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	from, err := s.GetTask(blocker)
+	if err != nil {
+		return err
+	}
+	to, err := s.GetTask(blocked)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(from.Blocks, blocked) && !slices.Contains(to.BlockedBy, blocker) {
+		return notFound(fmt.Errorf("task %d does not block task %d", blocker, blocked))
+	}
+
+	now := time.Now().UTC()
+	if err := s.unlink(from, "blocks", to, actor, now); err != nil {
+		return err
+	}
+	return s.unlink(to, "blocked_by", from, actor, now)
+}
+
+// unlink removes other from t's blocks or blocked_by field, as named by
+// field, writes t and records the change in its history by actor (empty for
+// the store's actor). Callers must hold the store lock.
+func (s *FileStore) unlink(t *models.Task, field string, other *models.Task, actor string, now time.Time) error {
+	var old, cur []int64
+	var message string
+	if field == "blocks" {
+		old = t.Blocks
+		t.Blocks = removeID(t.Blocks, other.ID)
+		cur = t.Blocks
+		message = fmt.Sprintf("%s no longer blocks %s", t.RefID, other.RefID)
+	} else {
+		old = t.BlockedBy
+		t.BlockedBy = removeID(t.BlockedBy, other.ID)
+		cur = t.BlockedBy
+		message = fmt.Sprintf("%s no longer blocked by %s", t.RefID, other.RefID)
+	}
+	t.UpdatedAt = now
+	if err := s.writeTask(t); err != nil {
+		return err
+	}
+	return s.recordLink(actor, models.EventTaskUnlinked, t, field, old, cur, message)
+}
+
+// recordLink emits the audit event of type typ on t for a change to its
+// blocks or blocked_by field, by actor (empty for the store's actor). Callers
+// must hold the store lock.
+func (s *FileStore) recordLink(actor, typ string, t *models.Task, field string, old, cur []int64, message string) error {
+	meta, err := json.Marshal(models.FieldChange{Field: field, Old: joinIDs(old), New: joinIDs(cur)})
+	if err != nil {
+		return fmt.Errorf("marshaling %s change: %w", field, err)
+	}
+	id := t.ID
+	if _, err := s.createEvent(actor, typ, message, string(meta), &id, t.UID); err != nil {
+		return fmt.Errorf("recording %s change: %w", field, err)
+	}
+	return nil
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// NextTasks returns tasks in the workflow's initial status (todo by default)
+// whose blockers are all done or no longer exist, highest priority first.
+func (s *FileStore) NextTasks() ([]models.Task, error) {
+	tasks, err := s.allTasks()
+	if err != nil {
+		return nil, err
+	}
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
+	status := make(map[int64]string, len(tasks))
+	for _, t := range tasks {
+		status[t.ID] = t.Status
+	}
+
+	var next []models.Task
+	for _, t := range tasks {
+		if t.Status != wf.InitialStatus() || t.Archived {
+			continue
+		}
+		ready := true
+		for _, b := range t.BlockedBy {
+			if st, ok := status[b]; ok && !wf.IsDone(st) {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			next = append(next, t)
+		}
+	}
+
+	sort.SliceStable(next, func(i, j int) bool {
+		return wf.PriorityRank(next[i].Priority) > wf.PriorityRank(next[j].Priority)
+	})
+	return next, nil
+}
+
+// clearTaskLinks removes deleted from the blocks/blocked_by lists of every
+// other task, recording the unlink in each one's history as UnlinkTasks does.
+// Used as a cascade when a task is deleted; callers must hold the store lock.
+func (s *FileStore) clearTaskLinks(deleted *models.Task) error {
+	tasks, err := s.allTasks()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for i := range tasks {
+		t := &tasks[i]
+		if slices.Contains(t.Blocks, deleted.ID) {
+			if err := s.unlink(t, "blocks", deleted, "", now); err != nil {
+				return err
+			}
+		}
+		if slices.Contains(t.BlockedBy, deleted.ID) {
+			if err := s.unlink(t, "blocked_by", deleted, "", now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reachable reports whether target can be reached from start by following
+// blocks edges.
+func reachable(tasks []models.Task, start, target int64) bool {
+	edges := make(map[int64][]int64, len(tasks))
+	for _, t := range tasks {
+		edges[t.ID] = t.Blocks
+	}
+	seen := map[int64]bool{start: true}
+	stack := []int64{start}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if cur == target {
+			return true
+		}
+		for _, n := range edges[cur] {
+			if !seen[n] {
+				seen[n] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+	return false
+}
+
+func appendSorted(ids []int64, id int64) []int64 {
+	ids = append(ids, id)
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+func removeID(ids []int64, id int64) []int64 {
+	out := slices.DeleteFunc(slices.Clone(ids), func(v int64) bool { return v == id })
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryBackend keeps every file in memory, for tests and for programs that
+// embed ghist without a .ghist/ directory. Nothing outlives the process.
+type memoryBackend struct {
+	mu    sync.Mutex
+	clock clock
+	files map[string]*memoryFile
+	dirs  map[string]time.Time // modification time of each directory
+}
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// OpenMemory returns an empty store held entirely in memory.
+func OpenMemory() (*FileStore, error) {
+	b := &memoryBackend{files: make(map[string]*memoryFile), dirs: make(map[string]time.Time)}
+	return open("", b)
+}
+
+func (b *memoryBackend) name() string { return BackendMemory }
+func (b *memoryBackend) close() error { return nil }
+
+// lock has nothing to do: the store's mutex already serialises writers, and
+// no other process can see the files.
+func (b *memoryBackend) lock() (func(), error) { return func() {}, nil }
+
+// isDir reports whether path is a directory. The root always is.
+func (b *memoryBackend) isDir(path string) bool {
+	if path == "." || path == string(filepath.Separator) {
+		return true
+	}
+	_, ok := b.dirs[path]
+	return ok
+}
+
+// touch updates a directory's modification time after an entry in it was
+// added or removed, as the file system does.
+func (b *memoryBackend) touch(dir string) {
+	if _, ok := b.dirs[dir]; ok {
+		b.dirs[dir] = b.clock.now()
+	}
+}
+
+func (b *memoryBackend) readFile(path string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.files[filepath.Clean(path)]
+	if !ok {
+		return nil, notExist("open", path)
+	}
+	return slices.Clone(f.data), nil
+}
+
+func (b *memoryBackend) writeFile(path string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if !b.isDir(filepath.Dir(path)) {
+		return notExist("open", path)
+	}
+	if _, ok := b.files[path]; !ok {
+		b.touch(filepath.Dir(path))
+	}
+	b.files[path] = &memoryFile{data: slices.Clone(data), modTime: b.clock.now()}
+	return nil
+}
+
+func (b *memoryBackend) appendFile(path string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if !b.isDir(filepath.Dir(path)) {
+		return notExist("open", path)
+	}
+	f, ok := b.files[path]
+	if !ok {
+		f = &memoryFile{}
+		b.files[path] = f
+		b.touch(filepath.Dir(path))
+	}
+	f.data = append(slices.Clip(f.data), data...)
+	f.modTime = b.clock.now()
+	return nil
+}
+
+func (b *memoryBackend) truncate(path string, size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.files[filepath.Clean(path)]
+	if !ok {
+		return notExist("truncate", path)
+	}
+	if size < int64(len(f.data)) {
+		f.data = slices.Clone(f.data[:size])
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	f.modTime = b.clock.now()
+	return nil
+}
+
+func (b *memoryBackend) remove(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if _, ok := b.files[path]; ok {
+		delete(b.files, path)
+		b.touch(filepath.Dir(path))
+		return nil
+	}
+	if _, ok := b.dirs[path]; !ok {
+		return notExist("remove", path)
+	}
+	for p := range b.files {
+		if filepath.Dir(p) == path {
+			return &fs.PathError{Op: "remove", Path: path, Err: errors.New("directory not empty")}
+		}
+	}
+	for p := range b.dirs {
+		if filepath.Dir(p) == path {
+			return &fs.PathError{Op: "remove", Path: path, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(b.dirs, path)
+	b.touch(filepath.Dir(path))
+	return nil
+}
+
+func (b *memoryBackend) readDir(path string) ([]fs.DirEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if !b.isDir(path) {
+		return nil, notExist("open", path)
+	}
+	var out []fs.DirEntry
+	for p, f := range b.files {
+		if filepath.Dir(p) == path {
+			out = append(out, fs.FileInfoToDirEntry(&fileInfo{base: filepath.Base(p), size: int64(len(f.data)), modTime: f.modTime}))
+		}
+	}
+	for p, mod := range b.dirs {
+		if filepath.Dir(p) == path && p != path {
+			out = append(out, fs.FileInfoToDirEntry(&fileInfo{base: filepath.Base(p), modTime: mod, dir: true}))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+func (b *memoryBackend) stat(path string) (fs.FileInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if f, ok := b.files[path]; ok {
+		return &fileInfo{base: filepath.Base(path), size: int64(len(f.data)), modTime: f.modTime}, nil
+	}
+	if b.isDir(path) {
+		return &fileInfo{base: filepath.Base(path), modTime: b.dirs[path], dir: true}, nil
+	}
+	return nil, notExist("stat", path)
+}
+
+func (b *memoryBackend) mkdirAll(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var missing []string
+	for p := filepath.Clean(path); !b.isDir(p); p = filepath.Dir(p) {
+		if _, ok := b.files[p]; ok {
+			return &fs.PathError{Op: "mkdir", Path: p, Err: fs.ErrExist}
+		}
+		missing = append(missing, p)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		b.dirs[missing[i]] = b.clock.now()
+		b.touch(filepath.Dir(missing[i]))
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Renumbering records one record that MergeFix moved to a new display ID.
+type Renumbering struct {
+	Kind  string `json:"kind"` // "task", "event", "opportunity" or "milestone"
+	UID   string `json:"uid"`
+	OldID int64  `json:"old_id"`
+	NewID int64  `json:"new_id"`
+}
+
+// MergeFixReport summarises what MergeFix changed (or would change).
+type MergeFixReport struct {
+	Renumbered     []Renumbering `json:"renumbered"`
+	EventsRelinked int           `json:"events_relinked"`
+	// LinksRelinked counts blocks and blocked_by entries re-pointed at a
+	// renumbered task.
+	LinksRelinked int `json:"links_relinked"`
+	// Ambiguous lists links MergeFix could not attribute; see AmbiguousLink.
+	Ambiguous []AmbiguousLink `json:"ambiguous,omitempty"`
+}
+
+// AmbiguousLink is a parent or blocking link to an ID that several tasks had
+// before MergeFix, which it could not attribute to one of them. It is left
+// pointing at the task that kept the ID, for the user to check.
+type AmbiguousLink struct {
+	TaskID int64  `json:"task_id"` // the linking task, by its new ID
+	Field  string `json:"field"`   // "parent_id", "blocks" or "blocked_by"
+	ID     int64  `json:"id"`
+	// Candidates are the new IDs of the tasks that had ID.
+	Candidates []int64 `json:"candidates"`
+}
+
+// recordRef is the identity of one record file, used to detect duplicates.
+type recordRef struct {
+	name      string
+	id        int64
+	uid       string
+	createdAt time.Time
+}
+
+// MergeFix finds records that share a display ID — which happens when two
+// branches each create a task and are then merged — and renumbers all but the
+// oldest of each group to fresh IDs. Events linked to a renumbered task (by
+// task_uid) are rewritten to point at the new ID, and so are blocking links
+// the renumbered task confirms from its side; parent links, which are not
+// mirrored, are reported as ambiguous instead. With dryRun nothing is written
+// and the report describes the changes that would be made.
+func (s *FileStore) MergeFix(dryRun bool) (*MergeFixReport, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	report := &MergeFixReport{}
+	st, err := s.readSettings()
+	if err != nil {
+		return nil, err
+	}
+	prefix := refPrefix(st)
+
+	nextTask, err := s.nextTaskID()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.allTasks()
+	if err != nil {
+		return nil, err
+	}
+	taskMoves, err := s.renumberDuplicates(s.tasksDir(), "task", nextTask, dryRun, func(data []byte, id int64, uid string) ([]byte, error) {
+		var t models.Task
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		if t.RefID == models.FormatRef(prefix, t.ID) || t.RefID == models.FormatRef(models.LegacyRefPrefix, t.ID) {
+			t.RefID = models.FormatRef(prefix, id)
+		}
+		t.ID, t.UID = id, uid
+		return json.MarshalIndent(t, "", "  ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Renumbered = append(report.Renumbered, taskMoves...)
+
+	eventMoves, err := s.renumberDuplicates(s.eventsDir(), "event", 0, dryRun, func(data []byte, id int64, uid string) ([]byte, error) {
+		var e models.Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		e.ID, e.UID = id, uid
+		return json.MarshalIndent(e, "", "  ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Renumbered = append(report.Renumbered, eventMoves...)
+
+	oppMoves, err := s.renumberDuplicates(s.opportunitiesDir(), "opportunity", 0, dryRun, func(data []byte, id int64, uid string) ([]byte, error) {
+		var o models.Opportunity
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, err
+		}
+		o.ID, o.UID = id, uid
+		return json.MarshalIndent(o, "", "  ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Renumbered = append(report.Renumbered, oppMoves...)
+
+	milestoneMoves, err := s.renumberDuplicates(s.milestonesDir(), "milestone", 0, dryRun, func(data []byte, id int64, uid string) ([]byte, error) {
+		var m models.Milestone
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		m.ID, m.UID = id, uid
+		return json.MarshalIndent(m, "", "  ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Renumbered = append(report.Renumbered, milestoneMoves...)
+
+	// Point events at the new task IDs. Events without a task_uid predate
+	// collision-free identities and stay with the task that kept the ID.
+	// Archived events are left as written: ListEventsByTask matches them by
+	// task_uid, which the renumbering does not change.
+	newTaskID := make(map[string]int64)
+	for _, m := range taskMoves {
+		newTaskID[m.UID] = m.NewID
+	}
+	if len(newTaskID) > 0 {
+		events, err := s.readAllEvents()
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			e := &events[i]
+			id, ok := newTaskID[e.TaskUID]
+			if !ok || e.TaskUID == "" || e.TaskID == nil || *e.TaskID == id {
+				continue
+			}
+			e.TaskID = &id
+			report.EventsRelinked++
+			if dryRun {
+				continue
+			}
+			if err := s.writeEvent(e); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.relinkTasks(tasks, newTaskID, dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// relinkTasks re-points links between tasks after renumbering. tasks is every
+// task as it was before, and newID maps the UIDs of renumbered tasks to their
+// new IDs. Links hold only IDs, so one naming an ID that several tasks had is
+// attributed through its mirror: a blocks entry goes to whichever of those
+// tasks lists the linking task in blocked_by, and vice versa. Links that no
+// candidate mirrors, and parent links, are added to report as ambiguous.
+func (s *FileStore) relinkTasks(tasks []models.Task, newID map[string]int64, dryRun bool, report *MergeFixReport) error {
+	shared := make(map[int64][]*models.Task)
+	for i := range tasks {
+		shared[tasks[i].ID] = append(shared[tasks[i].ID], &tasks[i])
+	}
+	finalID := func(t *models.Task) int64 {
+		if id, ok := newID[t.UID]; ok {
+			return id
+		}
+		return t.ID
+	}
+	candidates := func(id int64) []int64 {
+		var ids []int64
+		for _, c := range shared[id] {
+			ids = append(ids, finalID(c))
+		}
+		return ids
+	}
+
+	for i := range tasks {
+		t := &tasks[i]
+		changed := false
+		resolve := func(field string, ids []int64, mirror func(*models.Task) []int64) []int64 {
+			var out []int64
+			for _, id := range ids {
+				if len(shared[id]) < 2 {
+					out = append(out, id)
+					continue
+				}
+				var meant []int64
+				for _, c := range shared[id] {
+					if c.UID != t.UID && slices.Contains(mirror(c), t.ID) {
+						meant = append(meant, finalID(c))
+					}
+				}
+				if len(meant) == 0 {
+					report.Ambiguous = append(report.Ambiguous, AmbiguousLink{TaskID: finalID(t), Field: field, ID: id, Candidates: candidates(id)})
+					out = append(out, id)
+					continue
+				}
+				if len(meant) > 1 || meant[0] != id {
+					report.LinksRelinked++
+					changed = true
+				}
+				out = append(out, meant...)
+			}
+			slices.Sort(out)
+			return slices.Compact(out)
+		}
+		blocks := resolve("blocks", t.Blocks, func(c *models.Task) []int64 { return c.BlockedBy })
+		blockedBy := resolve("blocked_by", t.BlockedBy, func(c *models.Task) []int64 { return c.Blocks })
+		if t.ParentID != nil && len(shared[*t.ParentID]) > 1 {
+			report.Ambiguous = append(report.Ambiguous, AmbiguousLink{TaskID: finalID(t), Field: "parent_id", ID: *t.ParentID, Candidates: candidates(*t.ParentID)})
+		}
+		if !changed || dryRun {
+			continue
+		}
+		// Renumbering rewrote the file, so change the task as it is now.
+		cur, err := s.GetTask(finalID(t))
+		if err != nil {
+			return err
+		}
+		cur.Blocks, cur.BlockedBy = blocks, blockedBy
+		if err := s.writeTask(cur); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renumberDuplicates renumbers every record in dir whose display ID is shared
+// with an older record, to IDs from next (or above the highest in dir)
+// upwards. rewrite re-encodes a record's JSON with its new ID and UID.
+// Records without a UID are given one so they can be renamed.
+func (s *FileStore) renumberDuplicates(dir, kind string, next int64, dryRun bool, rewrite func(data []byte, id int64, uid string) ([]byte, error)) ([]Renumbering, error) {
+	entries, err := s.fs.readDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+
+	groups := make(map[int64][]recordRef)
+	max := next - 1
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		id, uid, ok := parseRecordFileName(e.Name())
+		if !ok {
+			continue
+		}
+		if id > max {
+			max = id
+		}
+		ref := recordRef{name: e.Name(), id: id, uid: uid}
+		data, err := s.fs.readFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s file %s: %w", kind, e.Name(), err)
+		}
+		var stamp struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		if err := json.Unmarshal(data, &stamp); err != nil {
+			return nil, fmt.Errorf("parsing %s file %s: %w", kind, e.Name(), err)
+		}
+		ref.createdAt = stamp.CreatedAt
+		groups[id] = append(groups[id], ref)
+	}
+
+	ids := make([]int64, 0, len(groups))
+	for id, refs := range groups {
+		if len(refs) > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var moves []Renumbering
+	for _, id := range ids {
+		refs := groups[id]
+		sort.Slice(refs, func(i, j int) bool {
+			if !refs[i].createdAt.Equal(refs[j].createdAt) {
+				return refs[i].createdAt.Before(refs[j].createdAt)
+			}
+			return refs[i].uid < refs[j].uid
+		})
+		for _, ref := range refs[1:] {
+			max++
+			uid := ref.uid
+			if uid == "" {
+				uid = newUID()
+			}
+			moves = append(moves, Renumbering{Kind: kind, UID: uid, OldID: id, NewID: max})
+			if dryRun {
+				continue
+			}
+			oldPath := filepath.Join(dir, ref.name)
+			data, err := s.fs.readFile(oldPath)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s file %s: %w", kind, ref.name, err)
+			}
+			data, err = rewrite(data, max, uid)
+			if err != nil {
+				return nil, fmt.Errorf("rewriting %s file %s: %w", kind, ref.name, err)
+			}
+			newPath := filepath.Join(dir, recordFileName(max, uid))
+			if err := s.writeFile(newPath, data); err != nil {
+				return nil, err
+			}
+			if err := s.removeFile(oldPath); err != nil {
+				return nil, fmt.Errorf("removing %s file %s: %w", kind, ref.name, err)
+			}
+		}
+	}
+	return moves, nil
+}
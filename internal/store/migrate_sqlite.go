@@ -16,6 +16,8 @@ import (
 // MigrateSQLiteToJSON migrates data from a legacy ghist.sqlite to individual
 // JSON files. It is idempotent: if ghist.sqlite does not exist it returns nil.
 // After a successful migration ghist.sqlite is renamed to ghist.sqlite.bak.
+// Open runs it as schema migration 1; records are written in the legacy
+// "<id>.json" layout and upgraded by the migrations that follow.
 func MigrateSQLiteToJSON(ghistDir string) error {
 	sqlitePath := filepath.Join(ghistDir, "ghist.sqlite")
 	if _, err := os.Stat(sqlitePath); os.IsNotExist(err) {
@@ -51,8 +53,6 @@ func MigrateSQLiteToJSON(ghistDir string) error {
 	if err := os.Rename(sqlitePath, bakPath); err != nil {
 		return fmt.Errorf("renaming sqlite to .bak: %w", err)
 	}
-
-	fmt.Println("  Migrated ghist.sqlite → JSON files (backup kept at ghist.sqlite.bak)")
 	return nil
 }
 
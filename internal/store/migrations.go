@@ -0,0 +1,393 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// backupsDir holds copies of the files each migration changed, under
+// backups/v<version>-<name>/, mirroring their paths in .ghist/.
+const backupsDir = "backups"
+
+// migration upgrades .ghist/ from schema version-1 to version. apply must be
+// idempotent, since a run interrupted part-way is retried in full the next
+// time the store is opened, and must back up every file it changes or
+// removes before touching it. It returns a one-line summary of what it
+// changed, or "" if there was nothing to do.
+type migration struct {
+	version int
+	name    string
+	apply   func(s *FileStore, b *migrationBackup) (string, error)
+}
+
+// migrations lists every schema change in order. Append new ones at the end;
+// never renumber or remove one, as stores record the last version applied.
+//
+// A field that is simply empty on older records needs no migration of its
+// own: the actor on events, a task's assignee and labels, and the
+// milestones/ directory, which Open creates. Fields derived from other data
+// are filled in here, and also at read time for records that arrive later
+// from a branch still on an older ghist (see reportTasks).
+var migrations = []migration{
+	{1, "sqlite-to-json", migrateSQLite},
+	{2, "record-uids", migrateRecordUIDs},
+	{3, "plan-history", migratePlanHistory},
+	{4, "status-times", migrateStatusTimes},
+}
+
+// SchemaVersion is the layout version of .ghist/ this build reads and writes.
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// MigrationResult describes one migration run by Open.
+type MigrationResult struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// Summary says what changed; it is empty when the store was already in
+	// shape and the migration only bumped the version.
+	Summary string `json:"summary,omitempty"`
+	// Backup is where the changed files were copied, relative to .ghist/.
+	Backup string `json:"backup,omitempty"`
+}
+
+// Migrations returns the migrations Open ran on this store, oldest first.
+func (s *FileStore) Migrations() []MigrationResult {
+	return s.migrated
+}
+
+// migrate brings the store up to SchemaVersion, recording the new version in
+// settings.json after each migration so an interrupted run resumes where it
+// stopped.
+func (s *FileStore) migrate() error {
+	st, err := s.readSettings()
+	if err != nil {
+		return err
+	}
+	if st.SchemaVersion > SchemaVersion {
+		return fmt.Errorf(".ghist/ uses schema version %d, but this ghist only knows up to %d; upgrade ghist", st.SchemaVersion, SchemaVersion)
+	}
+	if st.SchemaVersion == SchemaVersion {
+		return nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, m := range migrations {
+		// Re-read each time: another process may have migrated while we
+		// waited for the lock, and each step bumps the version.
+		st, err := s.readSettings()
+		if err != nil {
+			return err
+		}
+		if m.version <= st.SchemaVersion {
+			continue
+		}
+		b := &migrationBackup{s: s, dir: filepath.Join(s.root, backupsDir, fmt.Sprintf("v%d-%s", m.version, m.name))}
+		if err := b.saveSettings(); err != nil {
+			return err
+		}
+		summary, err := m.apply(s, b)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		st.SchemaVersion = m.version
+		if err := s.writeSettings(st); err != nil {
+			return err
+		}
+		result := MigrationResult{Version: m.version, Name: m.name, Summary: summary}
+		if b.used {
+			result.Backup = relPath(s, b.dir)
+		}
+		s.migrated = append(s.migrated, result)
+	}
+	return nil
+}
+
+// migrationBackup copies files aside before a migration changes them.
+type migrationBackup struct {
+	s    *FileStore
+	dir  string
+	used bool
+}
+
+// save copies path into the backup directory unless an earlier, interrupted
+// run of the same migration already did, so the backup always holds the
+// file as it was before the migration first touched it. A missing path is
+// not an error.
+func (b *migrationBackup) save(path string) error {
+	rel, err := filepath.Rel(b.s.root, path)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(b.dir, rel)
+	if _, err := b.s.fs.stat(dst); err == nil {
+		b.used = true
+		return nil
+	}
+	data, err := b.s.fs.readFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("backing up %s: %w", rel, err)
+	}
+	if err := b.s.fs.mkdirAll(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	if err := b.s.fs.writeFile(dst, data); err != nil {
+		return fmt.Errorf("backing up %s: %w", rel, err)
+	}
+	b.used = true
+	return nil
+}
+
+// saveSettings copies settings.json, which every migration rewrites to record
+// its version. It leaves used alone: Backup tells the user where the records
+// a migration changed went, and settings.json is always there.
+func (b *migrationBackup) saveSettings() error {
+	used := b.used
+	err := b.save(b.s.settingsPath())
+	b.used = used
+	return err
+}
+
+// migrateSQLite imports a legacy ghist.sqlite. The database itself is kept as
+// ghist.sqlite.bak, which serves as the backup.
+func migrateSQLite(s *FileStore, b *migrationBackup) (string, error) {
+	if _, err := s.fs.stat(filepath.Join(s.root, "ghist.sqlite")); os.IsNotExist(err) {
+		return "", nil
+	}
+	if s.fs.name() != BackendJSON {
+		return "", fmt.Errorf("ghist.sqlite can only be imported into the %s backend", BackendJSON)
+	}
+	if err := MigrateSQLiteToJSON(s.root); err != nil {
+		return "", err
+	}
+	return "imported ghist.sqlite into JSON records (original kept as ghist.sqlite.bak)", nil
+}
+
+// migrateRecordUIDs gives records from before UIDs existed one, renaming
+// "<id>.json" to "<id>-<uid>.json", fills in task_uid on events linked to a
+// task, and moves plan revisions kept under the task's ID to its UID.
+func migrateRecordUIDs(s *FileStore, b *migrationBackup) (string, error) {
+	taskUIDs := make(map[int64]string)
+	movedPlans := make(map[int64]string)
+	tasks, err := rewriteRecords(s, b, s.tasksDir(), func(t *models.Task) (int64, string, bool) {
+		changed := false
+		if t.UID == "" {
+			t.UID = newUID()
+			movedPlans[t.ID] = t.UID
+			changed = true
+		}
+		if _, dup := taskUIDs[t.ID]; dup {
+			taskUIDs[t.ID] = "" // ambiguous until merge-fix runs
+		} else {
+			taskUIDs[t.ID] = t.UID
+		}
+		return t.ID, t.UID, changed
+	})
+	if err != nil {
+		return "", err
+	}
+	events, err := rewriteRecords(s, b, s.eventsDir(), func(e *models.Event) (int64, string, bool) {
+		changed := false
+		if e.UID == "" {
+			e.UID = newUID()
+			changed = true
+		}
+		if e.TaskID != nil && e.TaskUID == "" && taskUIDs[*e.TaskID] != "" {
+			e.TaskUID = taskUIDs[*e.TaskID]
+			changed = true
+		}
+		return e.ID, e.UID, changed
+	})
+	if err != nil {
+		return "", err
+	}
+	opps, err := rewriteRecords(s, b, s.opportunitiesDir(), func(o *models.Opportunity) (int64, string, bool) {
+		changed := o.UID == ""
+		if changed {
+			o.UID = newUID()
+		}
+		return o.ID, o.UID, changed
+	})
+	if err != nil {
+		return "", err
+	}
+
+	plans := 0
+	for id, uid := range movedPlans {
+		moved, err := movePlanDir(s, b, strconv.FormatInt(id, 10), uid)
+		if err != nil {
+			return "", err
+		}
+		if moved {
+			plans++
+		}
+	}
+
+	if tasks+events+opps == 0 {
+		return "", nil
+	}
+	summary := fmt.Sprintf("rewrote %d task, %d event and %d opportunity file(s) with UIDs", tasks, events, opps)
+	if plans > 0 {
+		summary += fmt.Sprintf(" and moved %d plan history folder(s)", plans)
+	}
+	return summary, nil
+}
+
+// rewriteRecords passes every record in dir to update and rewrites the ones
+// it changes under their proper file name, backing up the original first.
+// Files that do not parse are left for 'ghist doctor'. It returns how many
+// files it rewrote.
+func rewriteRecords[T any](s *FileStore, b *migrationBackup, dir string, update func(*T) (id int64, uid string, changed bool)) (int, error) {
+	entries, err := s.fs.readDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("reading directory %s: %w", filepath.Base(dir), err)
+	}
+	n := 0
+	for _, e := range entries {
+		if _, _, ok := parseRecordFileName(e.Name()); !ok || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := s.fs.readFile(path)
+		if err != nil {
+			return n, fmt.Errorf("reading %s: %w", e.Name(), err)
+		}
+		var v T
+		if json.Unmarshal(data, &v) != nil {
+			continue
+		}
+		id, uid, changed := update(&v)
+		if !changed {
+			continue
+		}
+		data, err = json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return n, fmt.Errorf("marshaling %s: %w", e.Name(), err)
+		}
+		if err := b.save(path); err != nil {
+			return n, err
+		}
+		target := filepath.Join(dir, recordFileName(id, uid))
+		if err := s.writeFile(target, data); err != nil {
+			return n, err
+		}
+		if target != path {
+			if err := s.removeFile(path); err != nil {
+				return n, fmt.Errorf("removing %s: %w", e.Name(), err)
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// movePlanDir moves the plan revisions under plans/<from>/ to plans/<to>/.
+func movePlanDir(s *FileStore, b *migrationBackup, from, to string) (bool, error) {
+	src := filepath.Join(s.root, "plans", from)
+	entries, err := s.fs.readDir(src)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading plan revisions: %w", err)
+	}
+	dst := filepath.Join(s.root, "plans", to)
+	if err := s.fs.mkdirAll(dst); err != nil {
+		return false, fmt.Errorf("creating plan revision directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(src, e.Name())
+		data, err := s.fs.readFile(path)
+		if err != nil {
+			return false, fmt.Errorf("reading plan revision %s: %w", e.Name(), err)
+		}
+		if err := b.save(path); err != nil {
+			return false, err
+		}
+		if err := s.writeFile(filepath.Join(dst, e.Name()), data); err != nil {
+			return false, err
+		}
+		if err := s.removeFile(path); err != nil {
+			return false, fmt.Errorf("removing plan revision %s: %w", e.Name(), err)
+		}
+	}
+	s.fs.remove(src)
+	return true, nil
+}
+
+// migratePlanHistory saves the plan of each task written before plan
+// revisions were kept as its first revision, so plan-history shows it.
+// It only adds files, so there is nothing to back up.
+func migratePlanHistory(s *FileStore, b *migrationBackup) (string, error) {
+	// A read-only pass, so unparsable files are skipped rather than fatal.
+	var tasks []models.Task
+	if _, err := rewriteRecords(s, b, s.tasksDir(), func(t *models.Task) (int64, string, bool) {
+		tasks = append(tasks, *t)
+		return t.ID, t.UID, false
+	}); err != nil {
+		return "", err
+	}
+	n := 0
+	for i := range tasks {
+		t := &tasks[i]
+		if t.Plan == "" {
+			continue
+		}
+		revs, err := s.readPlanRevisions(t)
+		if err != nil {
+			return "", err
+		}
+		if len(revs) > 0 {
+			continue
+		}
+		if err := s.fs.mkdirAll(s.planDir(t)); err != nil {
+			return "", fmt.Errorf("creating plan revision directory: %w", err)
+		}
+		if err := s.writePlanRevision(t, t.Plan, t.UpdatedAt); err != nil {
+			return "", err
+		}
+		n++
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("saved the plan of %d task(s) as its first revision", n), nil
+}
+
+// migrateStatusTimes sets started_at and completed_at on tasks from before
+// those fields existed, recovered from their task.status_changed events.
+func migrateStatusTimes(s *FileStore, b *migrationBackup) (string, error) {
+	wf, err := s.Workflow()
+	if err != nil {
+		return "", err
+	}
+	changes, err := s.readEventsWithArchive(0, func(e *models.Event) bool { return e.Type == models.EventTaskStatusChanged })
+	if err != nil {
+		return "", err
+	}
+	slices.Reverse(changes) // oldest first
+	n, err := rewriteRecords(s, b, s.tasksDir(), func(t *models.Task) (int64, string, bool) {
+		started, completed := t.StartedAt, t.CompletedAt
+		backfillStatusTimes(t, wf, changes)
+		return t.ID, t.UID, t.StartedAt != started || t.CompletedAt != completed
+	})
+	if err != nil || n == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("recorded when %d task(s) were started or completed", n), nil
+}
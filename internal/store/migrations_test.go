@@ -0,0 +1,254 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacyFixture is this repository's own .ghist/, which still has the
+// layout from before UIDs, plus the sqlite database it was migrated from.
+const legacyFixture = "../../.ghist"
+
+func copyTree(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatalf("copying %s: %v", src, err)
+	}
+}
+
+func schemaVersion(t *testing.T, s *FileStore) int {
+	t.Helper()
+	st, _ := s.readSettings()
+	return st.SchemaVersion
+}
+
+func TestOpenNewStoreAtCurrentSchema(t *testing.T) {
+	s := newTestStore(t)
+	if got := schemaVersion(t, s); got != SchemaVersion {
+		t.Errorf("expected a new store at schema %d, got %d", SchemaVersion, got)
+	}
+	if ms := s.Migrations(); len(ms) != 0 {
+		t.Errorf("expected no migrations on a new store, got %+v", ms)
+	}
+}
+
+func TestMigrateFromSQLite(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join(legacyFixture, "ghist.sqlite.bak"))
+	if err != nil {
+		t.Fatalf("reading sqlite fixture: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "ghist.sqlite"), data, 0644)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "ghist.sqlite"))
+	if err != nil {
+		t.Fatalf("opening sqlite fixture: %v", err)
+	}
+	for _, q := range []string{
+		`INSERT INTO tasks (id, title, plan, status, ref_id, created_at, updated_at) VALUES (1, 'From sqlite', 'Step one', 'in_progress', 'GHST-1', '2025-01-02T03:04:05Z', '2025-01-03T03:04:05Z')`,
+		`INSERT INTO events (id, type, message, task_id, created_at) VALUES (1, 'decision', 'Chose sqlite', 1, '2025-01-02T04:00:00Z')`,
+		`INSERT INTO opportunities (id, name) VALUES (1, 'Acme')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("seeding sqlite fixture: %v", err)
+		}
+	}
+	db.Close()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	ms := s.Migrations()
+	if len(ms) != SchemaVersion || ms[0].Name != "sqlite-to-json" || ms[0].Summary == "" || ms[1].Summary == "" || ms[2].Summary == "" {
+		t.Fatalf("expected every migration to run and change something, got %+v", ms)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ghist.sqlite.bak")); err != nil {
+		t.Errorf("expected the database to be kept as ghist.sqlite.bak: %v", err)
+	}
+	task, err := s.GetTask(1)
+	if err != nil || task.UID == "" || task.Title != "From sqlite" {
+		t.Fatalf("expected the imported task to have a UID, got %+v (%v)", task, err)
+	}
+	if _, err := os.Stat(s.taskPath(task)); err != nil {
+		t.Errorf("expected the task under its UID file name: %v", err)
+	}
+	if e, _ := s.GetEvent(1); e == nil || e.UID == "" || e.TaskUID != task.UID {
+		t.Errorf("expected the imported event linked by UID, got %+v", e)
+	}
+	if revs, _ := s.ListPlanRevisions(1); len(revs) != 1 || revs[0].Plan != "Step one" {
+		t.Errorf("expected the imported plan as its first revision, got %+v", revs)
+	}
+	if got := schemaVersion(t, s); got != SchemaVersion {
+		t.Errorf("expected schema %d after migrating, got %d", SchemaVersion, got)
+	}
+}
+
+func TestMigrateLegacyJSON(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"tasks", "events"} {
+		copyTree(t, filepath.Join(legacyFixture, sub), filepath.Join(dir, sub))
+	}
+	// A plan revision saved while task 1 still had no UID.
+	os.MkdirAll(filepath.Join(dir, "plans", "1"), 0755)
+	rev := `{"uid": "01JAAAAAAAAAAAAAAAAAAAAAAA", "task_id": 1, "plan": "Old plan", "created_at": "2026-02-24T08:41:24Z"}`
+	os.WriteFile(filepath.Join(dir, "plans", "1", "01JAAAAAAAAAAAAAAAAAAAAAAA.json"), []byte(rev), 0644)
+	original, _ := os.ReadFile(filepath.Join(dir, "tasks", "1.json"))
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	ms := s.Migrations()
+	if len(ms) != SchemaVersion || ms[0].Summary != "" || ms[1].Summary == "" || ms[1].Backup != "backups/v2-record-uids" {
+		t.Fatalf("unexpected migrations %+v", ms)
+	}
+	for _, sub := range []string{"tasks", "events"} {
+		entries, _ := os.ReadDir(filepath.Join(dir, sub))
+		for _, e := range entries {
+			if _, uid, _ := parseRecordFileName(e.Name()); uid == "" {
+				t.Errorf("expected %s/%s to be renamed with a UID", sub, e.Name())
+			}
+		}
+	}
+	if backup, _ := os.ReadFile(filepath.Join(dir, ms[1].Backup, "tasks", "1.json")); !bytes.Equal(backup, original) {
+		t.Errorf("expected the original tasks/1.json in the backup")
+	}
+
+	task, _ := s.GetTask(1)
+	if revs, _ := s.ListPlanRevisions(1); len(revs) != 1 || revs[0].Plan != "Old plan" {
+		t.Errorf("expected plan history moved to plans/%s, got %+v", task.UID, revs)
+	}
+	events, _ := s.ListEventsByTask(1)
+	if len(events) == 0 {
+		t.Fatalf("expected fixture events on task 1")
+	}
+	for _, e := range events {
+		if e.TaskUID != task.UID {
+			t.Errorf("expected event %d to carry task_uid %s, got %q", e.ID, task.UID, e.TaskUID)
+		}
+	}
+
+	// Every migration is idempotent: running them again changes nothing.
+	for _, m := range migrations {
+		b := &migrationBackup{s: s, dir: filepath.Join(t.TempDir(), "backup")}
+		if summary, err := m.apply(s, b); err != nil || summary != "" || b.used {
+			t.Errorf("expected migration %d to be a no-op the second time, got %q (%v)", m.version, summary, err)
+		}
+	}
+	s2, err := Open(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	if ms := s2.Migrations(); len(ms) != 0 {
+		t.Errorf("expected nothing to run on reopen, got %+v", ms)
+	}
+}
+
+func TestMigrateCurrentLayoutWithoutVersion(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Already current"})
+	s.CreateEvent("note", "linked", "{}", &task.ID)
+	before, _ := os.ReadFile(s.taskPath(task))
+	os.WriteFile(s.settingsPath(), []byte("{}"), 0644)
+
+	s2, err := Open(s.root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, m := range s2.Migrations() {
+		if m.Summary != "" || m.Backup != "" {
+			t.Errorf("expected migration %d to find nothing to do, got %+v", m.Version, m)
+		}
+	}
+	if after, _ := os.ReadFile(s.taskPath(task)); !bytes.Equal(before, after) {
+		t.Errorf("expected task file untouched")
+	}
+	if got := schemaVersion(t, s2); got != SchemaVersion {
+		t.Errorf("expected schema %d, got %d", SchemaVersion, got)
+	}
+}
+
+func TestMigrateStatusTimes(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Finished before v4"})
+	status := "in_progress"
+	s.UpdateTask(task.ID, TaskUpdate{Status: &status})
+	status = "done"
+	s.UpdateTask(task.ID, TaskUpdate{Status: &status})
+	// Take the task back to how a v3 ghist wrote it.
+	old, _ := s.GetTask(task.ID)
+	old.StartedAt, old.CompletedAt = nil, nil
+	s.writeTask(old)
+	os.WriteFile(s.settingsPath(), []byte(`{"schema_version": 3}`), 0644)
+
+	s2, err := Open(s.root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s2.Close()
+	ms := s2.Migrations()
+	if len(ms) != 1 || ms[0].Name != "status-times" || ms[0].Summary == "" || ms[0].Backup == "" {
+		t.Fatalf("expected the status-times migration to fill in the task, got %+v", ms)
+	}
+	got, _ := s2.GetTask(task.ID)
+	if got.StartedAt == nil || got.CompletedAt == nil || got.CompletedAt.Before(*got.StartedAt) {
+		t.Errorf("expected started_at and completed_at from the status history, got %v and %v", got.StartedAt, got.CompletedAt)
+	}
+	if saved, _ := os.ReadFile(filepath.Join(s.root, ms[0].Backup, "settings.json")); string(saved) != `{"schema_version": 3}` {
+		t.Errorf("expected settings.json backed up before the version bump, got %q", saved)
+	}
+}
+
+func TestOpenRefusesCorruptSettings(t *testing.T) {
+	for _, backend := range []string{BackendJSON, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			conflicted := "{\n<<<<<<< HEAD\n  \"ref_prefix\": \"API\",\n=======\n  \"ref_prefix\": \"SVC\",\n>>>>>>> feature\n  \"backend\": \"" + backend + "\",\n  \"workflow\": {\"statuses\": [\"open\", \"closed\"]}\n}\n"
+			os.WriteFile(filepath.Join(dir, "settings.json"), []byte(conflicted), 0644)
+
+			_, err := Open(dir)
+			var serr *SettingsError
+			if !errors.As(err, &serr) || !strings.Contains(err.Error(), "ghist doctor") {
+				t.Fatalf("expected a settings error pointing at doctor, got %v", err)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "settings.json")); string(data) != conflicted {
+				t.Errorf("expected settings.json left as it was, got %q", data)
+			}
+			if _, err := os.Stat(filepath.Join(dir, sqliteFile)); !os.IsNotExist(err) {
+				t.Errorf("expected no %s created for a store whose backend is unknown", sqliteFile)
+			}
+		})
+	}
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	s := newTestStore(t)
+	os.WriteFile(s.settingsPath(), []byte(fmt.Sprintf(`{"schema_version": %d}`, SchemaVersion+1)), 0644)
+	if _, err := Open(s.root); err == nil || !strings.Contains(err.Error(), "upgrade ghist") {
+		t.Errorf("expected Open to refuse a newer schema, got %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// MilestoneInput holds the fields for a new milestone record.
+type MilestoneInput struct {
+	Name, Description, StartDate, TargetDate string
+	// Actor is who creates the milestone, recorded on its event; empty means
+	// the store's actor (see SetActor).
+	Actor string
+}
+
+// MilestoneUpdate holds optional fields to update on a milestone record. An
+// empty date clears it.
+type MilestoneUpdate struct {
+	Description *string
+	StartDate   *string
+	TargetDate  *string
+	// Actor is who makes the change, recorded on its event; empty means the
+	// store's actor (see SetActor).
+	Actor string
+}
+
+func (s *FileStore) milestonesDir() string {
+	return filepath.Join(s.root, "milestones")
+}
+
+func (s *FileStore) milestonePath(m *models.Milestone) string {
+	return filepath.Join(s.milestonesDir(), recordFileName(m.ID, m.UID))
+}
+
+// CreateMilestone adds a record for a milestone. Tasks may already use the
+// name; the record then describes the milestone they are in.
+func (s *FileStore) CreateMilestone(in MilestoneInput) (*models.Milestone, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, invalid(fmt.Errorf("milestone name is required"))
+	}
+	existing, err := s.findMilestone(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, invalid(fmt.Errorf("milestone %q already exists", name))
+	}
+	if err := validateMilestoneDates(in.StartDate, in.TargetDate); err != nil {
+		return nil, err
+	}
+
+	id, err := s.nextID(s.milestonesDir())
+	if err != nil {
+		return nil, fmt.Errorf("getting next id: %w", err)
+	}
+	now := time.Now().UTC()
+	m := models.Milestone{
+		ID:          id,
+		UID:         newUID(),
+		Name:        name,
+		Description: in.Description,
+		StartDate:   in.StartDate,
+		TargetDate:  in.TargetDate,
+		State:       models.MilestoneOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.writeMilestone(&m); err != nil {
+		return nil, err
+	}
+	if err := s.recordWrite(in.Actor, models.EventMilestoneCreated, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s created", m.Name)); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetMilestone returns the record for the named milestone.
+func (s *FileStore) GetMilestone(name string) (*models.Milestone, error) {
+	m, err := s.findMilestone(name)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, notFound(fmt.Errorf("milestone %q not found", name))
+	}
+	return m, nil
+}
+
+// ListMilestones returns every milestone record, ordered by ID.
+func (s *FileStore) ListMilestones() ([]models.Milestone, error) {
+	entries, err := s.fs.readDir(s.milestonesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("listing milestones: %w", err)
+	}
+	var ms []models.Milestone
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := s.fs.readFile(filepath.Join(s.milestonesDir(), e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading milestone file %s: %w", e.Name(), err)
+		}
+		var m models.Milestone
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parsing milestone file %s: %w", e.Name(), err)
+		}
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].ID < ms[j].ID
+	})
+	return ms, nil
+}
+
+// UpdateMilestone changes the description and dates of a milestone record.
+func (s *FileStore) UpdateMilestone(name string, u MilestoneUpdate) (*models.Milestone, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	m, err := s.GetMilestone(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Description != nil {
+		m.Description = *u.Description
+	}
+	if u.StartDate != nil {
+		m.StartDate = *u.StartDate
+	}
+	if u.TargetDate != nil {
+		m.TargetDate = *u.TargetDate
+	}
+	if err := validateMilestoneDates(m.StartDate, m.TargetDate); err != nil {
+		return nil, err
+	}
+	m.UpdatedAt = time.Now().UTC()
+	if err := s.writeMilestone(m); err != nil {
+		return nil, err
+	}
+	if err := s.recordWrite(u.Actor, models.EventMilestoneUpdated, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s updated", m.Name)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetMilestoneState opens or closes a milestone, recording actor (empty for
+// the store's actor) on its event. A milestone that tasks use but that has no
+// record gets one.
+func (s *FileStore) SetMilestoneState(name, state, actor string) (*models.Milestone, error) {
+	if state != models.MilestoneOpen && state != models.MilestoneClosed {
+		return nil, invalid(fmt.Errorf("invalid milestone state %q (want %s or %s)", state, models.MilestoneOpen, models.MilestoneClosed))
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	m, err := s.milestoneRecord(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	m.State = state
+	m.ClosedAt = nil
+	if state == models.MilestoneClosed {
+		m.ClosedAt = &now
+	}
+	m.UpdatedAt = now
+	if err := s.writeMilestone(m); err != nil {
+		return nil, err
+	}
+	verb := "reopened"
+	if state == models.MilestoneClosed {
+		verb = "closed"
+	}
+	if err := s.recordWrite(actor, models.EventMilestoneUpdated, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s %s", m.Name, verb)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DeleteMilestone removes a milestone record, recording actor (empty for the
+// store's actor) on its event. It refuses while tasks are still in the
+// milestone; move them with RenameMilestone or MergeMilestone.
+func (s *FileStore) DeleteMilestone(name, actor string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := s.GetMilestone(name)
+	if err != nil {
+		return err
+	}
+	tasks, err := s.milestoneTasks(name)
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 0 {
+		return invalid(fmt.Errorf("milestone %q still has %d task(s); merge it into another milestone instead", name, len(tasks)))
+	}
+	if err := s.removeFile(s.milestonePath(m)); err != nil {
+		return fmt.Errorf("deleting milestone %q: %w", name, err)
+	}
+	if err := s.recordWrite(actor, models.EventMilestoneDeleted, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s deleted", m.Name)); err != nil {
+		return err
+	}
+	return s.replaceInMilestoneOrder(name, "")
+}
+
+// RenameMilestone renames a milestone: its record, its place in the
+// milestone order and every task in it, recording actor (empty for the
+// store's actor) on the events. It returns how many tasks changed.
+func (s *FileStore) RenameMilestone(from, to, actor string) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return 0, invalid(fmt.Errorf("new milestone name is required"))
+	}
+	if to == from {
+		return 0, invalid(fmt.Errorf("milestone is already called %q", to))
+	}
+	m, err := s.findMilestone(from)
+	if err != nil {
+		return 0, err
+	}
+	tasks, err := s.milestoneTasks(from)
+	if err != nil {
+		return 0, err
+	}
+	if m == nil && len(tasks) == 0 {
+		return 0, notFound(fmt.Errorf("milestone %q not found", from))
+	}
+	if exists, err := s.milestoneExists(to); err != nil {
+		return 0, err
+	} else if exists {
+		return 0, invalid(fmt.Errorf("milestone %q already exists; merge into it instead", to))
+	}
+
+	if m != nil {
+		m.Name = to
+		m.UpdatedAt = time.Now().UTC()
+		if err := s.writeMilestone(m); err != nil {
+			return 0, err
+		}
+		if err := s.recordWrite(actor, models.EventMilestoneUpdated, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s renamed to %s", from, to)); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.moveTasks(tasks, to, actor); err != nil {
+		return 0, err
+	}
+	return len(tasks), s.replaceInMilestoneOrder(from, to)
+}
+
+// MergeMilestone moves every task in from into into and removes from's
+// record, recording actor (empty for the store's actor) on the events. into
+// keeps its own record. It returns how many tasks moved.
+func (s *FileStore) MergeMilestone(from, into, actor string) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if from == into {
+		return 0, invalid(fmt.Errorf("cannot merge milestone %q into itself", from))
+	}
+	m, err := s.findMilestone(from)
+	if err != nil {
+		return 0, err
+	}
+	tasks, err := s.milestoneTasks(from)
+	if err != nil {
+		return 0, err
+	}
+	if m == nil && len(tasks) == 0 {
+		return 0, notFound(fmt.Errorf("milestone %q not found", from))
+	}
+	if exists, err := s.milestoneExists(into); err != nil {
+		return 0, err
+	} else if !exists {
+		return 0, invalid(fmt.Errorf("milestone %q not found", into))
+	}
+
+	if err := s.moveTasks(tasks, into, actor); err != nil {
+		return 0, err
+	}
+	if m != nil {
+		if err := s.removeFile(s.milestonePath(m)); err != nil {
+			return 0, fmt.Errorf("deleting milestone %q: %w", from, err)
+		}
+		if err := s.recordWrite(actor, models.EventMilestoneDeleted, m.ID, m.UID, m.Name, fmt.Sprintf("Milestone %s merged into %s", from, into)); err != nil {
+			return 0, err
+		}
+	}
+	return len(tasks), s.replaceInMilestoneOrder(from, into)
+}
+
+// findMilestone returns the record named name, or nil if there is none.
+func (s *FileStore) findMilestone(name string) (*models.Milestone, error) {
+	ms, err := s.ListMilestones()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ms {
+		if ms[i].Name == name {
+			return &ms[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// milestoneRecord returns the record for name, or a new unsaved one if only
+// tasks know the milestone. Callers must hold the lock.
+func (s *FileStore) milestoneRecord(name string) (*models.Milestone, error) {
+	m, err := s.findMilestone(name)
+	if err != nil || m != nil {
+		return m, err
+	}
+	tasks, err := s.milestoneTasks(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, notFound(fmt.Errorf("milestone %q not found", name))
+	}
+	id, err := s.nextID(s.milestonesDir())
+	if err != nil {
+		return nil, fmt.Errorf("getting next id: %w", err)
+	}
+	return &models.Milestone{ID: id, UID: newUID(), Name: name, State: models.MilestoneOpen, CreatedAt: time.Now().UTC()}, nil
+}
+
+// milestoneExists reports whether name has a record or any task, archived or
+// not, is in it.
+func (s *FileStore) milestoneExists(name string) (bool, error) {
+	m, err := s.findMilestone(name)
+	if err != nil || m != nil {
+		return m != nil, err
+	}
+	tasks, err := s.milestoneTasks(name)
+	return len(tasks) > 0, err
+}
+
+// milestoneTasks returns the tasks, archived or not, whose own milestone is
+// name. Subtasks that inherit it from a parent are not included.
+func (s *FileStore) milestoneTasks(name string) ([]models.Task, error) {
+	return s.FilterTasks(TaskFilter{Milestone: name, IncludeArchived: true})
+}
+
+// moveTasks sets the milestone of tasks to name, recording the change on
+// each by actor. Callers must hold the lock.
+func (s *FileStore) moveTasks(tasks []models.Task, name, actor string) error {
+	now := time.Now().UTC()
+	for i := range tasks {
+		t := &tasks[i]
+		before := *t
+		t.Milestone = name
+		t.UpdatedAt = now
+		if err := s.writeTask(t); err != nil {
+			return err
+		}
+		if err := s.recordChanges(&before, t, actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceInMilestoneOrder swaps from for to in the saved milestone order, or
+// drops from if to is empty or already listed. Callers must hold the lock.
+func (s *FileStore) replaceInMilestoneOrder(from, to string) error {
+	st, err := s.readSettings()
+	if err != nil {
+		return err
+	}
+	i := slices.Index(st.MilestoneOrder, from)
+	if i < 0 {
+		return nil
+	}
+	if to == "" || slices.Contains(st.MilestoneOrder, to) {
+		st.MilestoneOrder = slices.Delete(st.MilestoneOrder, i, i+1)
+	} else {
+		st.MilestoneOrder[i] = to
+	}
+	return s.writeSettings(st)
+}
+
+func validateMilestoneDates(start, target string) error {
+	for _, d := range []string{start, target} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(models.DateLayout, d); err != nil {
+			return invalid(fmt.Errorf("invalid date %q: want YYYY-MM-DD", d))
+		}
+	}
+	if start != "" && target != "" && target < start {
+		return invalid(fmt.Errorf("target date %s is before start date %s", target, start))
+	}
+	return nil
+}
+
+func (s *FileStore) writeMilestone(m *models.Milestone) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling milestone: %w", err)
+	}
+	return s.writeFile(s.milestonePath(m), data)
+}
@@ -11,22 +11,40 @@ import (
 	"github.com/unnecessary-special-projects/ghist/internal/models"
 )
 
-func (s *Store) opportunitiesDir() string {
+// OpportunityUpdate holds optional fields to update on an existing opportunity.
+type OpportunityUpdate struct {
+	Name  *string
+	Notes *string
+	// Actor is who makes the change, recorded on its event; empty means the
+	// store's actor (see SetActor).
+	Actor string
+}
+
+func (s *FileStore) opportunitiesDir() string {
 	return filepath.Join(s.root, "opportunities")
 }
 
-func (s *Store) opportunityPath(id int64) string {
-	return filepath.Join(s.opportunitiesDir(), fmt.Sprintf("%d.json", id))
+func (s *FileStore) opportunityPath(o *models.Opportunity) string {
+	return filepath.Join(s.opportunitiesDir(), recordFileName(o.ID, o.UID))
 }
 
-func (s *Store) CreateOpportunity(name, notes string) (*models.Opportunity, error) {
-	id, err := nextID(s.opportunitiesDir())
+// CreateOpportunity adds an opportunity, recording actor (empty for the
+// store's actor) on its event.
+func (s *FileStore) CreateOpportunity(name, notes, actor string) (*models.Opportunity, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	id, err := s.nextID(s.opportunitiesDir())
 	if err != nil {
 		return nil, fmt.Errorf("getting next id: %w", err)
 	}
 	now := time.Now().UTC()
 	o := models.Opportunity{
 		ID:        id,
+		UID:       newUID(),
 		Name:      name,
 		Notes:     notes,
 		CreatedAt: now,
@@ -35,14 +53,21 @@ func (s *Store) CreateOpportunity(name, notes string) (*models.Opportunity, erro
 	if err := s.writeOpportunity(&o); err != nil {
 		return nil, err
 	}
+	if err := s.recordWrite(actor, models.EventOpportunityCreated, o.ID, o.UID, o.Name, fmt.Sprintf("Opportunity %d created: %s", o.ID, o.Name)); err != nil {
+		return nil, err
+	}
 	return &o, nil
 }
 
-func (s *Store) GetOpportunity(id int64) (*models.Opportunity, error) {
-	data, err := os.ReadFile(s.opportunityPath(id))
+func (s *FileStore) GetOpportunity(id int64) (*models.Opportunity, error) {
+	path, err := s.recordFile(s.opportunitiesDir(), "opportunity", id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.fs.readFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("opportunity not found")
+			return nil, notFound(fmt.Errorf("opportunity %d not found", id))
 		}
 		return nil, fmt.Errorf("reading opportunity %d: %w", id, err)
 	}
@@ -53,8 +78,8 @@ func (s *Store) GetOpportunity(id int64) (*models.Opportunity, error) {
 	return &o, nil
 }
 
-func (s *Store) ListOpportunities() ([]models.Opportunity, error) {
-	entries, err := os.ReadDir(s.opportunitiesDir())
+func (s *FileStore) ListOpportunities() ([]models.Opportunity, error) {
+	entries, err := s.fs.readDir(s.opportunitiesDir())
 	if err != nil {
 		return nil, fmt.Errorf("listing opportunities: %w", err)
 	}
@@ -63,7 +88,7 @@ func (s *Store) ListOpportunities() ([]models.Opportunity, error) {
 		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
 			continue
 		}
-		data, err := os.ReadFile(filepath.Join(s.opportunitiesDir(), e.Name()))
+		data, err := s.fs.readFile(filepath.Join(s.opportunitiesDir(), e.Name()))
 		if err != nil {
 			return nil, fmt.Errorf("reading opportunity file %s: %w", e.Name(), err)
 		}
@@ -79,10 +104,57 @@ func (s *Store) ListOpportunities() ([]models.Opportunity, error) {
 	return opps, nil
 }
 
-func (s *Store) writeOpportunity(o *models.Opportunity) error {
+func (s *FileStore) UpdateOpportunity(id int64, u OpportunityUpdate) (*models.Opportunity, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	o, err := s.GetOpportunity(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Name != nil {
+		o.Name = *u.Name
+	}
+	if u.Notes != nil {
+		o.Notes = *u.Notes
+	}
+	o.UpdatedAt = time.Now().UTC()
+
+	if err := s.writeOpportunity(o); err != nil {
+		return nil, err
+	}
+	if err := s.recordWrite(u.Actor, models.EventOpportunityUpdated, o.ID, o.UID, o.Name, fmt.Sprintf("Opportunity %d updated", o.ID)); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DeleteOpportunity removes an opportunity, recording actor (empty for the
+// store's actor) on its event.
+func (s *FileStore) DeleteOpportunity(id int64, actor string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	o, err := s.GetOpportunity(id)
+	if err != nil {
+		return err
+	}
+	if err := s.removeFile(s.opportunityPath(o)); err != nil {
+		return fmt.Errorf("deleting opportunity %d: %w", id, err)
+	}
+	return s.recordWrite(actor, models.EventOpportunityDeleted, o.ID, o.UID, o.Name, fmt.Sprintf("Opportunity %d deleted: %s", o.ID, o.Name))
+}
+
+func (s *FileStore) writeOpportunity(o *models.Opportunity) error {
 	data, err := json.MarshalIndent(o, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling opportunity: %w", err)
 	}
-	return os.WriteFile(s.opportunityPath(o.ID), data, 0644)
+	return s.writeFile(s.opportunityPath(o), data)
 }
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// planDir returns the directory holding t's plan revisions. It is keyed by
+// the task's UID (falling back to its ID for legacy tasks) so revisions
+// follow the task through a merge-fix renumbering.
+func (s *FileStore) planDir(t *models.Task) string {
+	key := t.UID
+	if key == "" {
+		key = strconv.FormatInt(t.ID, 10)
+	}
+	return filepath.Join(s.root, "plans", key)
+}
+
+// recordPlanRevision saves t's current plan as a new revision. If the task
+// already had a plan from before revisions were kept, that plan is saved
+// first so it isn't lost. Callers must hold the store lock.
+func (s *FileStore) recordPlanRevision(t *models.Task, previous string) error {
+	dir := s.planDir(t)
+	if err := s.fs.mkdirAll(dir); err != nil {
+		return fmt.Errorf("creating plan revision directory: %w", err)
+	}
+	revs, err := s.readPlanRevisions(t)
+	if err != nil {
+		return err
+	}
+	if len(revs) == 0 && previous != "" {
+		if err := s.writePlanRevision(t, previous, t.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return s.writePlanRevision(t, t.Plan, t.UpdatedAt)
+}
+
+func (s *FileStore) writePlanRevision(t *models.Task, plan string, at time.Time) error {
+	rev := models.PlanRevision{UID: newUID(), TaskID: t.ID, Plan: plan, CreatedAt: at}
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling plan revision: %w", err)
+	}
+	return s.writeFile(filepath.Join(s.planDir(t), rev.UID+".json"), data)
+}
+
+// ListPlanRevisions returns every saved revision of a task's plan, oldest
+// first.
+func (s *FileStore) ListPlanRevisions(taskID int64) ([]models.PlanRevision, error) {
+	t, err := s.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	return s.readPlanRevisions(t)
+}
+
+// GetPlanRevision returns revision rev (1-based) of a task's plan.
+func (s *FileStore) GetPlanRevision(taskID int64, rev int) (*models.PlanRevision, error) {
+	revs, err := s.ListPlanRevisions(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if rev < 1 || rev > len(revs) {
+		return nil, notFound(fmt.Errorf("task %d has no plan revision %d (%d saved)", taskID, rev, len(revs)))
+	}
+	return &revs[rev-1], nil
+}
+
+// RestorePlanRevision makes revision rev the task's current plan, recording
+// actor (empty for the store's actor) on the change. The restore is itself
+// recorded as a new revision.
+func (s *FileStore) RestorePlanRevision(taskID int64, rev int, actor string) (*models.Task, error) {
+	r, err := s.GetPlanRevision(taskID, rev)
+	if err != nil {
+		return nil, err
+	}
+	return s.UpdateTask(taskID, TaskUpdate{Plan: &r.Plan, Actor: actor})
+}
+
+func (s *FileStore) readPlanRevisions(t *models.Task) ([]models.PlanRevision, error) {
+	dir := s.planDir(t)
+	entries, err := s.fs.readDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing plan revisions: %w", err)
+	}
+	var revs []models.PlanRevision
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := s.fs.readFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading plan revision %s: %w", e.Name(), err)
+		}
+		var r models.PlanRevision
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("parsing plan revision %s: %w", e.Name(), err)
+		}
+		revs = append(revs, r)
+	}
+	// UIDs are time-ordered, so they break ties between equal timestamps.
+	sort.Slice(revs, func(i, j int) bool {
+		if !revs[i].CreatedAt.Equal(revs[j].CreatedAt) {
+			return revs[i].CreatedAt.Before(revs[j].CreatedAt)
+		}
+		return revs[i].UID < revs[j].UID
+	})
+	for i := range revs {
+		revs[i].Rev = i + 1
+		revs[i].TaskID = t.ID
+	}
+	return revs, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// RefPrefix returns the prefix used for new task refs ("GHST" unless the
+// project configured its own).
+func (s *FileStore) RefPrefix() (string, error) {
+	st, err := s.readSettings()
+	if err != nil {
+		return "", err
+	}
+	return refPrefix(st), nil
+}
+
+func refPrefix(st settings) string {
+	if st.RefPrefix == "" {
+		return models.LegacyRefPrefix
+	}
+	return st.RefPrefix
+}
+
+// ParseTaskID resolves a task ref typed by a user — "19", "API-19", or a ref
+// using a former or the legacy prefix — to its numeric ID.
+func (s *FileStore) ParseTaskID(raw string) (int64, error) {
+	st, err := s.readSettings()
+	if err != nil {
+		return 0, err
+	}
+	prefixes := append([]string{refPrefix(st)}, st.RefPrefixAliases...)
+	return models.ParseTaskID(raw, prefixes...)
+}
+
+// ParseCommitRefs finds the task refs in a commit message, under the
+// project's prefix or any former one.
+func (s *FileStore) ParseCommitRefs(msg string) ([]models.CommitRef, error) {
+	st, err := s.readSettings()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := append([]string{refPrefix(st)}, st.RefPrefixAliases...)
+	return models.ParseCommitRefs(msg, prefixes...), nil
+}
+
+// SetRefPrefix changes the prefix used for task refs. Existing tasks whose ref
+// uses the old prefix are rewritten to the new one, and the old prefix is kept
+// as an alias so refs already quoted elsewhere still resolve. It returns the
+// number of tasks rewritten.
+func (s *FileStore) SetRefPrefix(prefix string) (int, error) {
+	prefix, err := models.NormalizeRefPrefix(prefix)
+	if err != nil {
+		return 0, invalid(err)
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	st, err := s.readSettings()
+	if err != nil {
+		return 0, err
+	}
+	old := refPrefix(st)
+	if old == prefix {
+		return 0, nil
+	}
+
+	tasks, err := s.allTasks()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for i := range tasks {
+		t := &tasks[i]
+		if t.RefID != models.FormatRef(old, t.ID) && t.RefID != models.FormatRef(models.LegacyRefPrefix, t.ID) {
+			continue
+		}
+		t.RefID = models.FormatRef(prefix, t.ID)
+		if err := s.writeTask(t); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	if old != models.LegacyRefPrefix && !slices.Contains(st.RefPrefixAliases, old) {
+		st.RefPrefixAliases = append(st.RefPrefixAliases, old)
+	}
+	st.RefPrefixAliases = slices.DeleteFunc(st.RefPrefixAliases, func(p string) bool { return p == prefix })
+	st.RefPrefix = prefix
+	return migrated, s.writeSettings(st)
+}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// Reports count leaf tasks, archived ones included, in the milestone they
+// are in today. Days and weeks follow the local calendar.
+
+// Burndown returns a milestone's remaining work for each day from its start
+// date (or its first task) to today, or to the day it was closed.
+func (s *FileStore) Burndown(milestone string) (*models.Burndown, error) {
+	if milestone == "" {
+		return nil, invalid(fmt.Errorf("milestone is required"))
+	}
+	tasks, err := s.reportTasks(milestone, true)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := s.findMilestone(milestone)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil && len(tasks) == 0 {
+		return nil, notFound(fmt.Errorf("milestone %q not found", milestone))
+	}
+
+	today := day(time.Now())
+	start, end := today, today
+	for _, t := range tasks {
+		if d := day(t.CreatedAt); d.Before(start) {
+			start = d
+		}
+	}
+	var target time.Time
+	if rec != nil {
+		if d, err := time.ParseInLocation(models.DateLayout, rec.StartDate, time.Local); err == nil {
+			start = d
+		}
+		if d, err := time.ParseInLocation(models.DateLayout, rec.TargetDate, time.Local); err == nil {
+			target = d
+		}
+		if rec.ClosedAt != nil && day(*rec.ClosedAt).Before(end) {
+			end = day(*rec.ClosedAt)
+		}
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	b := &models.Burndown{Milestone: milestone, StartDate: start.Format(models.DateLayout), Days: []models.BurndownDay{}}
+	if rec != nil {
+		b.TargetDate = rec.TargetDate
+	}
+	span := daysBetween(start, target)
+	initial := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		next := d.AddDate(0, 0, 1)
+		bd := models.BurndownDay{Date: d.Format(models.DateLayout)}
+		for _, t := range tasks {
+			if !t.CreatedAt.Before(next) {
+				continue
+			}
+			bd.Scope++
+			if t.CompletedAt != nil && t.CompletedAt.Before(next) {
+				bd.Done++
+			}
+		}
+		bd.Remaining = bd.Scope - bd.Done
+		if d.Equal(start) {
+			initial = bd.Remaining
+		}
+		if span > 0 {
+			ideal := math.Max(0, float64(initial)*(1-float64(daysBetween(start, d))/float64(span)))
+			ideal = math.Round(ideal*100) / 100
+			bd.Ideal = &ideal
+		}
+		b.Days = append(b.Days, bd)
+	}
+	return b, nil
+}
+
+// Throughput counts the tasks finished in each of the last weeks weeks,
+// oldest first, ending with the current week. Weeks start on Monday.
+func (s *FileStore) Throughput(weeks int, milestone string) ([]models.ThroughputWeek, error) {
+	if weeks <= 0 {
+		weeks = 8
+	}
+	tasks, err := s.reportTasks(milestone, true)
+	if err != nil {
+		return nil, err
+	}
+	today := day(time.Now())
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	first := monday.AddDate(0, 0, -7*(weeks-1))
+
+	out := make([]models.ThroughputWeek, weeks)
+	for i := range out {
+		out[i].Week = first.AddDate(0, 0, 7*i).Format(models.DateLayout)
+	}
+	for _, t := range tasks {
+		if t.CompletedAt == nil {
+			continue
+		}
+		i := daysBetween(first, day(*t.CompletedAt)) / 7
+		if t.CompletedAt.Before(first) || i >= weeks {
+			continue
+		}
+		out[i].Done++
+	}
+	return out, nil
+}
+
+// LeadTime reports how long finished tasks took from creation to done.
+func (s *FileStore) LeadTime(milestone string) (*models.DurationReport, error) {
+	tasks, err := s.reportTasks(milestone, true)
+	if err != nil {
+		return nil, err
+	}
+	var ds []models.TaskDuration
+	for _, t := range tasks {
+		if t.CompletedAt != nil {
+			ds = append(ds, taskDuration(&t, t.CreatedAt, *t.CompletedAt))
+		}
+	}
+	return models.NewDurationReport(ds), nil
+}
+
+// CycleTime reports how long finished tasks took from the first start of
+// work to done. Tasks that went straight to done are left out.
+func (s *FileStore) CycleTime(milestone string) (*models.DurationReport, error) {
+	tasks, err := s.reportTasks(milestone, true)
+	if err != nil {
+		return nil, err
+	}
+	var ds []models.TaskDuration
+	for _, t := range tasks {
+		if t.StartedAt != nil && t.CompletedAt != nil && !t.CompletedAt.Before(*t.StartedAt) {
+			ds = append(ds, taskDuration(&t, *t.StartedAt, *t.CompletedAt))
+		}
+	}
+	return models.NewDurationReport(ds), nil
+}
+
+func taskDuration(t *models.Task, start, end time.Time) models.TaskDuration {
+	return models.TaskDuration{RefID: t.RefID, Title: t.Title, Start: start, End: end, Days: models.DaysBetween(start, end)}
+}
+
+// reportTasks returns the tasks in milestone (all of them if it is empty),
+// only the leaf tasks if leaves is set, with StartedAt and CompletedAt filled in for tasks written before
+// ghist stamped them: from their status change events, and failing that,
+// a done task is taken to have finished when it was last updated.
+func (s *FileStore) reportTasks(milestone string, leaves bool) ([]models.Task, error) {
+	all, err := s.allTasks()
+	if err != nil {
+		return nil, err
+	}
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*models.Task, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i]
+	}
+	candidates := all
+	if leaves {
+		candidates = leafTasks(all)
+	}
+	var tasks []models.Task
+	for _, t := range candidates {
+		if milestone == "" || effectiveMilestone(byID, &t) == milestone {
+			tasks = append(tasks, t)
+		}
+	}
+
+	unstamped := slices.ContainsFunc(tasks, func(t models.Task) bool {
+		return t.CompletedAt == nil && wf.IsDone(t.Status) || t.StartedAt == nil && t.Status != wf.InitialStatus()
+	})
+	if !unstamped {
+		return tasks, nil
+	}
+	changes, err := s.readEventsWithArchive(0, func(e *models.Event) bool { return e.Type == models.EventTaskStatusChanged })
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(changes) // oldest first
+	for i := range tasks {
+		backfillStatusTimes(&tasks[i], wf, changes)
+	}
+	return tasks, nil
+}
+
+func backfillStatusTimes(t *models.Task, wf models.Workflow, changes []models.Event) {
+	needStart := t.StartedAt == nil
+	needDone := t.CompletedAt == nil && wf.IsDone(t.Status)
+	if !needStart && !needDone {
+		return
+	}
+	var started, completed *time.Time
+	first := true
+	for i := range changes {
+		e := &changes[i]
+		if !eventOfTask(e, t.ID, t.UID) {
+			continue
+		}
+		var c models.FieldChange
+		if err := json.Unmarshal([]byte(e.Metadata), &c); err != nil {
+			continue
+		}
+		if first && wf.IsStarted(c.Old) {
+			started = &t.CreatedAt
+		}
+		first = false
+		if started == nil && wf.IsStarted(c.New) {
+			started = &e.CreatedAt
+		}
+		if wf.IsDone(c.New) {
+			completed = &e.CreatedAt
+		}
+	}
+	if needStart {
+		t.StartedAt = started
+	}
+	if needDone {
+		if completed == nil {
+			completed = &t.UpdatedAt
+		}
+		t.CompletedAt = completed
+	}
+}
+
+// day is the local calendar day t falls on, at midnight.
+func day(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// daysBetween counts calendar days from a to b, both midnights; it is 0 if b
+// is the zero time.
+func daysBetween(a, b time.Time) int {
+	if b.IsZero() {
+		return 0
+	}
+	return int(math.Round(b.Sub(a).Hours() / 24))
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/search"
+)
+
+// searchIndexVersion is bumped whenever the on-disk index format or what it
+// indexes changes, so older index files are rebuilt rather than misread.
+const searchIndexVersion = 1
+
+// indexDir holds local, derived artifacts. It is listed in .ghist/.gitignore.
+const indexDir = "index"
+
+// fileStamp identifies one version of a record file.
+type fileStamp struct {
+	ModTime int64 `json:"mtime"`
+	Size    int64 `json:"size"`
+}
+
+// searchIndex is the persisted full-text index plus the stamps of the files
+// it was built from, keyed like the index documents ("tasks/<name>").
+type searchIndex struct {
+	Version int                  `json:"version"`
+	Stamps  map[string]fileStamp `json:"stamps"`
+	Index   *search.Index        `json:"index"`
+}
+
+func (s *FileStore) searchIndexPath() string {
+	return filepath.Join(s.root, indexDir, "search.json")
+}
+
+// noteWrite records that the file at path was written or removed. For task
+// and event files, the cache entry is dropped and the search index is updated
+// when the store lock is released. Callers must hold the store lock.
+func (s *FileStore) noteWrite(path string) {
+	switch dir, name := filepath.Split(path); filepath.Clean(dir) {
+	case s.tasksDir():
+		s.taskCache.invalidate(name)
+	case s.eventsDir():
+		s.eventCache.invalidate(name)
+	default:
+		return
+	}
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return
+	}
+	if s.indexPending == nil {
+		s.indexPending = make(map[string]bool)
+	}
+	s.indexPending[filepath.ToSlash(rel)] = true
+}
+
+// flushSearchIndex applies the writes noted since the lock was taken to the
+// search index. Failures are not reported: Search reconciles the index with
+// the record files before every query, so a missed update heals itself.
+// Callers must hold the store lock.
+func (s *FileStore) flushSearchIndex() {
+	if len(s.indexPending) == 0 {
+		return
+	}
+	pending := s.indexPending
+	s.indexPending = nil
+
+	idx := s.loadSearchIndex()
+	for key := range pending {
+		s.indexFile(idx, key)
+	}
+	s.saveSearchIndex(idx) //nolint:errcheck
+}
+
+// Search runs a full-text query over task titles, descriptions, plans and
+// labels and over logged event messages. Changes made outside the store
+// (a git pull, a hand edit) are picked up before searching. A query against
+// an up-to-date index only reads; the store lock is taken just to bring the
+// index up to date.
+func (s *FileStore) Search(q string, limit int) ([]search.Result, error) {
+	s.mu.Lock()
+	idx := s.loadSearchIndex()
+	stale, err := s.reconcileSearchIndex(idx, true)
+	if err == nil && !stale {
+		results := idx.Index.Search(q, limit)
+		s.mu.Unlock()
+		return results, nil
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	idx = s.loadSearchIndex()
+	changed, err := s.reconcileSearchIndex(idx, false)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := s.saveSearchIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+	return idx.Index.Search(q, limit), nil
+}
+
+// loadSearchIndex returns the persisted search index, or an empty one if it
+// is missing, unreadable or from another version. Callers must hold s.mu.
+func (s *FileStore) loadSearchIndex() *searchIndex {
+	info, err := s.fs.stat(s.searchIndexPath())
+	if err == nil && s.searchIdx != nil && stampOf(info) == s.searchStamp {
+		return s.searchIdx
+	}
+	s.searchIdx = nil
+	var idx searchIndex
+	data, err := s.fs.readFile(s.searchIndexPath())
+	if err != nil || json.Unmarshal(data, &idx) != nil || idx.Version != searchIndexVersion || idx.Index == nil {
+		return &searchIndex{Version: searchIndexVersion, Stamps: make(map[string]fileStamp), Index: search.New()}
+	}
+	if idx.Stamps == nil {
+		idx.Stamps = make(map[string]fileStamp)
+	}
+	if info != nil {
+		s.searchIdx, s.searchStamp = &idx, stampOf(info)
+	}
+	return &idx
+}
+
+func (s *FileStore) saveSearchIndex(idx *searchIndex) error {
+	if err := s.fs.mkdirAll(filepath.Join(s.root, indexDir)); err != nil {
+		return fmt.Errorf("creating index directory: %w", err)
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("marshaling search index: %w", err)
+	}
+	s.searchIdx = nil
+	if err := s.fs.writeFile(s.searchIndexPath(), data); err != nil {
+		return err
+	}
+	if info, err := s.fs.stat(s.searchIndexPath()); err == nil {
+		s.searchIdx, s.searchStamp = idx, stampOf(info)
+	}
+	return nil
+}
+
+// reconcileSearchIndex re-indexes every task and event file whose stamp
+// differs from the one recorded in idx and drops files that no longer exist.
+// It reports whether idx changed. With check, it leaves idx alone and only
+// reports whether it would change.
+func (s *FileStore) reconcileSearchIndex(idx *searchIndex, check bool) (bool, error) {
+	seen := make(map[string]bool)
+	changed := false
+	for _, dir := range []string{"tasks", "events"} {
+		entries, err := s.fs.readDir(filepath.Join(s.root, dir))
+		if err != nil {
+			return false, fmt.Errorf("reading directory %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			if _, _, ok := parseRecordFileName(e.Name()); !ok || e.IsDir() {
+				continue
+			}
+			key := dir + "/" + e.Name()
+			seen[key] = true
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			if stamp, ok := idx.Stamps[key]; ok && stamp == stampOf(info) {
+				continue
+			}
+			if check {
+				return true, nil
+			}
+			s.indexFile(idx, key)
+			changed = true
+		}
+	}
+	for key := range idx.Stamps {
+		if !seen[key] {
+			if check {
+				return true, nil
+			}
+			idx.Index.Remove(key)
+			delete(idx.Stamps, key)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+}
+
+// indexFile (re)indexes the record file at key, or removes it from the index
+// if the file is gone or unreadable.
+func (s *FileStore) indexFile(idx *searchIndex, key string) {
+	idx.Index.Remove(key)
+	delete(idx.Stamps, key)
+
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	info, err := s.fs.stat(path)
+	if err != nil {
+		return
+	}
+	data, err := s.fs.readFile(path)
+	if err != nil {
+		return
+	}
+	idx.Stamps[key] = stampOf(info)
+
+	switch {
+	case strings.HasPrefix(key, "tasks/"):
+		var t models.Task
+		if json.Unmarshal(data, &t) != nil {
+			return
+		}
+		idx.Index.Add(key, &search.Doc{Kind: "task", ID: t.ID, Title: t.Title, Fields: map[string]string{
+			"title":       t.Title,
+			"description": t.Description,
+			"plan":        t.Plan,
+			"labels":      strings.Join(t.Labels, " "),
+		}})
+	case strings.HasPrefix(key, "events/"):
+		var e models.Event
+		// Audit events only restate task fields that are indexed already.
+		if json.Unmarshal(data, &e) != nil || models.IsAuditEvent(e.Type) {
+			return
+		}
+		idx.Index.Add(key, &search.Doc{Kind: "event", ID: e.ID, TaskID: e.TaskID, Title: e.Type, Fields: map[string]string{
+			"message": e.Message,
+		}})
+	}
+}
@@ -2,38 +2,74 @@ package store
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
 )
 
 type settings struct {
-	MilestoneOrder []string `json:"milestone_order"`
+	// SchemaVersion is the last migration applied to .ghist/; see migrations.
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Backend names where records are kept: "json" (the default) or
+	// "sqlite". Change it with 'ghist store convert', which moves the data.
+	Backend        string           `json:"backend,omitempty"`
+	MilestoneOrder []string         `json:"milestone_order"`
+	Workflow       *models.Workflow `json:"workflow,omitempty"`
+	RefPrefix      string           `json:"ref_prefix,omitempty"`
+	// RefPrefixAliases are prefixes the project used before RefPrefix, kept
+	// so refs quoted in old commits and notes still resolve.
+	RefPrefixAliases []string               `json:"ref_prefix_aliases,omitempty"`
+	EventRetention   *models.EventRetention `json:"event_retention,omitempty"`
 }
 
-func (s *Store) settingsPath() string {
+func (s *FileStore) settingsPath() string {
 	return filepath.Join(s.root, "settings.json")
 }
 
-func (s *Store) readSettings() (settings, error) {
+// SettingsError reports a settings.json that cannot be parsed, such as one
+// left with merge-conflict markers. The store refuses to open over it rather
+// than fall back to defaults and overwrite the project's configuration.
+type SettingsError struct {
+	Err error
+}
+
+func (e *SettingsError) Error() string {
+	return fmt.Sprintf("settings.json is not valid JSON (%v); fix it by hand, then run 'ghist doctor'", e.Err)
+}
+
+func (e *SettingsError) Unwrap() error { return e.Err }
+
+func parseSettings(data []byte) (settings, error) {
 	var st settings
-	data, err := os.ReadFile(s.settingsPath())
-	if err != nil {
-		return st, nil // missing file → zero value
+	if err := json.Unmarshal(data, &st); err != nil {
+		return settings{}, &SettingsError{Err: err}
 	}
-	_ = json.Unmarshal(data, &st)
 	return st, nil
 }
 
-func (s *Store) writeSettings(st settings) error {
+func (s *FileStore) readSettings() (settings, error) {
+	data, err := s.fs.readFile(s.settingsPath())
+	if os.IsNotExist(err) {
+		return settings{}, nil
+	}
+	if err != nil {
+		return settings{}, fmt.Errorf("reading settings.json: %w", err)
+	}
+	return parseSettings(data)
+}
+
+func (s *FileStore) writeSettings(st settings) error {
 	data, err := json.MarshalIndent(st, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.settingsPath(), data, 0644)
+	return s.writeFile(s.settingsPath(), data)
 }
 
 // GetMilestoneOrder returns the saved milestone ordering.
-func (s *Store) GetMilestoneOrder() ([]string, error) {
+func (s *FileStore) GetMilestoneOrder() ([]string, error) {
 	st, err := s.readSettings()
 	if err != nil {
 		return nil, err
@@ -45,7 +81,13 @@ func (s *Store) GetMilestoneOrder() ([]string, error) {
 }
 
 // SetMilestoneOrder saves the milestone ordering.
-func (s *Store) SetMilestoneOrder(order []string) error {
+func (s *FileStore) SetMilestoneOrder(order []string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	st, err := s.readSettings()
 	if err != nil {
 		return err
@@ -53,3 +95,45 @@ func (s *Store) SetMilestoneOrder(order []string) error {
 	st.MilestoneOrder = order
 	return s.writeSettings(st)
 }
+
+// Workflow returns the project's workflow, falling back to the default for
+// anything settings.json leaves out.
+func (s *FileStore) Workflow() (models.Workflow, error) {
+	st, err := s.readSettings()
+	if err != nil {
+		return models.Workflow{}, err
+	}
+	if st.Workflow == nil {
+		return models.DefaultWorkflow(), nil
+	}
+	return st.Workflow.WithDefaults(), nil
+}
+
+// SetWorkflow saves the project's workflow to settings.json.
+func (s *FileStore) SetWorkflow(w models.Workflow) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := s.readSettings()
+	if err != nil {
+		return err
+	}
+	st.Workflow = &w
+	return s.writeSettings(st)
+}
+
+// EventRetention returns the project's event retention policy. The zero
+// value (no automatic compaction) is returned when none is configured.
+func (s *FileStore) EventRetention() (models.EventRetention, error) {
+	st, err := s.readSettings()
+	if err != nil {
+		return models.EventRetention{}, err
+	}
+	if st.EventRetention == nil {
+		return models.EventRetention{}, nil
+	}
+	return *st.EventRetention, nil
+}
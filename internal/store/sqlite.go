@@ -0,0 +1,299 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sqliteFile is the database the SQLite backend keeps records in.
+const sqliteFile = "ghist.db"
+
+// dataDirs are the directories holding a project's records: what the SQLite
+// backend keeps in the database and what Convert moves. Everything else under
+// .ghist/ — settings.json, which names the backend, and the local index/,
+// journal/ and backups/ — stays on disk.
+var dataDirs = []string{"tasks", "events", "opportunities", "milestones", "plans", "trash", "archive"}
+
+// sqliteBackend keeps records in a single SQLite file, .ghist/ghist.db, one
+// row per record file. It suits projects that do not share .ghist/ through
+// git: the database cannot be merged.
+type sqliteBackend struct {
+	db    *sql.DB
+	disk  *dirBackend
+	clock clock
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS files (
+	path     TEXT PRIMARY KEY,
+	data     BLOB NOT NULL,
+	mod_time INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS dirs (
+	path     TEXT PRIMARY KEY,
+	mod_time INTEGER NOT NULL
+);`
+
+func openSQLiteBackend(root string) (*sqliteBackend, error) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(root, sqliteFile)+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", sqliteFile, err)
+	}
+	// One connection keeps writes from this process in order and avoids
+	// SQLITE_BUSY between our own connections.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating %s schema: %w", sqliteFile, err)
+	}
+	return &sqliteBackend{db: db, disk: &dirBackend{root: root}}, nil
+}
+
+func (b *sqliteBackend) name() string          { return BackendSQLite }
+func (b *sqliteBackend) close() error          { return b.db.Close() }
+func (b *sqliteBackend) lock() (func(), error) { return b.disk.lock() }
+
+// key returns path's key in the database, relative to the root with forward
+// slashes, or ok=false if the file belongs on disk.
+func (b *sqliteBackend) key(p string) (key string, ok bool) {
+	rel, err := filepath.Rel(b.disk.root, p)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	top, _, _ := strings.Cut(rel, "/")
+	for _, d := range dataDirs {
+		if top == d {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+func (b *sqliteBackend) readFile(p string) ([]byte, error) {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.readFile(p)
+	}
+	var data []byte
+	err := b.db.QueryRow(`SELECT data FROM files WHERE path = ?`, k).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notExist("open", p)
+	}
+	return data, err
+}
+
+func (b *sqliteBackend) writeFile(p string, data []byte) error {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.writeFile(p, data)
+	}
+	return b.update(p, k, func(tx *sql.Tx, now int64) error {
+		_, err := tx.Exec(`INSERT INTO files (path, data, mod_time) VALUES (?, ?, ?)
+			ON CONFLICT (path) DO UPDATE SET data = excluded.data, mod_time = excluded.mod_time`, k, data, now)
+		return err
+	})
+}
+
+func (b *sqliteBackend) appendFile(p string, data []byte) error {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.appendFile(p, data)
+	}
+	return b.update(p, k, func(tx *sql.Tx, now int64) error {
+		var old []byte
+		if err := tx.QueryRow(`SELECT data FROM files WHERE path = ?`, k).Scan(&old); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO files (path, data, mod_time) VALUES (?, ?, ?)
+			ON CONFLICT (path) DO UPDATE SET data = excluded.data, mod_time = excluded.mod_time`, k, append(old, data...), now)
+		return err
+	})
+}
+
+func (b *sqliteBackend) truncate(p string, size int64) error {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.truncate(p, size)
+	}
+	return b.update(p, "", func(tx *sql.Tx, now int64) error {
+		var data []byte
+		if err := tx.QueryRow(`SELECT data FROM files WHERE path = ?`, k).Scan(&data); errors.Is(err, sql.ErrNoRows) {
+			return notExist("truncate", p)
+		} else if err != nil {
+			return err
+		}
+		if size < int64(len(data)) {
+			data = data[:size]
+		} else {
+			data = append(data, make([]byte, size-int64(len(data)))...)
+		}
+		_, err := tx.Exec(`UPDATE files SET data = ?, mod_time = ? WHERE path = ?`, data, now, k)
+		return err
+	})
+}
+
+// update runs fn in a transaction. If created is the key of a file fn may
+// create, its parent directory must exist and has its modification time
+// bumped when the file is new.
+func (b *sqliteBackend) update(p, created string, fn func(tx *sql.Tx, now int64) error) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := b.clock.now().UnixNano()
+	if created != "" {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM files WHERE path = ?)`, created).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			dir := path.Dir(created)
+			res, err := tx.Exec(`UPDATE dirs SET mod_time = ? WHERE path = ?`, now, dir)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return notExist("open", p)
+			}
+		}
+	}
+	if err := fn(tx, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) remove(p string) error {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.remove(p)
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := b.clock.now().UnixNano()
+
+	res, err := tx.Exec(`DELETE FROM files WHERE path = ?`, k)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var children bool
+		lo, hi := childRange(k)
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM files WHERE path >= ? AND path < ?)
+			OR EXISTS (SELECT 1 FROM dirs WHERE path >= ? AND path < ?)`, lo, hi, lo, hi).Scan(&children); err != nil {
+			return err
+		}
+		if children {
+			return &fs.PathError{Op: "remove", Path: p, Err: errors.New("directory not empty")}
+		}
+		res, err := tx.Exec(`DELETE FROM dirs WHERE path = ?`, k)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return notExist("remove", p)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE dirs SET mod_time = ? WHERE path = ?`, now, path.Dir(k)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// childRange returns the key range holding everything under dir.
+func childRange(dir string) (lo, hi string) {
+	return dir + "/", dir + "0" // '0' sorts right after '/'
+}
+
+func (b *sqliteBackend) readDir(p string) ([]fs.DirEntry, error) {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.readDir(p)
+	}
+	if _, err := b.stat(p); err != nil {
+		return nil, err
+	}
+	lo, hi := childRange(k)
+	var out []fs.DirEntry
+	rows, err := b.db.Query(`SELECT path, length(data), mod_time, 0 FROM files WHERE path >= ? AND path < ?
+		UNION ALL SELECT path, 0, mod_time, 1 FROM dirs WHERE path >= ? AND path < ?
+		ORDER BY path`, lo, hi, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var size, mod int64
+		var dir bool
+		if err := rows.Scan(&name, &size, &mod, &dir); err != nil {
+			return nil, err
+		}
+		name = strings.TrimPrefix(name, lo)
+		if strings.Contains(name, "/") {
+			continue // deeper down
+		}
+		out = append(out, fs.FileInfoToDirEntry(&fileInfo{base: name, size: size, modTime: time.Unix(0, mod), dir: dir}))
+	}
+	return out, rows.Err()
+}
+
+func (b *sqliteBackend) stat(p string) (fs.FileInfo, error) {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.stat(p)
+	}
+	var size, mod int64
+	err := b.db.QueryRow(`SELECT length(data), mod_time FROM files WHERE path = ?`, k).Scan(&size, &mod)
+	if err == nil {
+		return &fileInfo{base: path.Base(k), size: size, modTime: time.Unix(0, mod)}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	err = b.db.QueryRow(`SELECT mod_time FROM dirs WHERE path = ?`, k).Scan(&mod)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notExist("stat", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{base: path.Base(k), modTime: time.Unix(0, mod), dir: true}, nil
+}
+
+func (b *sqliteBackend) mkdirAll(p string) error {
+	k, ok := b.key(p)
+	if !ok {
+		return b.disk.mkdirAll(p)
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := b.clock.now().UnixNano()
+	for d := k; d != "."; d = path.Dir(d) {
+		res, err := tx.Exec(`INSERT INTO dirs (path, mod_time) VALUES (?, ?) ON CONFLICT (path) DO NOTHING`, d, now)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			break // it and its parents exist
+		}
+		if _, err := tx.Exec(`UPDATE dirs SET mod_time = ? WHERE path = ?`, now, path.Dir(d)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
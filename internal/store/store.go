@@ -1,63 +1,344 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+	"github.com/unnecessary-special-projects/ghist/internal/search"
 )
 
-// Store holds the root .ghist/ directory path.
-type Store struct {
+// Store is what the CLI, the API server and the context writer need from a
+// project's data: tasks, events, opportunities, milestones and settings. FileStore
+// implements it over any of the backends.
+type Store interface {
+	CreateTask(in CreateTaskInput) (*models.Task, error)
+	GetTask(id int64) (*models.Task, error)
+	ParseTaskID(raw string) (int64, error)
+	ParseCommitRefs(msg string) ([]models.CommitRef, error)
+	ListTasks(status, milestone, priority, taskType string) ([]models.Task, error)
+	FilterTasks(f TaskFilter) ([]models.Task, error)
+	UpdateTask(id int64, u TaskUpdate) (*models.Task, error)
+	DeleteTask(id int64) error
+	DeleteTaskAtRevision(id, revision int64) error
+	ListSubtasks(id int64) ([]models.Task, error)
+	LinkTasks(blocker, blocked int64, actor string) error
+	UnlinkTasks(blocker, blocked int64, actor string) error
+	NextTasks() ([]models.Task, error)
+	TaskHistory(taskID int64) ([]models.Event, error)
+	TaskCountsByStatus() (map[string]int, error)
+	MilestoneInfo() ([]models.MilestoneInfo, error)
+	Burndown(milestone string) (*models.Burndown, error)
+	Throughput(weeks int, milestone string) ([]models.ThroughputWeek, error)
+	LeadTime(milestone string) (*models.DurationReport, error)
+	CycleTime(milestone string) (*models.DurationReport, error)
+	ChangelogTasks(milestone string, since time.Time) ([]models.Task, error)
+	LabelCounts() ([]models.LabelCount, error)
+	ListPlanRevisions(taskID int64) ([]models.PlanRevision, error)
+	GetPlanRevision(taskID int64, rev int) (*models.PlanRevision, error)
+	RestorePlanRevision(taskID int64, rev int, actor string) (*models.Task, error)
+	ListTrash() ([]models.TrashedTask, error)
+	RestoreTask(id int64) (*TrashRestore, error)
+
+	CreateEvent(typ, message, metadata string, taskID *int64) (*models.Event, error)
+	CreateEventAs(actor, typ, message, metadata string, taskID *int64) (*models.Event, error)
+	GetEvent(id int64) (*models.Event, error)
+	ListEvents(limit int) ([]models.Event, error)
+	ListEventsByTask(taskID int64) ([]models.Event, error)
+	ListEventsByActor(actor string, limit int) ([]models.Event, error)
+
+	CreateOpportunity(name, notes, actor string) (*models.Opportunity, error)
+	GetOpportunity(id int64) (*models.Opportunity, error)
+	ListOpportunities() ([]models.Opportunity, error)
+	UpdateOpportunity(id int64, u OpportunityUpdate) (*models.Opportunity, error)
+	DeleteOpportunity(id int64, actor string) error
+
+	CreateMilestone(in MilestoneInput) (*models.Milestone, error)
+	GetMilestone(name string) (*models.Milestone, error)
+	ListMilestones() ([]models.Milestone, error)
+	UpdateMilestone(name string, u MilestoneUpdate) (*models.Milestone, error)
+	SetMilestoneState(name, state, actor string) (*models.Milestone, error)
+	DeleteMilestone(name, actor string) error
+	RenameMilestone(from, to, actor string) (int, error)
+	MergeMilestone(from, into, actor string) (int, error)
+
+	GetMilestoneOrder() ([]string, error)
+	SetMilestoneOrder(order []string) error
+	Workflow() (models.Workflow, error)
+	SetWorkflow(w models.Workflow) error
+	RefPrefix() (string, error)
+	SetRefPrefix(prefix string) (int, error)
+	EventRetention() (models.EventRetention, error)
+
+	Search(q string, limit int) ([]search.Result, error)
+	ChangeStamp() (string, error)
+
+	Doctor(fix bool) (*DoctorReport, error)
+	MergeFix(dryRun bool) (*MergeFixReport, error)
+	ArchiveEvents(before time.Time, all, dryRun bool) (*ArchiveReport, error)
+	CompactEvents(dryRun bool) (*ArchiveReport, error)
+	EmptyTrash() (int, error)
+	Undo() (*Operation, error)
+	Close() error
+}
+
+// ActorSetter is a Store that can be told who is making its changes; see
+// FileStore.SetActor.
+type ActorSetter interface {
+	SetActor(actor string)
+}
+
+// OperationRecorder is a Store that can record its changes as one undoable
+// operation; see FileStore.BeginOperation.
+type OperationRecorder interface {
+	BeginOperation(name string)
+}
+
+var (
+	_ Store             = (*FileStore)(nil)
+	_ ActorSetter       = (*FileStore)(nil)
+	_ OperationRecorder = (*FileStore)(nil)
+)
+
+// FileStore keeps each record as a JSON file in a tree rooted at .ghist/.
+// Where the tree lives is up to its backend: the .ghist/ directory itself
+// (the default, merged through git), a single SQLite file, or memory.
+type FileStore struct {
 	root string
+	fs   backend
+	mu   sync.Mutex
+	// indexPending lists record files written under the current lock, for
+	// flushSearchIndex. Guarded by mu.
+	indexPending map[string]bool
+	// searchIdx is the search index as last loaded or saved by this process,
+	// reused while index/search.json still has searchStamp. Guarded by mu.
+	searchIdx   *searchIndex
+	searchStamp fileStamp
+
+	taskCache  recordCache[models.Task]
+	eventCache recordCache[models.Event]
+	// archiveCache holds parsed event archive files.
+	archiveCache archiveCache
+	// op collects the files changed by the operation begun with
+	// BeginOperation; opSeen holds their paths. Guarded by mu.
+	op     *Operation
+	opSeen map[string]bool
+	// migrated lists the migrations Open ran.
+	migrated []MigrationResult
+	// actor is recorded on events written without an explicit actor.
+	actor string
 }
 
-// Open initialises a file-based store rooted at ghistDir (the .ghist/ directory).
-// It runs SQLite-to-JSON migration if a legacy ghist.sqlite is present, then
-// ensures the tasks/, events/, and opportunities/ subdirectories exist.
-func Open(ghistDir string) (*Store, error) {
-	if err := MigrateSQLiteToJSON(ghistDir); err != nil {
-		return nil, fmt.Errorf("migrating sqlite: %w", err)
+// Open initialises the store rooted at ghistDir (the .ghist/ directory) with
+// the backend named in its settings.json, JSON files by default. It ensures
+// the tasks/, events/, opportunities/ and milestones/ subdirectories exist, then runs any
+// schema migrations the store has not had yet, including importing a legacy
+// ghist.sqlite.
+func Open(ghistDir string) (*FileStore, error) {
+	name, err := backendSetting(ghistDir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := openBackend(ghistDir, name)
+	if err != nil {
+		return nil, err
 	}
+	return open(ghistDir, b)
+}
 
-	for _, dir := range []string{"tasks", "events", "opportunities"} {
-		if err := os.MkdirAll(filepath.Join(ghistDir, dir), 0755); err != nil {
-			return nil, fmt.Errorf("creating %s directory: %w", dir, err)
+// backendSetting reads the backend named in settings.json, which always
+// lives on disk so the store can be found.
+func backendSetting(ghistDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(ghistDir, "settings.json"))
+	if os.IsNotExist(err) {
+		return BackendJSON, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading settings.json: %w", err)
+	}
+	st, err := parseSettings(data)
+	if err != nil {
+		return "", err
+	}
+	if st.Backend == "" {
+		return BackendJSON, nil
+	}
+	return st.Backend, nil
+}
+
+func openBackend(ghistDir, name string) (backend, error) {
+	switch name {
+	case BackendJSON:
+		return &dirBackend{root: ghistDir}, nil
+	case BackendSQLite:
+		return openSQLiteBackend(ghistDir)
+	default:
+		return nil, fmt.Errorf("unknown backend %q in settings.json (want %s or %s)", name, BackendJSON, BackendSQLite)
+	}
+}
+
+// open sets up a store on b, closing b if that fails.
+func open(root string, b backend) (*FileStore, error) {
+	s := &FileStore{root: root, fs: b}
+	if err := s.init(); err != nil {
+		b.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) init() error {
+	empty := s.isEmpty()
+	for _, dir := range []string{"tasks", "events", "opportunities", "milestones"} {
+		if err := s.fs.mkdirAll(filepath.Join(s.root, dir)); err != nil {
+			return fmt.Errorf("creating %s directory: %w", dir, err)
+		}
+	}
+
+	// Ensure settings.json exists. A brand-new store starts at the current
+	// schema; one with records but no settings predates schema versions.
+	if _, err := s.fs.stat(s.settingsPath()); os.IsNotExist(err) {
+		content := "{}"
+		if empty {
+			content = fmt.Sprintf("{\n  \"schema_version\": %d\n}", SchemaVersion)
+		}
+		if err := s.fs.writeFile(s.settingsPath(), []byte(content)); err != nil {
+			return fmt.Errorf("creating settings.json: %w", err)
 		}
 	}
 
-	// Ensure settings.json exists
-	settingsPath := filepath.Join(ghistDir, "settings.json")
-	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
-		if err := os.WriteFile(settingsPath, []byte("{}"), 0644); err != nil {
-			return nil, fmt.Errorf("creating settings.json: %w", err)
+	if s.fs.name() != BackendMemory {
+		if err := ensureGitignore(s.root); err != nil {
+			return err
 		}
 	}
+	return s.migrate()
+}
 
-	return &Store{root: ghistDir}, nil
+// isEmpty reports whether the store holds no records and no legacy database,
+// so there is nothing to migrate.
+func (s *FileStore) isEmpty() bool {
+	if _, err := s.fs.stat(filepath.Join(s.root, "ghist.sqlite")); err == nil {
+		return false
+	}
+	for _, dir := range []string{"tasks", "events", "opportunities", "milestones"} {
+		entries, err := s.fs.readDir(filepath.Join(s.root, dir))
+		if err != nil && !os.IsNotExist(err) || len(entries) > 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// Close is a no-op for the file-based store; retained for interface compatibility.
-func (s *Store) Close() error {
-	return nil
+// SetActor sets who is recorded on the events this store writes when the
+// caller does not name an actor: the person or agent running the command.
+func (s *FileStore) SetActor(actor string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actor = actor
+}
+
+// ChangeStamp returns a value that changes whenever a task, event,
+// opportunity or milestone is created, changed or removed, by this process
+// or another. It reads through the backend, so it works whatever the store
+// keeps its files in.
+func (s *FileStore) ChangeStamp() (string, error) {
+	var sb strings.Builder
+	for _, dir := range []string{s.tasksDir(), s.eventsDir(), s.opportunitiesDir(), s.milestonesDir()} {
+		entries, err := s.fs.readDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&sb, "%s:%d:%d|", e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+		sb.WriteString("/")
+	}
+	return sb.String(), nil
+}
+
+// Backend returns the name of the backend the store's files live in.
+func (s *FileStore) Backend() string {
+	return s.fs.name()
+}
+
+// ValidationError reports input that the project's workflow or the store's
+// invariants reject, as opposed to a missing record or an I/O failure.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// invalid wraps err as a *ValidationError; it returns nil for a nil err.
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{Err: err}
+}
+
+// NotFoundError reports a record that does not exist.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// notFound wraps err as a *NotFoundError.
+func notFound(err error) error {
+	return &NotFoundError{Err: err}
+}
+
+// isNotFound reports whether err is or wraps a *NotFoundError.
+func isNotFound(err error) bool {
+	var nerr *NotFoundError
+	return errors.As(err, &nerr)
+}
+
+// Close writes the operation begun with BeginOperation, if any, to the undo
+// journal, and releases the backend.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	recording := s.op != nil
+	s.mu.Unlock()
+	var err error
+	if recording {
+		err = s.commitOperation()
+	}
+	if cerr := s.fs.close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // nextID returns the next available integer ID for a given subdirectory by
-// scanning existing JSON filenames and returning max+1.
-func nextID(dir string) (int64, error) {
-	entries, err := os.ReadDir(dir)
+// scanning existing JSON filenames and returning max+1. Callers must hold the
+// store lock until the new file is written, or two writers may get the same ID.
+func (s *FileStore) nextID(dir string) (int64, error) {
+	entries, err := s.fs.readDir(dir)
 	if err != nil {
 		return 0, fmt.Errorf("reading directory %s: %w", dir, err)
 	}
 	var max int64
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+		if e.IsDir() {
 			continue
 		}
-		base := strings.TrimSuffix(e.Name(), ".json")
-		id, err := strconv.ParseInt(base, 10, 64)
-		if err != nil {
+		id, _, ok := parseRecordFileName(e.Name())
+		if !ok {
 			continue
 		}
 		if id > max {
@@ -66,3 +347,90 @@ func nextID(dir string) (int64, error) {
 	}
 	return max + 1, nil
 }
+
+// nextTaskID is nextID for tasks. It also counts the tasks in the trash and
+// the tasks archived events are linked to, so that a new task never takes
+// the ID, and ref, of a deleted one whose history is still around.
+func (s *FileStore) nextTaskID() (int64, error) {
+	next, err := s.nextID(s.tasksDir())
+	if err != nil {
+		return 0, err
+	}
+	entries, err := s.fs.readDir(s.trashDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("reading trash: %w", err)
+	}
+	for _, e := range entries {
+		if id, _, ok := parseRecordFileName(e.Name()); ok && !e.IsDir() && id >= next {
+			next = id + 1
+		}
+	}
+	months, err := s.archiveMonths()
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range months {
+		events, err := s.readArchive(m)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range events {
+			if e.TaskID != nil && *e.TaskID >= next {
+				next = *e.TaskID + 1
+			}
+		}
+	}
+	return next, nil
+}
+
+// recordFile returns the path of the single file holding record id in dir.
+// kind names the record type in error messages.
+func (s *FileStore) recordFile(dir, kind string, id int64) (string, error) {
+	names, err := s.findRecordFiles(dir, id)
+	if err != nil {
+		return "", err
+	}
+	switch len(names) {
+	case 0:
+		return "", notFound(fmt.Errorf("%s %d not found", kind, id))
+	case 1:
+		return filepath.Join(dir, names[0]), nil
+	default:
+		return "", fmt.Errorf("%s %d is ambiguous (%d files share this id); run 'ghist merge-fix'", kind, id, len(names))
+	}
+}
+
+// localArtifacts are paths under .ghist/ that are derived or per-machine and
+// should never be committed.
+var localArtifacts = []string{"/" + indexDir + "/", "/" + journalDir + "/", "/" + backupsDir + "/", "/" + lockFile, "/" + sqliteFile + "-journal", "*.tmp"}
+
+// ensureGitignore makes sure .ghist/.gitignore lists every local artifact,
+// keeping any lines the user added.
+func ensureGitignore(ghistDir string) error {
+	path := filepath.Join(ghistDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading .ghist/.gitignore: %w", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	var missing []string
+	for _, a := range localArtifacts {
+		if !slices.Contains(lines, a) {
+			missing = append(missing, a)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	content := string(data)
+	if content == "" {
+		content = "# Local ghist artifacts, rebuilt as needed.\n"
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += strings.Join(missing, "\n") + "\n"
+	if err := writeFileAtomic(path, []byte(content)); err != nil {
+		return fmt.Errorf("writing .ghist/.gitignore: %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,20 @@
 package store
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
 )
 
-func newTestStore(t *testing.T) *Store {
+func newTestStore(t *testing.T) *FileStore {
 	t.Helper()
 	s, err := Open(t.TempDir())
 	if err != nil {
@@ -14,6 +24,18 @@ func newTestStore(t *testing.T) *Store {
 	return s
 }
 
+// withoutAudit drops the audit events from events, leaving those logged
+// explicitly.
+func withoutAudit(events []models.Event) []models.Event {
+	var out []models.Event
+	for _, e := range events {
+		if !models.IsAuditEvent(e.Type) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 // --- Task tests ---
 
 func TestCreateAndGetTask(t *testing.T) {
@@ -140,6 +162,143 @@ func TestUpdateTaskNotFound(t *testing.T) {
 	}
 }
 
+func TestTaskRevision(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Shared"})
+	if task.Revision != 1 {
+		t.Fatalf("expected a new task at revision 1, got %d", task.Revision)
+	}
+
+	plan := "Agent's plan"
+	rev := task.Revision
+	updated, err := s.UpdateTask(task.ID, TaskUpdate{Plan: &plan, IfRevision: &rev})
+	if err != nil || updated.Revision != 2 {
+		t.Fatalf("expected the update at revision 2, got %+v (%v)", updated, err)
+	}
+
+	// A second writer still holding revision 1 is turned away.
+	stale := "Human's plan"
+	_, err = s.UpdateTask(task.ID, TaskUpdate{Plan: &stale, IfRevision: &rev})
+	var rerr *RevisionError
+	if !errors.As(err, &rerr) || rerr.Revision != 2 || rerr.Want != 1 {
+		t.Fatalf("expected a revision conflict, got %v", err)
+	}
+	if got, _ := s.GetTask(task.ID); got.Plan != plan || got.Revision != 2 {
+		t.Errorf("expected the rejected update to change nothing, got %+v", got)
+	}
+
+	// Writes made on the task's behalf bump it too.
+	other, _ := s.CreateTask(CreateTaskInput{Title: "Other"})
+	s.LinkTasks(task.ID, other.ID, "")
+	if got, _ := s.GetTask(task.ID); got.Revision != 3 {
+		t.Errorf("expected linking to bump the revision to 3, got %d", got.Revision)
+	}
+
+	if err := s.DeleteTaskAtRevision(task.ID, 2); !errors.As(err, &rerr) {
+		t.Errorf("expected a stale delete to fail, got %v", err)
+	}
+	if err := s.DeleteTaskAtRevision(task.ID, 3); err != nil {
+		t.Errorf("deleting at the current revision: %v", err)
+	}
+}
+
+func TestTaskAssignee(t *testing.T) {
+	s := newTestStore(t)
+	s.SetActor("Ada <ada@example.com>")
+	mine, _ := s.CreateTask(CreateTaskInput{Title: "Mine", Assignee: " Ada <ada@example.com> "})
+	s.CreateTask(CreateTaskInput{Title: "Nobody's"})
+
+	if mine.Assignee != "Ada <ada@example.com>" {
+		t.Errorf("expected the assignee trimmed, got %q", mine.Assignee)
+	}
+	got, _ := s.FilterTasks(TaskFilter{Assignee: "ada <ADA@example.com>"})
+	if len(got) != 1 || got[0].ID != mine.ID {
+		t.Errorf("expected the assignee filter to match case-insensitively, got %+v", got)
+	}
+	if got, _ := s.FilterTasks(TaskFilter{Assignee: NoAssignee}); len(got) != 1 || got[0].Title != "Nobody's" {
+		t.Errorf("expected %q to match unassigned tasks, got %+v", NoAssignee, got)
+	}
+
+	// Reassigning is audited under whoever made the change.
+	bob := "Bob"
+	if _, err := s.UpdateTask(mine.ID, TaskUpdate{Assignee: &bob, Actor: "agent:claude-code"}); err != nil {
+		t.Fatalf("reassigning: %v", err)
+	}
+	none := ""
+	s.UpdateTask(mine.ID, TaskUpdate{Assignee: &none})
+	history, _ := s.TaskHistory(mine.ID)
+	if len(history) != 3 {
+		t.Fatalf("expected the creation and 2 assignee events, got %+v", history)
+	}
+	if history[0].Type != models.EventTaskCreated || history[0].Actor != "Ada <ada@example.com>" {
+		t.Errorf("expected the store's actor on the creation, got %+v", history[0])
+	}
+	if history[1].Type != models.EventTaskAssigneeChanged || history[1].Actor != "agent:claude-code" {
+		t.Errorf("expected the explicit actor on the first change, got %+v", history[1])
+	}
+	if history[2].Actor != "Ada <ada@example.com>" {
+		t.Errorf("expected the store's actor on the second change, got %q", history[2].Actor)
+	}
+	if got, _ := s.GetTask(mine.ID); got.Assignee != "" {
+		t.Errorf("expected an empty assignee to unassign, got %q", got.Assignee)
+	}
+}
+
+func TestEventsByActor(t *testing.T) {
+	s := newTestStore(t)
+	s.SetActor("Ada")
+	s.CreateEvent("log", "by the store's actor", "{}", nil)
+	s.CreateEventAs("agent:cursor", "log", "by an agent", "{}", nil)
+	s.CreateEventAs("", "log", "also by the store's actor", "{}", nil)
+
+	events, err := s.ListEventsByActor("Ada", 10)
+	if err != nil {
+		t.Fatalf("listing events by actor: %v", err)
+	}
+	if len(events) != 2 || events[0].Message != "also by the store's actor" {
+		t.Errorf("expected Ada's 2 events newest first, got %+v", events)
+	}
+	agent, _ := s.ListEventsByActor("agent:cursor", 10)
+	if len(agent) != 1 || !models.IsAgentActor(agent[0].Actor) {
+		t.Errorf("expected the agent's event, got %+v", agent)
+	}
+}
+
+func TestWritesRecordActor(t *testing.T) {
+	s := newTestStore(t)
+	s.SetActor("Ada")
+	s.CreateTask(CreateTaskInput{Title: "By the store's actor"})
+	s.CreateTask(CreateTaskInput{Title: "By an agent", Actor: "agent:cursor"})
+	o, _ := s.CreateOpportunity("Faster sync", "", "")
+	name := "Much faster sync"
+	s.UpdateOpportunity(o.ID, OpportunityUpdate{Name: &name})
+	s.DeleteOpportunity(o.ID, "")
+	s.CreateMilestone(MilestoneInput{Name: "v1"})
+	s.SetMilestoneState("v1", models.MilestoneClosed, "")
+	s.DeleteMilestone("v1", "")
+
+	var types []string
+	events, _ := s.ListEventsByActor("Ada", 20)
+	for i := len(events) - 1; i >= 0; i-- {
+		types = append(types, events[i].Type)
+	}
+	want := []string{
+		models.EventTaskCreated,
+		models.EventOpportunityCreated, models.EventOpportunityUpdated, models.EventOpportunityDeleted,
+		models.EventMilestoneCreated, models.EventMilestoneUpdated, models.EventMilestoneDeleted,
+	}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("expected Ada's writes %v, got %v", want, types)
+	}
+	var change models.RecordChange
+	if err := json.Unmarshal([]byte(events[4].Metadata), &change); err != nil || change.ID != o.ID || change.Name != name {
+		t.Errorf("expected the opportunity in the event metadata, got %+v (%v)", change, err)
+	}
+	if agent, _ := s.ListEventsByActor("agent:cursor", 10); len(agent) != 1 || agent[0].Type != models.EventTaskCreated {
+		t.Errorf("expected the agent's task.created event, got %+v", agent)
+	}
+}
+
 func TestDeleteTask(t *testing.T) {
 	s := newTestStore(t)
 	s.CreateTask(CreateTaskInput{Title: "To delete"})
@@ -199,6 +358,251 @@ func TestMilestoneInfo(t *testing.T) {
 	}
 }
 
+func TestMilestoneRecords(t *testing.T) {
+	s := newTestStore(t)
+	m, err := s.CreateMilestone(MilestoneInput{Name: "v1", Description: "First cut", StartDate: "2020-01-01", TargetDate: "2020-02-01"})
+	if err != nil {
+		t.Fatalf("creating milestone: %v", err)
+	}
+	if m.State != models.MilestoneOpen {
+		t.Errorf("expected a new milestone to be open, got %q", m.State)
+	}
+	var verr *ValidationError
+	if _, err := s.CreateMilestone(MilestoneInput{Name: "v1"}); !errors.As(err, &verr) {
+		t.Errorf("expected a duplicate name to be rejected, got %v", err)
+	}
+	if _, err := s.CreateMilestone(MilestoneInput{Name: "v3", StartDate: "2020-03-01", TargetDate: "2020-02-01"}); !errors.As(err, &verr) {
+		t.Errorf("expected a target before the start to be rejected, got %v", err)
+	}
+	if _, err := s.CreateMilestone(MilestoneInput{Name: "v3", TargetDate: "next week"}); !errors.As(err, &verr) {
+		t.Errorf("expected a malformed date to be rejected, got %v", err)
+	}
+
+	// Records without tasks and task names without records are both listed.
+	s.CreateTask(CreateTaskInput{Title: "Beta work", Milestone: "beta"})
+	infos, _ := s.MilestoneInfo()
+	if len(infos) != 2 || infos[0].Name != "beta" || infos[1].Name != "v1" {
+		t.Fatalf("expected beta and v1, got %+v", infos)
+	}
+	if infos[0].State != models.MilestoneOpen || infos[0].Overdue {
+		t.Errorf("expected beta to be open with no dates, got %+v", infos[0])
+	}
+	if !infos[1].Overdue || infos[1].TargetDate != "2020-02-01" {
+		t.Errorf("expected v1 to be overdue, got %+v", infos[1])
+	}
+
+	// Closing a milestone known only from tasks gives it a record.
+	closed, err := s.SetMilestoneState("beta", models.MilestoneClosed, "")
+	if err != nil || closed.ClosedAt == nil {
+		t.Fatalf("closing beta: %+v (%v)", closed, err)
+	}
+	if got, err := s.GetMilestone("beta"); err != nil || got.State != models.MilestoneClosed {
+		t.Errorf("expected beta's record to be closed, got %+v (%v)", got, err)
+	}
+	s.SetMilestoneState("v1", models.MilestoneClosed, "")
+	if infos, _ := s.MilestoneInfo(); infos[1].Overdue {
+		t.Errorf("expected a closed milestone not to be overdue, got %+v", infos[1])
+	}
+
+	target := ""
+	if got, err := s.UpdateMilestone("v1", MilestoneUpdate{TargetDate: &target}); err != nil || got.TargetDate != "" {
+		t.Errorf("expected an empty target date to clear it, got %+v (%v)", got, err)
+	}
+}
+
+func TestRenameAndMergeMilestones(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateMilestone(MilestoneInput{Name: "v2", Description: "Second"})
+	a, _ := s.CreateTask(CreateTaskInput{Title: "A", Milestone: "v2"})
+	archived := true
+	b, _ := s.CreateTask(CreateTaskInput{Title: "B", Milestone: "v2"})
+	s.UpdateTask(b.ID, TaskUpdate{Archived: &archived})
+	s.CreateTask(CreateTaskInput{Title: "C", Milestone: "later"})
+	s.SetMilestoneOrder([]string{"v2", "later"})
+
+	if _, err := s.RenameMilestone("v2", "later", ""); err == nil {
+		t.Error("expected renaming onto an existing milestone to fail")
+	}
+	n, err := s.RenameMilestone("v2", "2.0", "")
+	if err != nil || n != 2 {
+		t.Fatalf("expected the rename to move 2 tasks, archived included, got %d (%v)", n, err)
+	}
+	if m, err := s.GetMilestone("2.0"); err != nil || m.Description != "Second" {
+		t.Errorf("expected the record to follow the rename, got %+v (%v)", m, err)
+	}
+	if got, _ := s.GetTask(b.ID); got.Milestone != "2.0" {
+		t.Errorf("expected the archived task renamed too, got %q", got.Milestone)
+	}
+	history, _ := s.TaskHistory(a.ID)
+	if len(history) != 2 || history[1].Type != models.EventTaskMilestoneChanged {
+		t.Errorf("expected the rename in the task's history, got %+v", history)
+	}
+	if order, _ := s.GetMilestoneOrder(); strings.Join(order, ",") != "2.0,later" {
+		t.Errorf("expected the milestone order renamed, got %v", order)
+	}
+
+	if err := s.DeleteMilestone("2.0", ""); err == nil {
+		t.Error("expected deleting a milestone with tasks to fail")
+	}
+	n, err = s.MergeMilestone("2.0", "later", "")
+	if err != nil || n != 2 {
+		t.Fatalf("expected the merge to move 2 tasks, got %d (%v)", n, err)
+	}
+	if _, err := s.GetMilestone("2.0"); err == nil {
+		t.Error("expected the merged milestone's record to be gone")
+	}
+	if tasks, _ := s.FilterTasks(TaskFilter{Milestone: "later", IncludeArchived: true}); len(tasks) != 3 {
+		t.Errorf("expected 3 tasks in later, got %d", len(tasks))
+	}
+	if order, _ := s.GetMilestoneOrder(); strings.Join(order, ",") != "later" {
+		t.Errorf("expected the merged milestone dropped from the order, got %v", order)
+	}
+	if _, err := s.MergeMilestone("later", "nowhere", ""); err == nil {
+		t.Error("expected merging into an unknown milestone to fail")
+	}
+}
+
+func TestStatusStamps(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Work"})
+	if task.StartedAt != nil || task.CompletedAt != nil {
+		t.Fatalf("expected a new todo task to be unstamped, got %+v", task)
+	}
+	status := "in_progress"
+	task, _ = s.UpdateTask(task.ID, TaskUpdate{Status: &status})
+	if task.StartedAt == nil {
+		t.Fatal("expected starting work to stamp started_at")
+	}
+	started := *task.StartedAt
+	status = "done"
+	task, _ = s.UpdateTask(task.ID, TaskUpdate{Status: &status})
+	if task.CompletedAt == nil {
+		t.Fatal("expected finishing to stamp completed_at")
+	}
+	status = "todo"
+	task, _ = s.UpdateTask(task.ID, TaskUpdate{Status: &status})
+	if task.CompletedAt != nil || task.StartedAt == nil || !task.StartedAt.Equal(started) {
+		t.Errorf("expected reopening to clear completed_at and keep started_at, got %+v", task)
+	}
+
+	done, _ := s.CreateTask(CreateTaskInput{Title: "Already done", Status: "done"})
+	if done.CompletedAt == nil || done.StartedAt != nil {
+		t.Errorf("expected a task created done to be completed but never started, got %+v", done)
+	}
+}
+
+func TestReports(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	ago := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	s.CreateMilestone(MilestoneInput{Name: "v1", StartDate: ago(10).Format(models.DateLayout), TargetDate: now.AddDate(0, 0, 10).Format(models.DateLayout)})
+
+	// backdate rewrites a task's timestamps as if it had been worked on in
+	// the past.
+	backdate := func(title string, created, started, completed *time.Time) *models.Task {
+		status := "todo"
+		switch {
+		case completed != nil:
+			status = "done"
+		case started != nil:
+			status = "in_progress"
+		}
+		task, _ := s.CreateTask(CreateTaskInput{Title: title, Milestone: "v1", Status: status})
+		task.CreatedAt, task.StartedAt, task.CompletedAt = *created, started, completed
+		if err := s.writeTask(task); err != nil {
+			t.Fatalf("backdating %s: %v", title, err)
+		}
+		return task
+	}
+	backdate("A", ago(10), ago(8), ago(5))
+	backdate("B", ago(10), nil, nil)
+	backdate("C", ago(3), nil, ago(1))
+	s.CreateTask(CreateTaskInput{Title: "Elsewhere", Status: "done"})
+
+	// A task from before the stamps: its times come from its events.
+	legacy, _ := s.CreateTask(CreateTaskInput{Title: "Legacy", Milestone: "v1"})
+	for _, st := range []string{"in_progress", "done"} {
+		status := st
+		s.UpdateTask(legacy.ID, TaskUpdate{Status: &status})
+	}
+	legacy, _ = s.GetTask(legacy.ID)
+	legacy.StartedAt, legacy.CompletedAt = nil, nil
+	s.writeTask(legacy)
+
+	b, err := s.Burndown("v1")
+	if err != nil {
+		t.Fatalf("burndown: %v", err)
+	}
+	if len(b.Days) != 11 {
+		t.Fatalf("expected 11 days from the start date to today, got %d", len(b.Days))
+	}
+	first, last := b.Days[0], b.Days[len(b.Days)-1]
+	if first.Scope != 2 || first.Remaining != 2 || first.Ideal == nil || *first.Ideal != 2 {
+		t.Errorf("unexpected first day: %+v", first)
+	}
+	if last.Scope != 4 || last.Done != 3 || last.Remaining != 1 || *last.Ideal != 1 {
+		t.Errorf("unexpected last day: %+v (ideal %v)", last, *last.Ideal)
+	}
+	if _, err := s.Burndown("nope"); err == nil {
+		t.Error("expected an unknown milestone to fail")
+	}
+
+	lead, _ := s.LeadTime("v1")
+	if lead.Count != 3 || lead.Tasks[0].RefID != "GHST-1" || lead.Tasks[0].Days != 5 {
+		t.Errorf("unexpected lead times: %+v", lead)
+	}
+	cycle, _ := s.CycleTime("v1")
+	if cycle.Count != 2 || cycle.Tasks[0].Days != 3 || cycle.Tasks[1].Title != "Legacy" {
+		t.Errorf("expected cycle times for A and the legacy task, got %+v", cycle)
+	}
+
+	weeks, _ := s.Throughput(3, "")
+	total := 0
+	for _, w := range weeks {
+		total += w.Done
+	}
+	if len(weeks) != 3 || total != 4 {
+		t.Errorf("expected 4 tasks done over 3 weeks, got %+v", weeks)
+	}
+	if weeks[2].Week != time.Now().AddDate(0, 0, -((int(time.Now().Weekday())+6)%7)).Format(models.DateLayout) {
+		t.Errorf("expected the last week to start this Monday, got %s", weeks[2].Week)
+	}
+}
+
+func TestChangelogTasks(t *testing.T) {
+	s := newTestStore(t)
+	done := "done"
+	parent, _ := s.CreateTask(CreateTaskInput{Title: "Feature", Milestone: "v1"})
+	step, _ := s.CreateTask(CreateTaskInput{Title: "Step", ParentID: &parent.ID})
+	other, _ := s.CreateTask(CreateTaskInput{Title: "Other step", ParentID: &parent.ID})
+	s.CreateTask(CreateTaskInput{Title: "Open", Milestone: "v1"})
+	s.CreateTask(CreateTaskInput{Title: "Elsewhere", Milestone: "v2", Status: "done"})
+
+	s.UpdateTask(step.ID, TaskUpdate{Status: &done})
+	tasks, _ := s.ChangelogTasks("v1", time.Time{})
+	if len(tasks) != 1 || tasks[0].ID != step.ID {
+		t.Fatalf("expected only the finished subtask while its parent is open, got %+v", tasks)
+	}
+
+	s.UpdateTask(other.ID, TaskUpdate{Status: &done})
+	s.UpdateTask(parent.ID, TaskUpdate{Status: &done})
+	tasks, _ = s.ChangelogTasks("v1", time.Time{})
+	if len(tasks) != 1 || tasks[0].ID != parent.ID {
+		t.Errorf("expected a finished parent to stand for its subtasks, got %+v", tasks)
+	}
+
+	all, _ := s.ChangelogTasks("", time.Time{})
+	if len(all) != 2 || all[0].Title != "Elsewhere" {
+		t.Errorf("expected done tasks in every milestone in the order they finished, got %+v", all)
+	}
+	if later, _ := s.ChangelogTasks("", time.Now().Add(time.Hour)); len(later) != 0 {
+		t.Errorf("expected nothing finished after an hour from now, got %+v", later)
+	}
+}
+
 func TestTaskNewFields(t *testing.T) {
 	s := newTestStore(t)
 	task, err := s.CreateTask(CreateTaskInput{Title: "Fields test", Priority: "high", Type: "bug"})
@@ -360,7 +764,7 @@ func TestListEventsByTask(t *testing.T) {
 	if err != nil {
 		t.Fatalf("listing events: %v", err)
 	}
-	if len(events) != 1 {
+	if events = withoutAudit(events); len(events) != 1 {
 		t.Errorf("expected 1 event, got %d", len(events))
 	}
 }
@@ -369,7 +773,7 @@ func TestListEventsByTask(t *testing.T) {
 
 func TestCreateAndGetOpportunity(t *testing.T) {
 	s := newTestStore(t)
-	opp, err := s.CreateOpportunity("Feature idea", "Some notes")
+	opp, err := s.CreateOpportunity("Feature idea", "Some notes", "")
 	if err != nil {
 		t.Fatalf("creating opportunity: %v", err)
 	}
@@ -388,8 +792,8 @@ func TestCreateAndGetOpportunity(t *testing.T) {
 
 func TestListOpportunities(t *testing.T) {
 	s := newTestStore(t)
-	s.CreateOpportunity("Opp 1", "")
-	s.CreateOpportunity("Opp 2", "")
+	s.CreateOpportunity("Opp 1", "", "")
+	s.CreateOpportunity("Opp 2", "", "")
 
 	opps, err := s.ListOpportunities()
 	if err != nil {
@@ -418,3 +822,1161 @@ func TestDeleteTaskSetsEventTaskNull(t *testing.T) {
 		t.Errorf("expected nil task_id after delete, got %v", got.TaskID)
 	}
 }
+
+// --- Merge-friendly identities ---
+
+func TestTaskFileNameIncludesUID(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Task"})
+	if len(task.UID) != 26 {
+		t.Fatalf("expected 26-char uid, got %q", task.UID)
+	}
+	want := filepath.Join(s.tasksDir(), fmt.Sprintf("1-%s.json", task.UID))
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("expected task file %s: %v", want, err)
+	}
+}
+
+func TestLegacyTaskFileStillReadable(t *testing.T) {
+	s := newTestStore(t)
+	legacy := `{"id": 3, "title": "Legacy", "status": "todo", "ref_id": "GHST-3"}`
+	os.WriteFile(filepath.Join(s.tasksDir(), "3.json"), []byte(legacy), 0644)
+
+	got, err := s.GetTask(3)
+	if err != nil {
+		t.Fatalf("getting legacy task: %v", err)
+	}
+	if got.Title != "Legacy" {
+		t.Errorf("unexpected title: %q", got.Title)
+	}
+
+	next, _ := s.CreateTask(CreateTaskInput{Title: "After legacy"})
+	if next.ID != 4 {
+		t.Errorf("expected id 4 after legacy task 3, got %d", next.ID)
+	}
+
+	title := "Legacy updated"
+	if _, err := s.UpdateTask(3, TaskUpdate{Title: &title}); err != nil {
+		t.Fatalf("updating legacy task: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.tasksDir(), "3.json")); err != nil {
+		t.Errorf("expected legacy file to be updated in place: %v", err)
+	}
+}
+
+func TestMergeFixRenumbersDuplicates(t *testing.T) {
+	s := newTestStore(t)
+
+	// Simulate two branches that each created task 1 and an event linked to it.
+	ours, _ := s.CreateTask(CreateTaskInput{Title: "Ours"})
+	oursEvent, _ := s.CreateEvent("log", "ours", "{}", &ours.ID)
+	theirs := models.Task{ID: 1, UID: newUID(), Title: "Theirs", Status: "todo", RefID: "GHST-1", CreatedAt: ours.CreatedAt.Add(time.Second)}
+	s.writeTask(&theirs)
+	one := int64(1)
+	theirsEvent := models.Event{ID: oursEvent.ID, UID: newUID(), Type: "log", Message: "theirs", TaskID: &one, TaskUID: theirs.UID, CreatedAt: theirs.CreatedAt}
+	s.writeEvent(&theirsEvent)
+
+	if _, err := s.GetTask(1); err == nil {
+		t.Fatal("expected ambiguity error before merge-fix")
+	}
+
+	dry, err := s.MergeFix(true)
+	if err != nil {
+		t.Fatalf("dry-run merge-fix: %v", err)
+	}
+	if len(dry.Renumbered) != 2 {
+		t.Fatalf("expected 2 renumberings in dry run, got %+v", dry.Renumbered)
+	}
+	if _, err := s.GetTask(1); err == nil {
+		t.Fatal("dry run should not change anything")
+	}
+
+	report, err := s.MergeFix(false)
+	if err != nil {
+		t.Fatalf("merge-fix: %v", err)
+	}
+	if len(report.Renumbered) != 2 || report.EventsRelinked != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	kept, err := s.GetTask(1)
+	if err != nil || kept.Title != "Ours" {
+		t.Fatalf("expected oldest task to keep id 1, got %+v, %v", kept, err)
+	}
+	moved, err := s.GetTask(2)
+	if err != nil || moved.Title != "Theirs" || moved.RefID != "GHST-2" || moved.UID != theirs.UID {
+		t.Fatalf("expected newer task at id 2, got %+v, %v", moved, err)
+	}
+
+	events, _ := s.ListEventsByTask(2)
+	if events = withoutAudit(events); len(events) != 1 || events[0].Message != "theirs" {
+		t.Errorf("expected 'theirs' event relinked to task 2, got %+v", events)
+	}
+	events, _ = s.ListEventsByTask(1)
+	if events = withoutAudit(events); len(events) != 1 || events[0].Message != "ours" {
+		t.Errorf("expected 'ours' event to stay on task 1, got %+v", events)
+	}
+
+	again, _ := s.MergeFix(false)
+	if len(again.Renumbered) != 0 {
+		t.Errorf("expected merge-fix to be idempotent, got %+v", again)
+	}
+}
+
+func TestMergeFixRelinksTaskLinks(t *testing.T) {
+	s := newTestStore(t)
+
+	// Our branch: task 1 blocks task 2. Theirs: another task 1, which blocks
+	// task 3 and is the parent of task 4.
+	ours, _ := s.CreateTask(CreateTaskInput{Title: "Ours"})
+	oursBlocked, _ := s.CreateTask(CreateTaskInput{Title: "Blocked by ours"})
+	s.LinkTasks(ours.ID, oursBlocked.ID, "")
+	one := int64(1)
+	later := ours.CreatedAt.Add(time.Second)
+	theirs := models.Task{ID: 1, UID: newUID(), Title: "Theirs", Status: "todo", RefID: "GHST-1", Blocks: []int64{3}, CreatedAt: later}
+	theirsBlocked := models.Task{ID: 3, UID: newUID(), Title: "Blocked by theirs", Status: "todo", RefID: "GHST-3", BlockedBy: []int64{1}, CreatedAt: later}
+	subtask := models.Task{ID: 4, UID: newUID(), Title: "Subtask of theirs", Status: "todo", RefID: "GHST-4", ParentID: &one, CreatedAt: later}
+	for _, task := range []*models.Task{&theirs, &theirsBlocked, &subtask} {
+		s.writeTask(task)
+	}
+
+	dry, err := s.MergeFix(true)
+	if err != nil {
+		t.Fatalf("dry-run merge-fix: %v", err)
+	}
+	if dry.LinksRelinked != 1 {
+		t.Errorf("expected one link to relink in the dry run, got %+v", dry)
+	}
+	if got, _ := s.GetTask(3); got.BlockedBy[0] != 1 {
+		t.Fatalf("dry run should not change links, got %v", got.BlockedBy)
+	}
+
+	report, err := s.MergeFix(false)
+	if err != nil {
+		t.Fatalf("merge-fix: %v", err)
+	}
+	if len(report.Renumbered) != 1 || report.Renumbered[0].NewID != 5 || report.LinksRelinked != 1 {
+		t.Fatalf("expected theirs moved to 5 and one link relinked, got %+v", report)
+	}
+	want := []AmbiguousLink{{TaskID: 4, Field: "parent_id", ID: 1, Candidates: []int64{1, 5}}}
+	if fmt.Sprint(report.Ambiguous) != fmt.Sprint(want) {
+		t.Errorf("expected the subtask's parent reported as ambiguous, got %+v", report.Ambiguous)
+	}
+
+	if got, _ := s.GetTask(3); !slices.Equal(got.BlockedBy, []int64{5}) {
+		t.Errorf("expected task 3 blocked by the renumbered task, got %v", got.BlockedBy)
+	}
+	if got, _ := s.GetTask(5); got.Title != "Theirs" || !slices.Equal(got.Blocks, []int64{3}) {
+		t.Errorf("expected the renumbered task to keep blocking task 3, got %+v", got)
+	}
+	if got, _ := s.GetTask(2); !slices.Equal(got.BlockedBy, []int64{1}) {
+		t.Errorf("expected task 2 still blocked by task 1, got %v", got.BlockedBy)
+	}
+	if got, _ := s.GetTask(4); got.ParentID == nil || *got.ParentID != 1 {
+		t.Errorf("expected the ambiguous parent left as it was, got %v", got.ParentID)
+	}
+}
+
+func TestUpdateOpportunity(t *testing.T) {
+	s := newTestStore(t)
+	opp, _ := s.CreateOpportunity("Acme", "Wants SSO", "")
+
+	notes := "Wants SSO and audit logs"
+	got, err := s.UpdateOpportunity(opp.ID, OpportunityUpdate{Notes: &notes})
+	if err != nil {
+		t.Fatalf("updating opportunity: %v", err)
+	}
+	if got.Name != "Acme" || got.Notes != notes {
+		t.Errorf("unexpected opportunity after update: %+v", got)
+	}
+
+	name := "Nope"
+	if _, err := s.UpdateOpportunity(999, OpportunityUpdate{Name: &name}); err == nil {
+		t.Error("expected error updating non-existent opportunity")
+	}
+}
+
+func TestDeleteOpportunity(t *testing.T) {
+	s := newTestStore(t)
+	opp, _ := s.CreateOpportunity("To delete", "", "")
+
+	if err := s.DeleteOpportunity(opp.ID, ""); err != nil {
+		t.Fatalf("deleting opportunity: %v", err)
+	}
+	if _, err := s.GetOpportunity(opp.ID); err == nil {
+		t.Fatal("expected error getting deleted opportunity")
+	}
+	if err := s.DeleteOpportunity(opp.ID, ""); err == nil {
+		t.Fatal("expected error deleting opportunity twice")
+	}
+}
+
+// --- Dependencies ---
+
+func TestLinkTasks(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "Schema"})
+	s.CreateTask(CreateTaskInput{Title: "API"})
+
+	if err := s.LinkTasks(1, 2, ""); err != nil {
+		t.Fatalf("linking tasks: %v", err)
+	}
+	blocker, _ := s.GetTask(1)
+	blocked, _ := s.GetTask(2)
+	if len(blocker.Blocks) != 1 || blocker.Blocks[0] != 2 {
+		t.Errorf("expected task 1 to block [2], got %v", blocker.Blocks)
+	}
+	if len(blocked.BlockedBy) != 1 || blocked.BlockedBy[0] != 1 {
+		t.Errorf("expected task 2 blocked_by [1], got %v", blocked.BlockedBy)
+	}
+
+	if err := s.UnlinkTasks(1, 2, ""); err != nil {
+		t.Fatalf("unlinking tasks: %v", err)
+	}
+	blocked, _ = s.GetTask(2)
+	if len(blocked.BlockedBy) != 0 {
+		t.Errorf("expected no blockers after unlink, got %v", blocked.BlockedBy)
+	}
+
+	// Both tasks' histories record the link and the unlink.
+	for id, want := range map[int64][]string{
+		1: {"GHST-1 now blocks GHST-2", "GHST-1 no longer blocks GHST-2"},
+		2: {"GHST-2 now blocked by GHST-1", "GHST-2 no longer blocked by GHST-1"},
+	} {
+		history, _ := s.TaskHistory(id)
+		var got []string
+		for _, e := range history[1:] {
+			got = append(got, e.Message)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected task %d's history %q, got %q", id, want, got)
+		}
+	}
+}
+
+func TestLinkTasksRejectsCycles(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "A"})
+	s.CreateTask(CreateTaskInput{Title: "B"})
+	s.CreateTask(CreateTaskInput{Title: "C"})
+
+	if err := s.LinkTasks(1, 1, ""); err == nil {
+		t.Error("expected error linking a task to itself")
+	}
+	if err := s.LinkTasks(1, 2, ""); err != nil {
+		t.Fatalf("linking 1→2: %v", err)
+	}
+	if err := s.LinkTasks(2, 3, ""); err != nil {
+		t.Fatalf("linking 2→3: %v", err)
+	}
+	if err := s.LinkTasks(3, 1, ""); err == nil {
+		t.Error("expected error for cycle 1→2→3→1")
+	}
+	if err := s.LinkTasks(1, 99, ""); err == nil {
+		t.Error("expected error linking to non-existent task")
+	}
+}
+
+func TestNextTasks(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "Blocker", Priority: "low"})
+	s.CreateTask(CreateTaskInput{Title: "Blocked", Priority: "urgent"})
+	s.CreateTask(CreateTaskInput{Title: "Free", Priority: "high"})
+	s.CreateTask(CreateTaskInput{Title: "Started", Status: "in_progress"})
+	s.LinkTasks(1, 2, "")
+
+	next, err := s.NextTasks()
+	if err != nil {
+		t.Fatalf("listing next tasks: %v", err)
+	}
+	if len(next) != 2 || next[0].ID != 3 || next[1].ID != 1 {
+		t.Fatalf("expected [3 1], got %+v", next)
+	}
+
+	done := "done"
+	s.UpdateTask(1, TaskUpdate{Status: &done})
+	next, _ = s.NextTasks()
+	if len(next) != 2 || next[0].ID != 2 {
+		t.Errorf("expected unblocked urgent task 2 first, got %+v", next)
+	}
+}
+
+func TestDeleteTaskClearsLinks(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "A"})
+	s.CreateTask(CreateTaskInput{Title: "B"})
+	s.CreateTask(CreateTaskInput{Title: "C"})
+	s.LinkTasks(1, 2, "")
+	s.LinkTasks(2, 3, "")
+	linkedAt := make(map[int64]time.Time)
+	for _, id := range []int64{1, 3} {
+		task, _ := s.GetTask(id)
+		linkedAt[id] = task.UpdatedAt
+	}
+
+	if err := s.DeleteTask(2); err != nil {
+		t.Fatalf("deleting task: %v", err)
+	}
+	a, _ := s.GetTask(1)
+	c, _ := s.GetTask(3)
+	if len(a.Blocks) != 0 || len(c.BlockedBy) != 0 {
+		t.Errorf("expected dangling links removed, got blocks=%v blocked_by=%v", a.Blocks, c.BlockedBy)
+	}
+	for _, task := range []*models.Task{a, c} {
+		if !task.UpdatedAt.After(linkedAt[task.ID]) {
+			t.Errorf("expected task %d's updated_at bumped", task.ID)
+		}
+		history, _ := s.TaskHistory(task.ID)
+		last := history[len(history)-1]
+		if last.Type != models.EventTaskUnlinked || !strings.Contains(last.Message, "GHST-2") {
+			t.Errorf("expected task %d's history to end with the unlink from GHST-2, got %+v", task.ID, last)
+		}
+	}
+}
+
+// --- Subtasks ---
+
+func TestSubtasks(t *testing.T) {
+	s := newTestStore(t)
+	parent, _ := s.CreateTask(CreateTaskInput{Title: "Epic", Milestone: "v1"})
+	child, err := s.CreateTask(CreateTaskInput{Title: "Step 1", ParentID: &parent.ID})
+	if err != nil {
+		t.Fatalf("creating subtask: %v", err)
+	}
+	s.CreateTask(CreateTaskInput{Title: "Step 2", Status: "done", ParentID: &parent.ID})
+	s.CreateTask(CreateTaskInput{Title: "Step 1a", ParentID: &child.ID})
+
+	missing := int64(99)
+	if _, err := s.CreateTask(CreateTaskInput{Title: "Orphan", ParentID: &missing}); err == nil {
+		t.Error("expected error creating subtask of non-existent task")
+	}
+
+	subtasks, err := s.ListSubtasks(parent.ID)
+	if err != nil {
+		t.Fatalf("listing subtasks: %v", err)
+	}
+	if len(subtasks) != 3 {
+		t.Errorf("expected 3 descendants, got %d", len(subtasks))
+	}
+
+	// Moving a task under its own descendant would create a cycle.
+	if _, err := s.UpdateTask(parent.ID, TaskUpdate{ParentID: &child.ID}); err == nil {
+		t.Error("expected error moving task under its own subtask")
+	}
+}
+
+func TestProgressCountsLeaves(t *testing.T) {
+	s := newTestStore(t)
+	parent, _ := s.CreateTask(CreateTaskInput{Title: "Epic", Milestone: "v1"})
+	s.CreateTask(CreateTaskInput{Title: "Step 1", ParentID: &parent.ID})
+	s.CreateTask(CreateTaskInput{Title: "Step 2", Status: "done", ParentID: &parent.ID})
+
+	counts, _ := s.TaskCountsByStatus()
+	if counts["todo"] != 1 || counts["done"] != 1 {
+		t.Errorf("expected leaf counts todo=1 done=1, got %v", counts)
+	}
+
+	milestones, _ := s.MilestoneInfo()
+	if len(milestones) != 1 || milestones[0].Total != 2 || milestones[0].Done != 1 {
+		t.Errorf("expected v1 progress 1/2 from leaves, got %+v", milestones)
+	}
+}
+
+func TestDeleteTaskReparentsChildren(t *testing.T) {
+	s := newTestStore(t)
+	root, _ := s.CreateTask(CreateTaskInput{Title: "Root"})
+	mid, _ := s.CreateTask(CreateTaskInput{Title: "Mid", ParentID: &root.ID})
+	leaf, _ := s.CreateTask(CreateTaskInput{Title: "Leaf", ParentID: &mid.ID})
+
+	s.DeleteTask(mid.ID)
+
+	got, _ := s.GetTask(leaf.ID)
+	if got.ParentID == nil || *got.ParentID != root.ID {
+		t.Errorf("expected leaf to move up to root, got parent %v", got.ParentID)
+	}
+}
+
+// --- Audit trail ---
+
+func TestUpdateTaskRecordsAuditEvents(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Audit me"})
+
+	status := "in_progress"
+	plan := "## Steps"
+	s.UpdateTask(task.ID, TaskUpdate{Status: &status, Plan: &plan})
+	status = "done"
+	same := "Audit me"
+	s.UpdateTask(task.ID, TaskUpdate{Status: &status, Title: &same})
+
+	history, err := s.TaskHistory(task.ID)
+	if err != nil {
+		t.Fatalf("reading history: %v", err)
+	}
+	var types []string
+	for _, e := range history {
+		types = append(types, e.Type)
+	}
+	want := []string{models.EventTaskCreated, models.EventTaskPlanUpdated, models.EventTaskStatusChanged, models.EventTaskStatusChanged}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("expected history %v, got %v", want, types)
+	}
+
+	var change models.FieldChange
+	if err := json.Unmarshal([]byte(history[3].Metadata), &change); err != nil {
+		t.Fatalf("parsing metadata: %v", err)
+	}
+	if change.Field != "status" || change.Old != "in_progress" || change.New != "done" {
+		t.Errorf("unexpected change metadata: %+v", change)
+	}
+}
+
+// --- Plan revisions ---
+
+func TestPlanRevisions(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Plan me"})
+
+	for _, plan := range []string{"v1", "v2", "v3"} {
+		p := plan
+		if _, err := s.UpdateTask(task.ID, TaskUpdate{Plan: &p}); err != nil {
+			t.Fatalf("updating plan: %v", err)
+		}
+	}
+	status := "in_progress"
+	s.UpdateTask(task.ID, TaskUpdate{Status: &status}) // no new revision
+
+	revs, err := s.ListPlanRevisions(task.ID)
+	if err != nil {
+		t.Fatalf("listing revisions: %v", err)
+	}
+	if len(revs) != 3 || revs[0].Plan != "v1" || revs[2].Plan != "v3" || revs[2].Rev != 3 {
+		t.Fatalf("unexpected revisions: %+v", revs)
+	}
+
+	restored, err := s.RestorePlanRevision(task.ID, 1, "")
+	if err != nil {
+		t.Fatalf("restoring revision: %v", err)
+	}
+	if restored.Plan != "v1" {
+		t.Errorf("expected restored plan v1, got %q", restored.Plan)
+	}
+	revs, _ = s.ListPlanRevisions(task.ID)
+	if len(revs) != 4 || revs[3].Plan != "v1" {
+		t.Errorf("expected restore to add revision 4, got %+v", revs)
+	}
+
+	if _, err := s.GetPlanRevision(task.ID, 9); err == nil {
+		t.Error("expected error for missing revision")
+	}
+}
+
+func TestPlanRevisionsKeepPreexistingPlan(t *testing.T) {
+	s := newTestStore(t)
+	legacy := `{"id": 1, "title": "Legacy", "plan": "original", "status": "todo", "ref_id": "GHST-1"}`
+	os.WriteFile(filepath.Join(s.tasksDir(), "1.json"), []byte(legacy), 0644)
+
+	plan := "rewritten"
+	s.UpdateTask(1, TaskUpdate{Plan: &plan})
+
+	revs, _ := s.ListPlanRevisions(1)
+	if len(revs) != 2 || revs[0].Plan != "original" || revs[1].Plan != "rewritten" {
+		t.Errorf("expected original plan saved as revision 1, got %+v", revs)
+	}
+}
+
+// --- Workflow ---
+
+func TestWorkflowEnforcement(t *testing.T) {
+	s := newTestStore(t)
+	err := s.SetWorkflow(models.Workflow{
+		Statuses: []models.WorkflowStatus{{Name: "open"}, {Name: "review"}, {Name: "shipped", Done: true}},
+		Transitions: map[string][]string{
+			"open":   {"review"},
+			"review": {"open", "shipped"},
+		},
+		Priorities: []string{"p2", "p1", "p0"},
+	})
+	if err != nil {
+		t.Fatalf("saving workflow: %v", err)
+	}
+
+	task, err := s.CreateTask(CreateTaskInput{Title: "Custom", Priority: "p0", Milestone: "v1"})
+	if err != nil {
+		t.Fatalf("creating task: %v", err)
+	}
+	if task.Status != "open" {
+		t.Errorf("expected initial status 'open', got %q", task.Status)
+	}
+
+	var verr *ValidationError
+	if _, err := s.CreateTask(CreateTaskInput{Title: "Typo", Status: "in-progress"}); !errors.As(err, &verr) {
+		t.Errorf("expected validation error for unknown status, got %v", err)
+	}
+	if _, err := s.CreateTask(CreateTaskInput{Title: "Typo", Priority: "high"}); !errors.As(err, &verr) {
+		t.Errorf("expected validation error for unknown priority, got %v", err)
+	}
+
+	shipped := "shipped"
+	if _, err := s.UpdateTask(task.ID, TaskUpdate{Status: &shipped}); !errors.As(err, &verr) {
+		t.Errorf("expected open → shipped to be rejected, got %v", err)
+	}
+	review := "review"
+	if _, err := s.UpdateTask(task.ID, TaskUpdate{Status: &review}); err != nil {
+		t.Fatalf("open → review: %v", err)
+	}
+	if _, err := s.UpdateTask(task.ID, TaskUpdate{Status: &shipped}); err != nil {
+		t.Fatalf("review → shipped: %v", err)
+	}
+
+	milestones, _ := s.MilestoneInfo()
+	if len(milestones) != 1 || milestones[0].Done != 1 {
+		t.Errorf("expected 'shipped' to count as done, got %+v", milestones)
+	}
+}
+
+// --- Ref prefix tests ---
+
+func TestSetRefPrefix(t *testing.T) {
+	s := newTestStore(t)
+	old, _ := s.CreateTask(CreateTaskInput{Title: "Before"})
+
+	migrated, err := s.SetRefPrefix("api-")
+	if err != nil {
+		t.Fatalf("SetRefPrefix: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("expected 1 task migrated, got %d", migrated)
+	}
+	got, _ := s.GetTask(old.ID)
+	if got.RefID != "API-1" {
+		t.Errorf("expected migrated ref API-1, got %q", got.RefID)
+	}
+	task, _ := s.CreateTask(CreateTaskInput{Title: "After"})
+	if task.RefID != "API-2" {
+		t.Errorf("expected API-2, got %q", task.RefID)
+	}
+
+	if _, err := s.SetRefPrefix("WEB"); err != nil {
+		t.Fatalf("SetRefPrefix: %v", err)
+	}
+	for _, ref := range []string{"WEB-2", "API-2", "GHST-2", "2"} {
+		if id, err := s.ParseTaskID(ref); err != nil || id != 2 {
+			t.Errorf("ParseTaskID(%q) = %d, %v; want 2", ref, id, err)
+		}
+	}
+	if _, err := s.ParseTaskID("OPS-2"); err == nil {
+		t.Error("expected unknown prefix to be rejected")
+	}
+	refs, _ := s.ParseCommitRefs("Fixes API-1 after WEB-2 (not OPS-3)")
+	if len(refs) != 2 || refs[0].ID != 1 || !refs[0].Closes || refs[1].ID != 2 || refs[1].Closes {
+		t.Errorf("expected commit refs under the current and former prefixes, got %+v", refs)
+	}
+
+	var verr *ValidationError
+	if _, err := s.SetRefPrefix("my-app"); !errors.As(err, &verr) {
+		t.Errorf("expected validation error for bad prefix, got %v", err)
+	}
+}
+
+// --- Label tests ---
+
+func TestTaskLabels(t *testing.T) {
+	s := newTestStore(t)
+	task, err := s.CreateTask(CreateTaskInput{Title: "Login", Labels: []string{"Auth", "infra", "auth"}})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if fmt.Sprint(task.Labels) != "[auth infra]" {
+		t.Errorf("expected normalized labels [auth infra], got %v", task.Labels)
+	}
+
+	task, err = s.UpdateTask(task.ID, TaskUpdate{AddLabels: []string{"ui"}, RemoveLabels: []string{"infra"}})
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if fmt.Sprint(task.Labels) != "[auth ui]" {
+		t.Errorf("expected [auth ui], got %v", task.Labels)
+	}
+	history, _ := s.TaskHistory(task.ID)
+	if len(history) != 2 || history[1].Type != models.EventTaskLabelsChanged {
+		t.Errorf("expected one labels_changed event, got %+v", history)
+	}
+
+	var verr *ValidationError
+	if _, err := s.UpdateTask(task.ID, TaskUpdate{AddLabels: []string{"two words"}}); !errors.As(err, &verr) {
+		t.Errorf("expected validation error for bad label, got %v", err)
+	}
+}
+
+func TestFilterTasksByLabel(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "A", Labels: []string{"auth"}})
+	s.CreateTask(CreateTaskInput{Title: "B", Labels: []string{"auth", "infra"}})
+	s.CreateTask(CreateTaskInput{Title: "C", Labels: []string{"infra"}})
+	s.CreateTask(CreateTaskInput{Title: "D"})
+
+	all, err := s.FilterTasks(TaskFilter{Labels: []string{"auth", "infra"}})
+	if err != nil {
+		t.Fatalf("FilterTasks: %v", err)
+	}
+	if len(all) != 1 || all[0].Title != "B" {
+		t.Errorf("expected only B to have both labels, got %v", all)
+	}
+
+	either, _ := s.FilterTasks(TaskFilter{Labels: []string{"auth", "infra"}, AnyLabel: true})
+	if len(either) != 3 {
+		t.Errorf("expected 3 tasks with either label, got %d", len(either))
+	}
+
+	counts, _ := s.LabelCounts()
+	if fmt.Sprint(counts) != "[{auth 2} {infra 2}]" {
+		t.Errorf("unexpected label counts %v", counts)
+	}
+}
+
+// --- Search tests ---
+
+func TestSearch(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Fix login redirect", Description: "Blank page after sign-in"})
+	s.CreateTask(CreateTaskInput{Title: "Rate limiting"})
+	s.CreateEvent("decision", "Keep session cookies for login", "{}", &task.ID)
+
+	results, err := s.Search("login", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected a task and an event hit, got %+v", results)
+	}
+	if results[0].Kind != "task" || results[0].ID != task.ID || results[0].Field != "title" {
+		t.Errorf("expected the task title first, got %+v", results[0])
+	}
+	if results[1].Kind != "event" || results[1].Snippet != "Keep session cookies for **login**" {
+		t.Errorf("unexpected event hit %+v", results[1])
+	}
+
+	// Writes through the store update the index.
+	plan := "Throttle the login endpoint"
+	other, _ := s.ListTasks("", "", "", "")
+	s.UpdateTask(other[1].ID, TaskUpdate{Plan: &plan})
+	if results, _ := s.Search("throttle", 10); len(results) != 1 || results[0].Field != "plan" {
+		t.Errorf("expected plan hit after update, got %+v", results)
+	}
+
+	// Files changed behind the store's back are picked up too.
+	os.Remove(s.taskPath(task))
+	if results, _ := s.Search("redirect", 10); len(results) != 0 {
+		t.Errorf("expected deleted file to drop out of the index, got %+v", results)
+	}
+
+	// An up-to-date index is searched without waiting for a writer.
+	release, err := s.fs.lock()
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer release()
+	hits := make(chan int)
+	go func() {
+		results, _ := s.Search("throttle", 10)
+		hits <- len(results)
+	}()
+	select {
+	case n := <-hits:
+		if n != 1 {
+			t.Errorf("expected a hit while another process holds the lock, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Search not to wait for the store lock")
+	}
+}
+
+func TestGitignoreListsLocalArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("mine\n"), 0644)
+	if _, err := Open(dir); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	for _, want := range []string{"mine", "/index/", "/.lock"} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Errorf(".gitignore missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestArchiveTask(t *testing.T) {
+	s := newTestStore(t)
+	parent, _ := s.CreateTask(CreateTaskInput{Title: "Epic"})
+	done, _ := s.CreateTask(CreateTaskInput{Title: "Shipped", Status: "done", ParentID: &parent.ID})
+	s.CreateTask(CreateTaskInput{Title: "Open"})
+
+	archived := true
+	task, err := s.UpdateTask(done.ID, TaskUpdate{Archived: &archived})
+	if err != nil {
+		t.Fatalf("archiving: %v", err)
+	}
+	if !task.Archived {
+		t.Fatal("expected task to be archived")
+	}
+
+	tasks, _ := s.ListTasks("", "", "", "")
+	if len(tasks) != 2 {
+		t.Errorf("expected archived task to be hidden, got %d tasks", len(tasks))
+	}
+	all, _ := s.FilterTasks(TaskFilter{IncludeArchived: true})
+	if len(all) != 3 {
+		t.Errorf("expected 3 tasks with IncludeArchived, got %d", len(all))
+	}
+	if counts, _ := s.TaskCountsByStatus(); counts["done"] != 0 {
+		t.Errorf("expected archived task to drop out of counts, got %v", counts)
+	}
+	// Archived tasks still show up as subtasks and keep their history.
+	if subtasks, _ := s.ListSubtasks(parent.ID); len(subtasks) != 1 {
+		t.Errorf("expected archived subtask to be listed under its parent, got %d", len(subtasks))
+	}
+	history, _ := s.TaskHistory(done.ID)
+	if len(history) != 2 || history[1].Type != models.EventTaskArchivedChanged || history[1].Message != done.RefID+" archived" {
+		t.Errorf("expected an archive audit event, got %+v", history)
+	}
+
+	archived = false
+	s.UpdateTask(done.ID, TaskUpdate{Archived: &archived})
+	if tasks, _ := s.ListTasks("", "", "", ""); len(tasks) != 3 {
+		t.Errorf("expected unarchived task to be listed again, got %d tasks", len(tasks))
+	}
+}
+
+// --- Trash and undo tests ---
+
+func TestRestoreTaskFromTrash(t *testing.T) {
+	s := newTestStore(t)
+	parent, _ := s.CreateTask(CreateTaskInput{Title: "Epic"})
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Doomed", ParentID: &parent.ID})
+	child, _ := s.CreateTask(CreateTaskInput{Title: "Step", ParentID: &task.ID})
+	other, _ := s.CreateTask(CreateTaskInput{Title: "Later"})
+	s.LinkTasks(task.ID, other.ID, "")
+	event, _ := s.CreateEvent("note", "context", "{}", &task.ID)
+
+	if err := s.DeleteTask(task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	trashed, _ := s.ListTrash()
+	if len(trashed) != 1 || trashed[0].Task.ID != task.ID || len(trashed[0].UnlinkedEvents) != 3 || len(trashed[0].Children) != 1 {
+		t.Fatalf("expected the task in the trash with its links, got %+v", trashed)
+	}
+
+	// A new task does not take the trashed task's ID, so it comes back as is.
+	s.CreateTask(CreateTaskInput{Title: "Newcomer"})
+	report, err := s.RestoreTask(task.ID)
+	if err != nil {
+		t.Fatalf("RestoreTask: %v", err)
+	}
+	restored := report.Task
+	if restored.ID != task.ID || restored.UID != task.UID || restored.RefID != task.RefID {
+		t.Errorf("expected the task back under its own ID, got %+v", restored)
+	}
+	if report.EventsRelinked != 3 || report.ChildrenReattached != 1 {
+		t.Errorf("unexpected restore report %+v", report)
+	}
+	if e, _ := s.GetEvent(event.ID); e.TaskID == nil || *e.TaskID != restored.ID {
+		t.Errorf("expected event to be re-linked, got %+v", e)
+	}
+	if c, _ := s.GetTask(child.ID); c.ParentID == nil || *c.ParentID != restored.ID {
+		t.Errorf("expected subtask back under the restored task, got %+v", c.ParentID)
+	}
+	if o, _ := s.GetTask(other.ID); len(o.BlockedBy) != 1 || o.BlockedBy[0] != restored.ID {
+		t.Errorf("expected dependency to be restored, got %v", o.BlockedBy)
+	}
+	if trashed, _ := s.ListTrash(); len(trashed) != 0 {
+		t.Errorf("expected empty trash after restore, got %+v", trashed)
+	}
+}
+
+func TestDeletedTaskIDsAreNotReused(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "First"})
+	trashed, _ := s.CreateTask(CreateTaskInput{Title: "Trashed"})
+	purged, _ := s.CreateTask(CreateTaskInput{Title: "Purged"})
+	note, _ := s.CreateEvent("note", "about the trashed task", "{}", &trashed.ID)
+	backdate(t, s, note, "2025-11-05")
+	old, _ := s.CreateEvent("note", "about the purged task", "{}", &purged.ID)
+	backdate(t, s, old, "2025-11-06")
+	s.CreateEvent("log", "newest", "{}", nil)
+	if _, err := s.ArchiveEvents(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true, false); err != nil {
+		t.Fatalf("ArchiveEvents: %v", err)
+	}
+
+	// The purged task is gone but its archived history still names it.
+	s.DeleteTask(purged.ID)
+	s.EmptyTrash()
+	s.DeleteTask(trashed.ID)
+	task, err := s.CreateTask(CreateTaskInput{Title: "Newcomer"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if task.ID != purged.ID+1 {
+		t.Errorf("expected ID %d, past the trashed and archived tasks, got %d", purged.ID+1, task.ID)
+	}
+
+	report, err := s.RestoreTask(trashed.ID)
+	if err != nil {
+		t.Fatalf("RestoreTask: %v", err)
+	}
+	// Its task.created event is relinked along with the archived note.
+	if report.Task.ID != trashed.ID || report.EventsRelinked != 2 {
+		t.Errorf("expected the task back with its archived note, got %+v", report)
+	}
+	if events, _ := s.ListEventsByTask(trashed.ID); len(withoutAudit(events)) != 1 || withoutAudit(events)[0].ID != note.ID {
+		t.Errorf("expected the archived note in the restored task's history, got %+v", events)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Task"})
+	s.CreateEvent("note", "keep me", "{}", &task.ID)
+
+	s.BeginOperation("ghist task delete 1")
+	s.DeleteTask(task.ID)
+	s.Close()
+
+	s.BeginOperation("ghist task add Other")
+	s.CreateTask(CreateTaskInput{Title: "Other"})
+	s.Close()
+
+	op, err := s.Undo()
+	if err != nil || op.Name != "ghist task add Other" {
+		t.Fatalf("expected to undo the add, got %+v, %v", op, err)
+	}
+	if tasks, _ := s.ListTasks("", "", "", ""); len(tasks) != 0 {
+		t.Errorf("expected no tasks after undoing the add, got %d", len(tasks))
+	}
+	if _, err := s.Undo(); err != nil {
+		t.Fatalf("undoing the delete: %v", err)
+	}
+	if got, err := s.GetTask(task.ID); err != nil || got.Title != "Task" {
+		t.Errorf("expected the deleted task back, got %+v, %v", got, err)
+	}
+	if events, _ := s.ListEventsByTask(task.ID); len(withoutAudit(events)) != 1 {
+		t.Errorf("expected the event to be linked again, got %+v", events)
+	}
+	if trashed, _ := s.ListTrash(); len(trashed) != 0 {
+		t.Errorf("expected undo to remove the trash entry, got %+v", trashed)
+	}
+	if _, err := s.Undo(); err == nil {
+		t.Error("expected nothing left to undo")
+	}
+
+	// Undo refuses to clobber changes made since.
+	s.BeginOperation("ghist task update 1")
+	title := "Renamed"
+	s.UpdateTask(task.ID, TaskUpdate{Title: &title})
+	s.Close()
+	title = "Renamed again"
+	s.UpdateTask(task.ID, TaskUpdate{Title: &title})
+	if _, err := s.Undo(); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Errorf("expected undo to refuse after a later change, got %v", err)
+	}
+}
+
+// --- Cache tests ---
+
+func TestListTasksSeesExternalEdits(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Original", Labels: []string{"auth"}})
+	tasks, _ := s.ListTasks("", "", "", "")
+	tasks[0].Labels[0] = "mutated" // must not leak into the cache
+
+	edited := *task
+	edited.Title = "Edited by hand"
+	data, _ := json.MarshalIndent(edited, "", "  ")
+	// Like git and most editors, replace the file rather than rewrite it.
+	path := s.taskPath(task)
+	os.WriteFile(path+".new", data, 0644)
+	os.Rename(path+".new", path)
+
+	tasks, err := s.ListTasks("", "", "", "")
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if tasks[0].Title != "Edited by hand" {
+		t.Errorf("expected external edit to be picked up, got %q", tasks[0].Title)
+	}
+	if tasks[0].Labels[0] != "auth" {
+		t.Errorf("expected cached labels to be unaffected by callers, got %v", tasks[0].Labels)
+	}
+}
+
+// --- Event archive tests ---
+
+// backdate rewrites an event's created_at, as if it had been logged then.
+func backdate(t *testing.T, s *FileStore, e *models.Event, when string) {
+	t.Helper()
+	at, err := time.Parse("2006-01-02", when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.CreatedAt = at
+	if err := s.writeEvent(e); err != nil {
+		t.Fatalf("writeEvent: %v", err)
+	}
+}
+
+func TestArchiveEvents(t *testing.T) {
+	s := newTestStore(t)
+	task, _ := s.CreateTask(CreateTaskInput{Title: "Auth"})
+	old, _ := s.CreateEvent("log", "old log", "{}", nil)
+	backdate(t, s, old, "2025-11-05")
+	decision, _ := s.CreateEvent("decision", "use sessions", "{}", &task.ID)
+	backdate(t, s, decision, "2025-11-10")
+	note, _ := s.CreateEvent("note", "tried JWT", "{}", &task.ID)
+	backdate(t, s, note, "2025-12-01")
+	recent, _ := s.CreateEvent("log", "recent", "{}", nil)
+	before, _ := s.ListEvents(100)
+
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	report, err := s.ArchiveEvents(cutoff, false, true)
+	if err != nil {
+		t.Fatalf("ArchiveEvents dry run: %v", err)
+	}
+	if report.Archived != 2 || report.Kept != 1 {
+		t.Errorf("expected 2 archived and 1 kept, got %+v", report)
+	}
+	if _, err := os.Stat(s.eventArchiveDir()); !os.IsNotExist(err) {
+		t.Errorf("dry run should not create the archive")
+	}
+
+	if _, err := s.ArchiveEvents(cutoff, false, false); err != nil {
+		t.Fatalf("ArchiveEvents: %v", err)
+	}
+	for _, m := range []string{"2025-11", "2025-12"} {
+		if _, err := os.Stat(filepath.Join(s.eventArchiveDir(), m+".jsonl")); err != nil {
+			t.Errorf("expected archive for %s: %v", m, err)
+		}
+	}
+	if _, err := os.Stat(s.eventPath(old)); !os.IsNotExist(err) {
+		t.Errorf("expected archived event file to be removed")
+	}
+	if _, err := os.Stat(s.eventPath(decision)); err != nil {
+		t.Errorf("expected task-linked decision to stay live: %v", err)
+	}
+
+	after, err := s.ListEvents(100)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("expected %d events including archived ones, got %d", len(before), len(after))
+	}
+	if latest, _ := s.ListEvents(1); len(latest) != 1 || latest[0].ID != recent.ID {
+		t.Errorf("expected only the newest event, got %+v", latest)
+	}
+	history, _ := s.ListEventsByTask(task.ID)
+	if len(history) < 2 || history[len(history)-1].ID != decision.ID {
+		t.Errorf("expected task history to include archived events, got %+v", history)
+	}
+	if e, err := s.GetEvent(old.ID); err != nil || e.Message != "old log" {
+		t.Errorf("expected GetEvent to read the archive, got %+v, %v", e, err)
+	}
+
+	next, _ := s.CreateEvent("log", "next", "{}", nil)
+	if next.ID <= recent.ID {
+		t.Errorf("expected new event ID above %d, got %d", recent.ID, next.ID)
+	}
+
+	// Archives are append-only: a second run adds to the month's file.
+	more, _ := s.CreateEvent("log", "another old one", "{}", nil)
+	backdate(t, s, more, "2025-11-20")
+	s.CreateEvent("log", "newest", "{}", nil)
+	if report, _ := s.ArchiveEvents(cutoff, true, false); report.Archived != 2 {
+		t.Errorf("expected --all to archive the decision and the new old event, got %+v", report)
+	}
+	archived, _ := s.readArchive("2025-11")
+	if len(archived) != 3 || archived[0].ID != old.ID {
+		t.Errorf("expected 3 events appended to 2025-11, got %+v", archived)
+	}
+}
+
+func TestArchivedEventsFollowTaskUID(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "Keeps ID 1"})
+	gone, _ := s.CreateTask(CreateTaskInput{Title: "Deleted"})
+	note, _ := s.CreateEvent("note", "about the deleted task", "{}", &gone.ID)
+	backdate(t, s, note, "2025-11-05")
+	s.CreateEvent("log", "newest", "{}", nil)
+	if _, err := s.ArchiveEvents(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true, false); err != nil {
+		t.Fatalf("ArchiveEvents: %v", err)
+	}
+
+	if err := s.DeleteTask(gone.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	// Stand in for a task that later took the ID.
+	taker := &models.Task{ID: gone.ID, UID: newUID(), Title: "Took the ID", Status: "todo", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	s.writeTask(taker)
+	events, _ := s.ListEventsByTask(gone.ID)
+	for _, e := range events {
+		if e.ID == note.ID {
+			t.Errorf("expected the deleted task's archived note not to attach to the task that took its ID")
+		}
+	}
+
+	report, _ := s.Doctor(false)
+	for _, p := range report.Problems {
+		if p.Kind == ProblemDanglingTask {
+			t.Errorf("expected no dangling archived events while the task is in the trash, got %+v", p)
+		}
+	}
+	s.removeFile(s.taskPath(taker))
+	s.EmptyTrash()
+	report, _ = s.Doctor(true)
+	found := false
+	for _, p := range report.Problems {
+		found = found || p.Kind == ProblemDanglingTask && p.Fixed && strings.HasPrefix(p.Path, "archive/")
+	}
+	if !found {
+		t.Fatalf("expected doctor to unlink the archived event of a purged task, got %+v", report.Problems)
+	}
+	archived, _ := s.readArchive("2025-11")
+	if len(archived) != 1 || archived[0].TaskID != nil || archived[0].TaskUID != "" {
+		t.Errorf("expected the archived event unlinked, got %+v", archived)
+	}
+}
+
+func TestAutoCompaction(t *testing.T) {
+	s := newTestStore(t)
+	st, _ := s.readSettings()
+	st.EventRetention = &models.EventRetention{ArchiveAfterDays: 30}
+	s.writeSettings(st)
+
+	old, _ := s.CreateEvent("log", "stale", "{}", nil)
+	backdate(t, s, old, "2025-01-15")
+	marker := filepath.Join(s.root, indexDir, "compacted_at")
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected the first write to run compaction: %v", err)
+	}
+	// Compaction runs at most once a day; pretend the last run was yesterday.
+	yesterday := time.Now().Add(-25 * time.Hour)
+	os.Chtimes(marker, yesterday, yesterday)
+	s.CreateEvent("log", "fresh", "{}", nil)
+
+	if _, err := os.Stat(s.eventPath(old)); !os.IsNotExist(err) {
+		t.Errorf("expected the stale event to be compacted on write")
+	}
+	if events, _ := s.ListEvents(10); len(events) != 2 {
+		t.Errorf("expected both events to stay listed, got %+v", events)
+	}
+	if info, _ := os.Stat(marker); info == nil || time.Since(info.ModTime()) > time.Hour {
+		t.Errorf("expected compaction marker to be refreshed")
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	s := newTestStore(t)
+	a, _ := s.CreateTask(CreateTaskInput{Title: "Keeps its ref"})
+	b, _ := s.CreateTask(CreateTaskInput{Title: "Copied ref"})
+	c, _ := s.CreateTask(CreateTaskInput{Title: "Misnamed"})
+
+	b.RefID = a.RefID
+	b.Status = "someday"
+	b.BlockedBy = []int64{42}
+	s.writeTask(b)
+	os.Rename(s.taskPath(c), filepath.Join(s.tasksDir(), recordFileName(9, c.UID)))
+	os.WriteFile(filepath.Join(s.opportunitiesDir(), "7-broken.json"), []byte("{"), 0644)
+	missing, gone := int64(42), int64(43)
+	byUID, _ := s.CreateEvent("note", "relinkable", "{}", nil)
+	byUID.TaskID, byUID.TaskUID = &missing, a.UID
+	s.writeEvent(byUID)
+	orphan, _ := s.CreateEvent("note", "orphan", "{}", nil)
+	orphan.TaskID = &gone
+	s.writeEvent(orphan)
+
+	kinds := func(r *DoctorReport) map[string]int {
+		out := make(map[string]int)
+		for _, p := range r.Problems {
+			if !p.Fixed {
+				out[p.Kind]++
+			}
+		}
+		return out
+	}
+
+	report, err := s.Doctor(false)
+	if err != nil {
+		t.Fatalf("Doctor: %v", err)
+	}
+	want := map[string]int{
+		ProblemMalformedJSON: 1, ProblemIDMismatch: 1, ProblemDuplicateRef: 1,
+		ProblemUnknownStatus: 1, ProblemDanglingLink: 1, ProblemDanglingTask: 2,
+	}
+	if got := kinds(report); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, report.Problems)
+	}
+	if got, _ := s.GetTask(b.ID); got.RefID != a.RefID {
+		t.Errorf("expected doctor without fix to change nothing, got ref %s", got.RefID)
+	}
+
+	report, err = s.Doctor(true)
+	if err != nil {
+		t.Fatalf("Doctor(fix): %v", err)
+	}
+	if n := report.Unresolved(); n != 2 {
+		t.Errorf("expected malformed JSON and unknown status to remain, got %+v", report.Problems)
+	}
+	if got, _ := s.GetTask(c.ID); got == nil || got.Title != "Misnamed" {
+		t.Errorf("expected misnamed task file to be renamed, got %+v", got)
+	}
+	if got, _ := s.GetTask(b.ID); got.RefID != models.FormatRef("GHST", b.ID) || len(got.BlockedBy) != 0 {
+		t.Errorf("expected duplicate ref reset and dangling link dropped, got %+v", got)
+	}
+	if got, _ := s.GetTask(a.ID); got.RefID != a.RefID {
+		t.Errorf("expected the original ref to stay with task %d, got %s", a.ID, got.RefID)
+	}
+	if e, _ := s.GetEvent(byUID.ID); e.TaskID == nil || *e.TaskID != a.ID {
+		t.Errorf("expected event to be re-linked by UID, got %+v", e.TaskID)
+	}
+	if e, _ := s.GetEvent(orphan.ID); e.TaskID != nil {
+		t.Errorf("expected orphaned event to be unlinked, got %d", *e.TaskID)
+	}
+
+	report, _ = s.Doctor(true)
+	want = map[string]int{ProblemMalformedJSON: 1, ProblemUnknownStatus: 1}
+	if got := kinds(report); fmt.Sprint(got) != fmt.Sprint(want) || len(report.Problems) != 2 {
+		t.Errorf("expected only unfixable problems on a second run, got %+v", report.Problems)
+	}
+}
+
+func TestDoctorCorruptSettings(t *testing.T) {
+	s := newTestStore(t)
+	s.CreateTask(CreateTaskInput{Title: "Survives"})
+	s.SetWorkflow(models.Workflow{Statuses: []models.WorkflowStatus{{Name: "open"}, {Name: "closed", Done: true}}})
+	good, _ := os.ReadFile(s.settingsPath())
+	conflicted := "<<<<<<< HEAD\n" + string(good) + "=======\n{}\n>>>>>>> other\n"
+	os.WriteFile(s.settingsPath(), []byte(conflicted), 0644)
+
+	p := CheckSettings(s.root)
+	if p == nil || p.Kind != ProblemMalformedJSON || p.Path != "settings.json" {
+		t.Fatalf("expected settings.json reported as malformed, got %+v", p)
+	}
+	if _, err := Open(s.root); err == nil {
+		t.Fatalf("expected Open to refuse the conflicted settings.json")
+	}
+	if data, _ := os.ReadFile(s.settingsPath()); string(data) != conflicted {
+		t.Fatalf("expected settings.json untouched, got %q", data)
+	}
+
+	os.WriteFile(s.settingsPath(), good, 0644)
+	if p := CheckSettings(s.root); p != nil {
+		t.Errorf("expected no problem once settings.json is fixed, got %+v", p)
+	}
+	s2, err := Open(s.root)
+	if err != nil {
+		t.Fatalf("Open after fixing settings.json: %v", err)
+	}
+	defer s2.Close()
+	if wf, _ := s2.Workflow(); len(wf.Statuses) != 2 || wf.Statuses[0].Name != "open" {
+		t.Errorf("expected the custom workflow kept, got %+v", wf.Statuses)
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	stressHelperEnv   = "GHIST_STRESS_HELPER_DIR"
+	stressGoroutines  = 16
+	stressSubprocs    = 4
+	stressTasksPerRun = 15
+)
+
+// TestStressHelperProcess is not a real test: it is re-executed as a
+// subprocess by TestConcurrentCreateTask to create tasks from another process.
+func TestStressHelperProcess(t *testing.T) {
+	dir := os.Getenv(stressHelperEnv)
+	if dir == "" {
+		return
+	}
+	s, err := Open(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i := 0; i < stressTasksPerRun; i++ {
+		if _, err := s.CreateTask(CreateTaskInput{Title: "subprocess task"}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if _, err := s.CreateEvent("log", "subprocess event", "{}", nil); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestConcurrentCreateTask(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines+stressSubprocs)
+
+	for p := 0; p < stressSubprocs; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestStressHelperProcess$")
+			cmd.Env = append(os.Environ(), stressHelperEnv+"="+dir)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("subprocess: %v: %s", err, out)
+			}
+		}()
+	}
+
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < stressTasksPerRun; i++ {
+				task, err := s.CreateTask(CreateTaskInput{Title: "goroutine task"})
+				if err != nil {
+					errs <- err
+					return
+				}
+				title := "updated"
+				if _, err := s.UpdateTask(task.ID, TaskUpdate{Title: &title}); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.CreateEvent("log", "goroutine event", "{}", &task.ID); err != nil {
+					errs <- err
+					return
+				}
+				// Readers must never observe half-written files.
+				if _, err := s.ListTasks("", "", "", ""); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	want := (stressGoroutines + stressSubprocs) * stressTasksPerRun
+	tasks, err := s.ListTasks("", "", "", "")
+	if err != nil {
+		t.Fatalf("listing tasks: %v", err)
+	}
+	if len(tasks) != want {
+		t.Errorf("expected %d tasks, got %d", want, len(tasks))
+	}
+	seen := make(map[int64]bool)
+	for _, task := range tasks {
+		if seen[task.ID] {
+			t.Errorf("duplicate task id %d", task.ID)
+		}
+		seen[task.ID] = true
+		if task.RefID != "GHST-"+strconv.FormatInt(task.ID, 10) {
+			t.Errorf("task %d has mismatched ref_id %q", task.ID, task.RefID)
+		}
+	}
+
+	events, err := s.readAllEvents()
+	if err != nil {
+		t.Fatalf("reading events: %v", err)
+	}
+	logged := 0
+	seenEvents := make(map[int64]bool)
+	for _, e := range events {
+		if seenEvents[e.ID] {
+			t.Errorf("duplicate event id %d", e.ID)
+		}
+		seenEvents[e.ID] = true
+		if e.Type == "log" {
+			logged++
+		}
+	}
+	if logged != want {
+		t.Errorf("expected %d log events, got %d", want, logged)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*", "*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+	release, err := s.fs.lock()
+	if err != nil {
+		t.Errorf("lock left held: %v", err)
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// ListSubtasks returns every descendant of task id (children, grandchildren
+// and so on) in ID order.
+func (s *FileStore) ListSubtasks(id int64) ([]models.Task, error) {
+	tasks, err := s.allTasks()
+	if err != nil {
+		return nil, err
+	}
+	parents := parentMap(tasks)
+	var out []models.Task
+	for _, t := range tasks {
+		if isDescendant(parents, t.ID, id) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// setParent points t at a new parent, or detaches it when parentID is 0.
+// A task cannot become a child of itself or of one of its descendants.
+func (s *FileStore) setParent(t *models.Task, parentID int64) error {
+	if parentID == 0 {
+		t.ParentID = nil
+		return nil
+	}
+	if parentID == t.ID {
+		return invalid(fmt.Errorf("task %d cannot be its own parent", t.ID))
+	}
+	if _, err := s.GetTask(parentID); isNotFound(err) {
+		return invalid(fmt.Errorf("parent task %d not found", parentID))
+	} else if err != nil {
+		return err
+	}
+	tasks, err := s.allTasks()
+	if err != nil {
+		return err
+	}
+	if isDescendant(parentMap(tasks), parentID, t.ID) {
+		return invalid(fmt.Errorf("cannot move task %d under task %d: task %d is one of its subtasks", t.ID, parentID, parentID))
+	}
+	t.ParentID = &parentID
+	return nil
+}
+
+// reparentChildren moves the children of a deleted task up to its parent and
+// returns the children it moved. Used as a cascade when a task is deleted;
+// callers must hold the store lock.
+func (s *FileStore) reparentChildren(id int64, parentID *int64) []models.RecordRef {
+	tasks, err := s.allTasks()
+	if err != nil {
+		return nil
+	}
+	var moved []models.RecordRef
+	for i := range tasks {
+		t := &tasks[i]
+		if t.ParentID != nil && *t.ParentID == id {
+			t.ParentID = parentID
+			if s.writeTask(t) == nil {
+				moved = append(moved, models.RecordRef{ID: t.ID, UID: t.UID})
+			}
+		}
+	}
+	return moved
+}
+
+// parentMap maps each task ID to its parent ID (nil for top-level tasks).
+func parentMap(tasks []models.Task) map[int64]*int64 {
+	parent := make(map[int64]*int64, len(tasks))
+	for _, t := range tasks {
+		parent[t.ID] = t.ParentID
+	}
+	return parent
+}
+
+// isDescendant reports whether task id sits somewhere below ancestor.
+func isDescendant(parent map[int64]*int64, id, ancestor int64) bool {
+	seen := map[int64]bool{}
+	for p := parent[id]; p != nil && !seen[*p]; p = parent[*p] {
+		if *p == ancestor {
+			return true
+		}
+		seen[*p] = true
+	}
+	return false
+}
+
+// leafTasks returns the tasks that have no children.
+func leafTasks(tasks []models.Task) []models.Task {
+	hasChildren := make(map[int64]bool)
+	for _, t := range tasks {
+		if t.ParentID != nil {
+			hasChildren[*t.ParentID] = true
+		}
+	}
+	var leaves []models.Task
+	for _, t := range tasks {
+		if !hasChildren[t.ID] {
+			leaves = append(leaves, t)
+		}
+	}
+	return leaves
+}
+
+// effectiveMilestone returns t's milestone, or its nearest ancestor's if t
+// has none of its own. byID indexes all tasks.
+func effectiveMilestone(byID map[int64]*models.Task, t *models.Task) string {
+	seen := map[int64]bool{}
+	for cur := t; cur != nil && !seen[cur.ID]; {
+		if cur.Milestone != "" {
+			return cur.Milestone
+		}
+		seen[cur.ID] = true
+		if cur.ParentID == nil {
+			break
+		}
+		cur = byID[*cur.ParentID]
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/unnecessary-special-projects/ghist/internal/models"
@@ -14,6 +15,12 @@ import (
 // CreateTaskInput holds the fields needed to create a new task.
 type CreateTaskInput struct {
 	Title, Description, Status, Milestone, Priority, Type, LegacyID string
+	Assignee                                                        string
+	ParentID                                                        *int64
+	Labels                                                          []string
+	// Actor is who creates the task, recorded on its task.created event;
+	// empty means the store's actor (see SetActor).
+	Actor string
 }
 
 // TaskUpdate holds optional fields to update on an existing task.
@@ -27,27 +34,133 @@ type TaskUpdate struct {
 	Priority    *string
 	Type        *string
 	LegacyID    *string
+	ParentID    *int64 // 0 detaches the task from its parent
+	// Labels replaces the task's labels; AddLabels and RemoveLabels are
+	// applied on top of it (or of the current labels if Labels is nil).
+	Labels       *[]string
+	AddLabels    []string
+	RemoveLabels []string
+	Archived     *bool
+	Assignee     *string // "" unassigns
+	// Actor is who makes the change, recorded on its audit events; empty
+	// means the store's actor (see SetActor).
+	Actor string
+	// IfRevision, if set, makes the update fail with a *RevisionError unless
+	// the task is still at this revision.
+	IfRevision *int64
 }
 
-func (s *Store) tasksDir() string {
+// TaskFilter selects tasks by field. Empty fields match everything. A task
+// matches Labels if it carries all of them, or any of them with AnyLabel.
+// Archived tasks are left out unless IncludeArchived is set.
+type TaskFilter struct {
+	Status          string
+	Milestone       string
+	Priority        string
+	Type            string
+	Assignee        string // case-insensitive; NoAssignee matches unassigned tasks
+	Labels          []string
+	AnyLabel        bool
+	IncludeArchived bool
+}
+
+// NoAssignee is the TaskFilter.Assignee value that selects unassigned tasks.
+const NoAssignee = "none"
+
+func (f TaskFilter) match(t *models.Task) bool {
+	if t.Archived && !f.IncludeArchived {
+		return false
+	}
+	if f.Status != "" && t.Status != f.Status {
+		return false
+	}
+	if f.Milestone != "" && t.Milestone != f.Milestone {
+		return false
+	}
+	if f.Priority != "" && t.Priority != f.Priority {
+		return false
+	}
+	if f.Type != "" && t.Type != f.Type {
+		return false
+	}
+	switch f.Assignee {
+	case "":
+	case NoAssignee:
+		if t.Assignee != "" {
+			return false
+		}
+	default:
+		if !strings.EqualFold(t.Assignee, f.Assignee) {
+			return false
+		}
+	}
+	if len(f.Labels) == 0 {
+		return true
+	}
+	if f.AnyLabel {
+		for _, l := range f.Labels {
+			if t.HasLabel(l) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, l := range f.Labels {
+		if !t.HasLabel(l) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *FileStore) tasksDir() string {
 	return filepath.Join(s.root, "tasks")
 }
 
-func (s *Store) taskPath(id int64) string {
-	return filepath.Join(s.tasksDir(), fmt.Sprintf("%d.json", id))
+func (s *FileStore) taskPath(t *models.Task) string {
+	return filepath.Join(s.tasksDir(), recordFileName(t.ID, t.UID))
 }
 
-func (s *Store) CreateTask(in CreateTaskInput) (*models.Task, error) {
+func (s *FileStore) CreateTask(in CreateTaskInput) (*models.Task, error) {
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
 	if in.Status == "" {
-		in.Status = "todo"
+		in.Status = wf.InitialStatus()
+	}
+	if err := validateTaskFields(wf, in.Status, in.Priority, in.Type); err != nil {
+		return nil, err
+	}
+	labels, err := models.NormalizeLabels(in.Labels)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if in.ParentID != nil {
+		if _, err := s.GetTask(*in.ParentID); isNotFound(err) {
+			return nil, invalid(fmt.Errorf("parent task %d not found", *in.ParentID))
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	prefix, err := s.RefPrefix()
+	if err != nil {
+		return nil, err
 	}
-	id, err := nextID(s.tasksDir())
+	id, err := s.nextTaskID()
 	if err != nil {
 		return nil, fmt.Errorf("getting next id: %w", err)
 	}
 	now := time.Now().UTC()
 	t := models.Task{
 		ID:          id,
+		UID:         newUID(),
 		Title:       in.Title,
 		Description: in.Description,
 		Status:      in.Status,
@@ -55,21 +168,32 @@ func (s *Store) CreateTask(in CreateTaskInput) (*models.Task, error) {
 		Priority:    in.Priority,
 		Type:        in.Type,
 		LegacyID:    in.LegacyID,
-		RefID:       fmt.Sprintf("GHST-%d", id),
+		ParentID:    in.ParentID,
+		Labels:      labels,
+		Assignee:    strings.TrimSpace(in.Assignee),
+		RefID:       models.FormatRef(prefix, id),
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
+	stampStatus(&t, wf, now)
 	if err := s.writeTask(&t); err != nil {
 		return nil, err
 	}
+	if err := s.recordCreated(&t, in.Actor); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
-func (s *Store) GetTask(id int64) (*models.Task, error) {
-	data, err := os.ReadFile(s.taskPath(id))
+func (s *FileStore) GetTask(id int64) (*models.Task, error) {
+	path, err := s.recordFile(s.tasksDir(), "task", id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.fs.readFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("task not found")
+			return nil, notFound(fmt.Errorf("task %d not found", id))
 		}
 		return nil, fmt.Errorf("reading task %d: %w", id, err)
 	}
@@ -80,38 +204,35 @@ func (s *Store) GetTask(id int64) (*models.Task, error) {
 	return &t, nil
 }
 
-func (s *Store) ListTasks(status, milestone, priority, taskType string) ([]models.Task, error) {
-	entries, err := os.ReadDir(s.tasksDir())
+// ListTasks returns the unarchived tasks matching the given fields.
+func (s *FileStore) ListTasks(status, milestone, priority, taskType string) ([]models.Task, error) {
+	return s.FilterTasks(TaskFilter{Status: status, Milestone: milestone, Priority: priority, Type: taskType})
+}
+
+// allTasks returns every task, archived or not, for checks and cascades that
+// must see the whole graph.
+func (s *FileStore) allTasks() ([]models.Task, error) {
+	return s.FilterTasks(TaskFilter{IncludeArchived: true})
+}
+
+// FilterTasks returns the tasks matching f, ordered by ID.
+func (s *FileStore) FilterTasks(f TaskFilter) ([]models.Task, error) {
+	labels, err := models.NormalizeLabels(f.Labels)
 	if err != nil {
-		return nil, fmt.Errorf("listing tasks: %w", err)
+		return nil, invalid(err)
+	}
+	f.Labels = labels
+
+	all, err := s.taskCache.load(s.fs, s.tasksDir(), "task", cloneTask)
+	if err != nil {
+		return nil, err
 	}
 
 	var tasks []models.Task
-	for _, e := range entries {
-		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(s.tasksDir(), e.Name()))
-		if err != nil {
-			return nil, fmt.Errorf("reading task file %s: %w", e.Name(), err)
-		}
-		var t models.Task
-		if err := json.Unmarshal(data, &t); err != nil {
-			return nil, fmt.Errorf("parsing task file %s: %w", e.Name(), err)
-		}
-		if status != "" && t.Status != status {
-			continue
-		}
-		if milestone != "" && t.Milestone != milestone {
-			continue
-		}
-		if priority != "" && t.Priority != priority {
-			continue
+	for _, t := range all {
+		if f.match(&t) {
+			tasks = append(tasks, t)
 		}
-		if taskType != "" && t.Type != taskType {
-			continue
-		}
-		tasks = append(tasks, t)
 	}
 
 	sort.Slice(tasks, func(i, j int) bool {
@@ -120,10 +241,40 @@ func (s *Store) ListTasks(status, milestone, priority, taskType string) ([]model
 	return tasks, nil
 }
 
-func (s *Store) UpdateTask(id int64, u TaskUpdate) (*models.Task, error) {
+func (s *FileStore) UpdateTask(id int64, u TaskUpdate) (*models.Task, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	t, err := s.GetTask(id)
 	if err != nil {
-		return nil, fmt.Errorf("task %d not found", id)
+		return nil, err
+	}
+	if err := checkRevision(t, u.IfRevision); err != nil {
+		return nil, err
+	}
+	before := *t
+
+	wf, err := s.Workflow()
+	if err != nil {
+		return nil, err
+	}
+	if u.Status != nil && *u.Status != t.Status {
+		if err := wf.ValidateTransition(t.Status, *u.Status); err != nil {
+			return nil, invalid(err)
+		}
+	}
+	if u.Priority != nil {
+		if err := wf.ValidatePriority(*u.Priority); err != nil {
+			return nil, invalid(err)
+		}
+	}
+	if u.Type != nil {
+		if err := wf.ValidateType(*u.Type); err != nil {
+			return nil, invalid(err)
+		}
 	}
 
 	if u.Title != nil {
@@ -153,44 +304,157 @@ func (s *Store) UpdateTask(id int64, u TaskUpdate) (*models.Task, error) {
 	if u.LegacyID != nil {
 		t.LegacyID = *u.LegacyID
 	}
+	if u.ParentID != nil {
+		if err := s.setParent(t, *u.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	if u.Labels != nil || len(u.AddLabels) > 0 || len(u.RemoveLabels) > 0 {
+		if err := applyLabels(t, u); err != nil {
+			return nil, err
+		}
+	}
+	if u.Archived != nil {
+		t.Archived = *u.Archived
+	}
+	if u.Assignee != nil {
+		t.Assignee = strings.TrimSpace(*u.Assignee)
+	}
 	t.UpdatedAt = time.Now().UTC()
+	if t.Status != before.Status {
+		stampStatus(t, wf, t.UpdatedAt)
+	}
 
 	if err := s.writeTask(t); err != nil {
 		return nil, err
 	}
+	if t.Plan != before.Plan {
+		if err := s.recordPlanRevision(t, before.Plan); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.recordChanges(&before, t, u.Actor); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
 
-func (s *Store) DeleteTask(id int64) error {
-	path := s.taskPath(id)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("task %d not found", id)
+// DeleteTask moves a task to the trash. Its events are unlinked, other tasks
+// stop depending on it and its subtasks move up to its parent; the trash entry
+// records all of this so RestoreTask can undo it. Plan revisions stay where
+// they are until the trash is emptied.
+func (s *FileStore) DeleteTask(id int64) error {
+	return s.deleteTask(id, nil)
+}
+
+// DeleteTaskAtRevision deletes a task like DeleteTask, but fails with a
+// *RevisionError unless the task is still at revision.
+func (s *FileStore) DeleteTaskAtRevision(id, revision int64) error {
+	return s.deleteTask(id, &revision)
+}
+
+func (s *FileStore) deleteTask(id int64, ifRevision *int64) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
 	}
-	if err := os.Remove(path); err != nil {
+	defer unlock()
+
+	t, err := s.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if err := checkRevision(t, ifRevision); err != nil {
+		return err
+	}
+	entry := models.TrashedTask{Task: *t, DeletedAt: time.Now().UTC()}
+	if err := s.writeTrash(&entry); err != nil {
+		return err
+	}
+	if err := s.removeFile(s.taskPath(t)); err != nil {
 		return fmt.Errorf("deleting task %d: %w", id, err)
 	}
-	// Cascade: clear task_id on any events that reference this task.
-	s.clearEventTaskID(id)
+	// Cascade: clear task_id on any events that reference this task, and drop
+	// it from other tasks' dependency lists.
+	entry.UnlinkedEvents = s.clearEventTaskID(id)
+	entry.Children = s.reparentChildren(id, t.ParentID)
+	if len(entry.UnlinkedEvents) > 0 || len(entry.Children) > 0 {
+		if err := s.writeTrash(&entry); err != nil {
+			return err
+		}
+	}
+	if err := s.clearTaskLinks(t); err != nil {
+		return fmt.Errorf("unlinking task %d: %w", id, err)
+	}
 	return nil
 }
 
-func (s *Store) TaskCountsByStatus() (map[string]int, error) {
+// RevisionError reports that a task changed since the revision the caller
+// based its change on, so applying it would overwrite someone else's edit.
+type RevisionError struct {
+	ID       int64
+	Want     int64 // the revision the caller expected
+	Revision int64 // the task's current revision
+}
+
+func (e *RevisionError) Error() string {
+	return fmt.Sprintf("task %d changed since revision %d (now at revision %d); re-read it and try again", e.ID, e.Want, e.Revision)
+}
+
+// checkRevision returns a *RevisionError if want is set and t is not at it.
+func checkRevision(t *models.Task, want *int64) error {
+	if want != nil && *want != t.Revision {
+		return &RevisionError{ID: t.ID, Want: *want, Revision: t.Revision}
+	}
+	return nil
+}
+
+// validateTaskFields checks a new task's status, priority and type against
+// the workflow.
+func validateTaskFields(wf models.Workflow, status, priority, typ string) error {
+	if err := wf.ValidateStatus(status); err != nil {
+		return invalid(err)
+	}
+	if err := wf.ValidatePriority(priority); err != nil {
+		return invalid(err)
+	}
+	return invalid(wf.ValidateType(typ))
+}
+
+// TaskCountsByStatus counts leaf tasks by status. Tasks with subtasks are
+// left out so that a parent and its children aren't counted twice.
+func (s *FileStore) TaskCountsByStatus() (map[string]int, error) {
 	tasks, err := s.ListTasks("", "", "", "")
 	if err != nil {
 		return nil, err
 	}
 	counts := make(map[string]int)
-	for _, t := range tasks {
+	for _, t := range leafTasks(tasks) {
 		counts[t.Status]++
 	}
 	return counts, nil
 }
 
-func (s *Store) MilestoneInfo() ([]models.MilestoneInfo, error) {
-	tasks, err := s.ListTasks("", "", "", "")
+// MilestoneInfo reports per-milestone progress over leaf tasks, for every
+// milestone that has a record or a task, ordered by name. A subtask without
+// its own milestone counts towards its nearest ancestor's.
+func (s *FileStore) MilestoneInfo() ([]models.MilestoneInfo, error) {
+	all, err := s.ListTasks("", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	wf, err := s.Workflow()
 	if err != nil {
 		return nil, err
 	}
+	byID := make(map[int64]*models.Task, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i]
+	}
+	tasks := leafTasks(all)
+	for i := range tasks {
+		tasks[i].Milestone = effectiveMilestone(byID, &tasks[i])
+	}
 
 	type milestoneData struct {
 		total int
@@ -207,28 +471,68 @@ func (s *Store) MilestoneInfo() ([]models.MilestoneInfo, error) {
 			order = append(order, t.Milestone)
 		}
 		mmap[t.Milestone].total++
-		if t.Status == "done" {
+		if wf.IsDone(t.Status) {
 			mmap[t.Milestone].done++
 		}
 	}
 
+	records, err := s.ListMilestones()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Milestone, len(records))
+	for i := range records {
+		if _, ok := byName[records[i].Name]; ok {
+			continue
+		}
+		byName[records[i].Name] = &records[i]
+		if _, ok := mmap[records[i].Name]; !ok {
+			mmap[records[i].Name] = &milestoneData{}
+			order = append(order, records[i].Name)
+		}
+	}
+
 	sort.Strings(order)
+	now := time.Now()
 	var milestones []models.MilestoneInfo
 	for _, name := range order {
 		m := mmap[name]
-		milestones = append(milestones, models.MilestoneInfo{
+		info := models.MilestoneInfo{
 			Name:  name,
 			Total: m.total,
 			Done:  m.done,
-		})
+			State: models.MilestoneOpen,
+		}
+		if r := byName[name]; r != nil {
+			info.State = r.State
+			info.StartDate = r.StartDate
+			info.TargetDate = r.TargetDate
+			info.Overdue = r.Overdue(now)
+		}
+		milestones = append(milestones, info)
 	}
 	return milestones, nil
 }
 
-func (s *Store) writeTask(t *models.Task) error {
+// stampStatus records the time t entered its current status: the first
+// start of work, and the finish (cleared again if the task is reopened).
+func stampStatus(t *models.Task, wf models.Workflow, at time.Time) {
+	if wf.IsStarted(t.Status) && t.StartedAt == nil {
+		t.StartedAt = &at
+	}
+	if wf.IsDone(t.Status) {
+		t.CompletedAt = &at
+	} else {
+		t.CompletedAt = nil
+	}
+}
+
+// writeTask saves t, bumping its revision.
+func (s *FileStore) writeTask(t *models.Task) error {
+	t.Revision++
 	data, err := json.MarshalIndent(t, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling task: %w", err)
 	}
-	return os.WriteFile(s.taskPath(t.ID), data, 0644)
+	return s.writeFile(s.taskPath(t), data)
 }
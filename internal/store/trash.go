@@ -0,0 +1,291 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/unnecessary-special-projects/ghist/internal/models"
+)
+
+// trashEntry is a trash file and its parsed contents.
+type trashEntry struct {
+	path string
+	models.TrashedTask
+}
+
+// TrashRestore describes a task brought back by RestoreTask.
+type TrashRestore struct {
+	Task *models.Task `json:"task"`
+	// PreviousID is the task's ID before it was deleted. It differs from
+	// Task.ID when a new task took the ID while it was in the trash.
+	PreviousID         int64 `json:"previous_id"`
+	EventsRelinked     int   `json:"events_relinked"`
+	ChildrenReattached int   `json:"children_reattached"`
+}
+
+func (s *FileStore) trashDir() string {
+	return filepath.Join(s.root, "trash")
+}
+
+// writeTrash saves a trash entry. Callers must hold the store lock.
+func (s *FileStore) writeTrash(e *models.TrashedTask) error {
+	if err := s.fs.mkdirAll(s.trashDir()); err != nil {
+		return fmt.Errorf("creating trash directory: %w", err)
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling trash entry: %w", err)
+	}
+	return s.writeFile(filepath.Join(s.trashDir(), recordFileName(e.Task.ID, e.Task.UID)), data)
+}
+
+// readTrash returns every trash entry, most recently deleted first.
+func (s *FileStore) readTrash() ([]trashEntry, error) {
+	entries, err := s.fs.readDir(s.trashDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading trash: %w", err)
+	}
+	var out []trashEntry
+	for _, e := range entries {
+		if _, _, ok := parseRecordFileName(e.Name()); !ok || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.trashDir(), e.Name())
+		data, err := s.fs.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading trash file %s: %w", e.Name(), err)
+		}
+		entry := trashEntry{path: path}
+		if err := json.Unmarshal(data, &entry.TrashedTask); err != nil {
+			return nil, fmt.Errorf("parsing trash file %s: %w", e.Name(), err)
+		}
+		out = append(out, entry)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DeletedAt.After(out[j].DeletedAt) })
+	return out, nil
+}
+
+// ListTrash returns the deleted tasks in the trash, most recently deleted
+// first.
+func (s *FileStore) ListTrash() ([]models.TrashedTask, error) {
+	entries, err := s.readTrash()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.TrashedTask, len(entries))
+	for i, e := range entries {
+		out[i] = e.TrashedTask
+	}
+	return out, nil
+}
+
+// RestoreTask brings the most recently deleted task with the given ID back
+// from the trash. Its events are linked to it again, tasks it blocked or was
+// blocked by get the relation back, and subtasks that were moved up to its
+// parent (and have not moved since) return under it. If another task has
+// taken its ID in the meantime, it gets a fresh one.
+func (s *FileStore) RestoreTask(id int64) (*TrashRestore, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := s.readTrash()
+	if err != nil {
+		return nil, err
+	}
+	i := -1
+	for j := range entries {
+		if entries[j].Task.ID == id {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil, notFound(fmt.Errorf("task %d is not in the trash", id))
+	}
+	entry := entries[i]
+	t := entry.Task
+	report := &TrashRestore{Task: &t, PreviousID: t.ID}
+
+	if taken, err := s.findRecordFiles(s.tasksDir(), t.ID); err != nil {
+		return nil, err
+	} else if len(taken) > 0 {
+		prefix, err := s.RefPrefix()
+		if err != nil {
+			return nil, err
+		}
+		newID, err := s.nextTaskID()
+		if err != nil {
+			return nil, err
+		}
+		if t.RefID == models.FormatRef(prefix, t.ID) || t.RefID == models.FormatRef(models.LegacyRefPrefix, t.ID) {
+			t.RefID = models.FormatRef(prefix, newID)
+		}
+		t.ID = newID
+	}
+
+	formerParent := t.ParentID
+	if t.ParentID != nil {
+		if _, err := s.GetTask(*t.ParentID); err != nil {
+			t.ParentID = nil
+		}
+	}
+	t.Blocks = s.relink(t.Blocks, func(o *models.Task) { o.BlockedBy = appendSorted(o.BlockedBy, t.ID) })
+	t.BlockedBy = s.relink(t.BlockedBy, func(o *models.Task) { o.Blocks = appendSorted(o.Blocks, t.ID) })
+	t.UpdatedAt = time.Now().UTC()
+	if err := s.writeTask(&t); err != nil {
+		return nil, err
+	}
+
+	events, err := s.readAllEvents()
+	if err != nil {
+		return nil, err
+	}
+	for j := range events {
+		e := &events[j]
+		if e.TaskID == nil && matchesRef(entry.UnlinkedEvents, e.ID, e.UID) {
+			e.TaskID, e.TaskUID = &t.ID, t.UID
+			if err := s.writeEvent(e); err != nil {
+				return nil, err
+			}
+			report.EventsRelinked++
+		}
+	}
+	// Archived events are not rewritten: they still carry the task's UID,
+	// which ListEventsByTask matches them by, so they come back with it.
+	months, err := s.archiveMonths()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range months {
+		archived, err := s.readArchive(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range archived {
+			if e.TaskUID != "" && e.TaskUID == t.UID {
+				report.EventsRelinked++
+			}
+		}
+	}
+
+	tasks, err := s.allTasks()
+	if err != nil {
+		return nil, err
+	}
+	for j := range tasks {
+		c := &tasks[j]
+		if c.ID == t.ID || !matchesRef(entry.Children, c.ID, c.UID) || !sameParent(c.ParentID, formerParent) {
+			continue
+		}
+		c.ParentID = &t.ID
+		if err := s.writeTask(c); err != nil {
+			return nil, err
+		}
+		report.ChildrenReattached++
+	}
+
+	if err := s.removeFile(entry.path); err != nil {
+		return nil, fmt.Errorf("removing trash entry: %w", err)
+	}
+	return report, nil
+}
+
+// relink applies add to each task in ids that still exists and returns those
+// IDs, dropping the rest. Callers must hold the store lock.
+func (s *FileStore) relink(ids []int64, add func(*models.Task)) []int64 {
+	var kept []int64
+	for _, id := range ids {
+		o, err := s.GetTask(id)
+		if err != nil {
+			continue
+		}
+		add(o)
+		if s.writeTask(o) == nil {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// EmptyTrash permanently removes every task in the trash, along with its plan
+// revisions, and returns how many it removed.
+func (s *FileStore) EmptyTrash() (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.readTrash()
+	if err != nil {
+		return 0, err
+	}
+	live, err := s.allTasks()
+	if err != nil {
+		return 0, err
+	}
+	// Leave plan revisions alone if a live task shares the directory, as a
+	// task brought back by undo does.
+	inUse := make(map[string]bool, len(live))
+	for i := range live {
+		inUse[s.planDir(&live[i])] = true
+	}
+	for _, e := range entries {
+		if !inUse[s.planDir(&e.Task)] {
+			if err := s.removePlanRevisions(&e.Task); err != nil {
+				return 0, err
+			}
+		}
+		if err := s.removeFile(e.path); err != nil {
+			return 0, fmt.Errorf("removing trash entry: %w", err)
+		}
+	}
+	return len(entries), nil
+}
+
+// removePlanRevisions deletes t's plan revision files one by one, so the
+// deletion is journaled. Callers must hold the store lock.
+func (s *FileStore) removePlanRevisions(t *models.Task) error {
+	dir := s.planDir(t)
+	entries, err := s.fs.readDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading plan revisions: %w", err)
+	}
+	for _, e := range entries {
+		if err := s.removeFile(filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("removing plan revision %s: %w", e.Name(), err)
+		}
+	}
+	s.fs.remove(dir)
+	return nil
+}
+
+// matchesRef reports whether the record with this ID and UID is one of refs.
+func matchesRef(refs []models.RecordRef, id int64, uid string) bool {
+	for _, r := range refs {
+		if r.UID != "" && r.UID == uid || r.UID == "" && r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameParent(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newUID returns a 26-character ULID: a 48-bit millisecond timestamp followed
+// by 80 random bits, Crockford base32 encoded. UIDs sort by creation time and
+// are unique across machines, so records created on different branches never
+// share a file name.
+func newUID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128 bits → 26 base32 characters (the first carries only 3 bits).
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// recordFileName returns the file name for a record. Records with a UID are
+// stored as "<id>-<uid>.json" so that two branches allocating the same display
+// ID never write the same file; legacy records keep the plain "<id>.json".
+func recordFileName(id int64, uid string) string {
+	if uid == "" {
+		return fmt.Sprintf("%d.json", id)
+	}
+	return fmt.Sprintf("%d-%s.json", id, uid)
+}
+
+// parseRecordFileName is the inverse of recordFileName.
+func parseRecordFileName(name string) (id int64, uid string, ok bool) {
+	base, found := strings.CutSuffix(name, ".json")
+	if !found {
+		return 0, "", false
+	}
+	idPart, uid, _ := strings.Cut(base, "-")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, uid, true
+}
+
+// findRecordFiles returns the names of all files in dir holding the record
+// with the given display ID. More than one match means a merge brought in two
+// records with the same ID; see MergeFix.
+func (s *FileStore) findRecordFiles(dir string, id int64) ([]string, error) {
+	entries, err := s.fs.readDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if rid, _, ok := parseRecordFileName(e.Name()); ok && rid == id {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,157 @@
+// Package textdiff produces line-based unified diffs.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff of a and b with the given number of context
+// lines, labelled with aName and bName. It returns "" when a and b are equal.
+func Unified(aName, bName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	// Walk the edit script, emitting a hunk for each run of changes padded
+	// with up to `context` unchanged lines on either side.
+	aLine, bLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			aLine++
+			bLine++
+			i++
+			continue
+		}
+
+		start := i
+		for start > 0 && i-start < context && ops[start-1].kind == opEqual {
+			start--
+		}
+		hunkA, hunkB := aLine-(i-start), bLine-(i-start)
+
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, run)
+				break
+			}
+			end = run
+		}
+
+		var body strings.Builder
+		countA, countB := 0, 0
+		for _, o := range ops[start:end] {
+			switch o.kind {
+			case opEqual:
+				body.WriteString(" " + o.line + "\n")
+				countA++
+				countB++
+			case opDelete:
+				body.WriteString("-" + o.line + "\n")
+				countA++
+			case opInsert:
+				body.WriteString("+" + o.line + "\n")
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(hunkA, countA), hunkRange(hunkB, countB))
+		sb.WriteString(body.String())
+
+		for _, o := range ops[i:end] {
+			if o.kind != opInsert {
+				aLine++
+			}
+			if o.kind != opDelete {
+				bLine++
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a minimal edit script from a to b using the longest
+// common subsequence of lines.
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,44 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{
+			"change in middle",
+			"one\ntwo\nthree\nfour\nfive\n",
+			"one\ntwo\nTHREE\nfour\nfive\n",
+			"--- a\n+++ b\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+		},
+		{
+			"append",
+			"one\n",
+			"one\ntwo\n",
+			"--- a\n+++ b\n@@ -1 +1,2 @@\n one\n+two\n",
+		},
+		{
+			"from empty",
+			"",
+			"new\n",
+			"--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"X\n2\n3\n4\n5\n6\n7\n8\nY\n",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+X\n 2\n@@ -8,2 +8,2 @@\n 8\n-9\n+Y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got := Unified("a", "b", tt.a, tt.b, 1)
+		if got != tt.want {
+			t.Errorf("%s: Unified() =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -10,7 +10,8 @@ import { Toolbar } from './components/toolbar';
 import { ActivityFeed } from './components/activity-feed';
 import { useTaskFilters } from './hooks/useTaskFilters';
 import { useSSE } from './hooks/useSSE';
-import type { Task, TaskStatus, TaskPriority, TaskType } from './types';
+import type { Task, TaskStatus, TaskPriority, TaskType, Workflow } from './types';
+import { DEFAULT_WORKFLOW } from './types';
 import * as api from './api/client';
 
 export function App() {
@@ -20,6 +21,7 @@ export function App() {
   const [drawerMode, setDrawerMode] = useState<'view' | 'create' | null>(null);
   const [repoURL, setRepoURL] = useState<string>('');
   const [milestoneOrder, setMilestoneOrder] = useState<string[]>([]);
+  const [workflow, setWorkflow] = useState<Workflow>(DEFAULT_WORKFLOW);
 
   const {
     viewMode, setViewMode,
@@ -29,7 +31,7 @@ export function App() {
     sortBy, setSortBy,
     milestoneFilter, toggleMilestone,
     filterTasks,
-  } = useTaskFilters();
+  } = useTaskFilters(workflow);
 
   const loadTasks = useCallback(async () => {
     const data = await api.listTasks();
@@ -41,9 +43,15 @@ export function App() {
     setMilestoneOrder(order);
   }, []);
 
+  // The workflow is reloaded with the tasks, so edits to settings.json show up
+  // without a page reload.
+  const loadWorkflow = useCallback(async () => {
+    setWorkflow(await api.getWorkflow());
+  }, []);
+
   const handleRefresh = useCallback(async () => {
-    await Promise.all([loadTasks(), loadMilestoneOrder()]);
-  }, [loadTasks, loadMilestoneOrder]);
+    await Promise.all([loadTasks(), loadMilestoneOrder(), loadWorkflow()]);
+  }, [loadTasks, loadMilestoneOrder, loadWorkflow]);
 
   useEffect(() => {
     handleRefresh();
@@ -104,19 +112,25 @@ export function App() {
   };
 
   const handleFieldSave = async (id: number, data: Record<string, string>) => {
+    // The revision the edit was made against, so a concurrent change by an
+    // agent is rejected rather than overwritten.
+    const revision = (drawerTask?.id === id ? drawerTask : tasks.find((t) => t.id === id))?.revision;
     // Optimistic update
     setTasks((prev) => prev.map((t) => (t.id === id ? { ...t, ...data } : t)));
     if (drawerTask?.id === id) {
       setDrawerTask((prev) => prev ? { ...prev, ...data } : null);
     }
     try {
-      const updated = await api.updateTask(id, data);
+      const updated = await api.updateTask(id, data, revision);
       setTasks((prev) => prev.map((t) => (t.id === id ? updated : t)));
       if (drawerTask?.id === id) {
         setDrawerTask(updated);
       }
     } catch {
       await loadTasks(); // revert
+      if (drawerTask?.id === id) {
+        await api.getTask(id).then(setDrawerTask).catch(() => {});
+      }
     }
   };
 
@@ -155,6 +169,7 @@ export function App() {
           <Toolbar
             viewMode={viewMode}
             onViewModeChange={setViewMode}
+            workflow={workflow}
             priorityFilter={priorityFilter}
             onPriorityFilterChange={setPriorityFilter}
             typeFilter={typeFilter}
@@ -172,12 +187,14 @@ export function App() {
           {viewMode === 'list' ? (
             <List
               tasks={filteredTasks}
+              workflow={workflow}
               onStatusChange={handleStatusChange}
               onCardClick={handleCardClick}
             />
           ) : viewMode === 'board' ? (
             <Board
               tasks={filteredTasks}
+              workflow={workflow}
               onStatusChange={handleStatusChange}
               onCardClick={handleCardClick}
             />
@@ -195,6 +212,7 @@ export function App() {
       <TaskDrawer
         task={drawerTask}
         mode={drawerMode}
+        workflow={workflow}
         onClose={handleCloseDrawer}
         onUpdateTask={handleFieldSave}
         onCreateTask={handleCreateTask}
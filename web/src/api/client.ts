@@ -1,11 +1,11 @@
-import type { Task, Event, StatusSummary } from '../types';
+import type { Task, Event, StatusSummary, Workflow } from '../types';
 
 const BASE = '/api';
 
 async function request<T>(path: string, options?: RequestInit): Promise<T> {
   const res = await fetch(`${BASE}${path}`, {
-    headers: { 'Content-Type': 'application/json' },
     ...options,
+    headers: { 'Content-Type': 'application/json', ...(options?.headers as Record<string, string>) },
   });
   if (!res.ok) {
     const body = await res.json().catch(() => ({ error: res.statusText }));
@@ -48,12 +48,16 @@ export async function createTask(data: {
   });
 }
 
+// updateTask applies data to a task. With a revision, the server refuses the
+// change (412) if the task has been changed since that revision.
 export async function updateTask(
   id: number,
-  data: Partial<Pick<Task, 'title' | 'description' | 'plan' | 'status' | 'milestone' | 'commit_hash' | 'priority' | 'type' | 'legacy_id'>>
+  data: Partial<Pick<Task, 'title' | 'description' | 'plan' | 'status' | 'milestone' | 'commit_hash' | 'priority' | 'type' | 'legacy_id'>>,
+  revision?: number,
 ): Promise<Task> {
   return request<Task>(`/tasks/${id}`, {
     method: 'PATCH',
+    headers: revision === undefined ? {} : { 'If-Match': `"${revision}"` },
     body: JSON.stringify(data),
   });
 }
@@ -90,6 +94,10 @@ export async function getConfig(): Promise<{ github_repo_url: string }> {
   return request<{ github_repo_url: string }>('/config');
 }
 
+export async function getWorkflow(): Promise<Workflow> {
+  return request<Workflow>('/workflow');
+}
+
 export async function getMilestoneOrder(): Promise<string[]> {
   return request<string[]>('/settings/milestone-order');
 }
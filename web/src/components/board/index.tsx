@@ -3,12 +3,13 @@ import { DndContext, DragEndEvent, DragOverlay, DragStartEvent, PointerSensor, u
 import { useDroppable } from "@dnd-kit/core";
 import { useState } from "react";
 import css from "./index.module.css";
-import type { Task, TaskStatus } from "../../types";
-import { BOARD_STATUSES, STATUS_LABELS, STATUS_COLORS } from "../../types";
+import type { Task, TaskStatus, Workflow } from "../../types";
+import { boardStatuses, nextStatuses, statusLabel, statusColor } from "../../types";
 import { TaskCard } from "../task-card";
 
 export interface IBoard {
   tasks: Task[];
+  workflow: Workflow;
   onStatusChange: (id: number, status: TaskStatus) => void;
   onCardClick?: (task: Task) => void;
 }
@@ -34,7 +35,8 @@ export const Board: React.FC<IBoard> = (props) => {
     const newStatus = over.id as TaskStatus;
 
     const task = props.tasks.find((t) => t.id === taskId);
-    if (task && task.status !== newStatus) {
+    // Drops onto a status the workflow doesn't allow from here are ignored.
+    if (task && task.status !== newStatus && nextStatuses(props.workflow, task.status).includes(newStatus)) {
       props.onStatusChange(taskId, newStatus);
     }
   };
@@ -42,7 +44,7 @@ export const Board: React.FC<IBoard> = (props) => {
   return (
     <DndContext sensors={sensors} onDragStart={handleDragStart} onDragEnd={handleDragEnd}>
       <div className={css.columns}>
-        {BOARD_STATUSES.map((status) => (
+        {boardStatuses(props.workflow, props.tasks).map((status) => (
           <Column
             key={status}
             status={status}
@@ -65,11 +67,11 @@ function Column({ status, tasks, onCardClick }: { status: TaskStatus; tasks: Tas
     <div
       ref={setNodeRef}
       className={cx(css.column, { [css.columnOver]: isOver, [css.columnEmpty]: tasks.length === 0 })}
-      style={isOver ? { borderColor: STATUS_COLORS[status] } : undefined}
+      style={isOver ? { borderColor: statusColor(status) } : undefined}
     >
       <div className={css.header}>
-        <span className={css.dot} style={{ backgroundColor: STATUS_COLORS[status] }} />
-        <span className={css.label}>{STATUS_LABELS[status]}</span>
+        <span className={css.dot} style={{ backgroundColor: statusColor(status) }} />
+        <span className={css.label}>{statusLabel(status)}</span>
         <span className={css.count}>{tasks.length}</span>
       </div>
       <div className={css.cards}>
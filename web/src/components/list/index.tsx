@@ -3,12 +3,13 @@ import { DndContext, DragEndEvent, DragOverlay, DragStartEvent, PointerSensor, u
 import { useDroppable } from "@dnd-kit/core";
 import { useState } from "react";
 import css from "./index.module.css";
-import type { Task, TaskStatus } from "../../types";
-import { LIST_STATUSES, STATUS_LABELS, STATUS_COLORS } from "../../types";
+import type { Task, TaskStatus, Workflow } from "../../types";
+import { listStatuses, nextStatuses, statusLabel, statusColor } from "../../types";
 import { TaskCard } from "../task-card";
 
 export interface IList {
   tasks: Task[];
+  workflow: Workflow;
   onStatusChange: (id: number, status: TaskStatus) => void;
   onCardClick?: (task: Task) => void;
 }
@@ -34,7 +35,8 @@ export const List: React.FC<IList> = (props) => {
     const newStatus = over.id as TaskStatus;
 
     const task = props.tasks.find((t) => t.id === taskId);
-    if (task && task.status !== newStatus) {
+    // Drops onto a status the workflow doesn't allow from here are ignored.
+    if (task && task.status !== newStatus && nextStatuses(props.workflow, task.status).includes(newStatus)) {
       props.onStatusChange(taskId, newStatus);
     }
   };
@@ -48,7 +50,7 @@ export const List: React.FC<IList> = (props) => {
       <div
         className={ css.list }>
         {
-          LIST_STATUSES.map((status) => (
+          listStatuses(props.workflow, props.tasks).map((status) => (
             <ListGroup
               key={status}
               status={status}
@@ -85,16 +87,16 @@ function ListGroup({ status, tasks, onCardClick }: { status: TaskStatus; tasks:
         css.group,
         { [css.groupOver]: isOver, [css.groupEmpty]: tasks.length === 0 })
       }
-      style={ isOver ? { borderColor: STATUS_COLORS[status] } : undefined }>
+      style={ isOver ? { borderColor: statusColor(status) } : undefined }>
 
       <div
         className={ css.groupHeader }>
         <span
           className={ css.dot }
-          style={{ backgroundColor: STATUS_COLORS[status] }} />
+          style={{ backgroundColor: statusColor(status) }} />
         <span
           className={ css.groupLabel }>
-          { STATUS_LABELS[status] }
+          { statusLabel(status) }
         </span>
         <span
           className={ css.count }>
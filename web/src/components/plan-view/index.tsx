@@ -21,12 +21,12 @@ import { DotsSixVertical } from "@phosphor-icons/react";
 import css from "./index.module.css";
 import type { Task } from "../../types";
 import {
-  STATUS_COLORS,
-  STATUS_LABELS,
-  PRIORITY_COLORS,
-  PRIORITY_LABELS,
-  TYPE_COLORS,
-  TYPE_LABELS,
+  statusColor,
+  statusLabel,
+  priorityColor,
+  priorityLabel,
+  typeColor,
+  typeLabel,
 } from "../../types";
 import { orderMilestones } from "../../utils/milestoneOrder";
 
@@ -253,32 +253,32 @@ function PlanCard({
         <span
           className={css.pill}
           style={{
-            color: STATUS_COLORS[task.status],
-            backgroundColor: `${STATUS_COLORS[task.status]}1a`,
+            color: statusColor(task.status),
+            backgroundColor: `${statusColor(task.status)}1a`,
           }}
         >
-          {STATUS_LABELS[task.status]}
+          {statusLabel(task.status)}
         </span>
         {task.priority && (
           <span
             className={css.pill}
             style={{
-              color: PRIORITY_COLORS[task.priority],
-              backgroundColor: `${PRIORITY_COLORS[task.priority]}1a`,
+              color: priorityColor(task.priority),
+              backgroundColor: `${priorityColor(task.priority)}1a`,
             }}
           >
-            {PRIORITY_LABELS[task.priority]}
+            {priorityLabel(task.priority)}
           </span>
         )}
         {task.type && (
           <span
             className={css.pill}
             style={{
-              color: TYPE_COLORS[task.type],
-              backgroundColor: `${TYPE_COLORS[task.type]}1a`,
+              color: typeColor(task.type),
+              backgroundColor: `${typeColor(task.type)}1a`,
             }}
           >
-            {TYPE_LABELS[task.type]}
+            {typeLabel(task.type)}
           </span>
         )}
       </div>
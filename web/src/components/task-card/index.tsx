@@ -2,7 +2,7 @@ import cx from "classnames";
 import { useDraggable } from "@dnd-kit/core";
 import css from "./index.module.css";
 import type { Task } from "../../types";
-import { PRIORITY_COLORS, PRIORITY_LABELS, TYPE_COLORS, TYPE_LABELS } from "../../types";
+import { priorityColor, priorityLabel, typeColor, typeLabel } from "../../types";
 
 export interface ITaskCard {
   task: Task;
@@ -47,17 +47,17 @@ export const TaskCard: React.FC<ITaskCard> = (props) => {
             {props.task.priority && (
               <span
                 className={css.priorityPill}
-                style={{ color: PRIORITY_COLORS[props.task.priority], backgroundColor: `${PRIORITY_COLORS[props.task.priority]}1a` }}
+                style={{ color: priorityColor(props.task.priority), backgroundColor: `${priorityColor(props.task.priority)}1a` }}
               >
-                <span className={css.pillLabel}>Priority:</span> {PRIORITY_LABELS[props.task.priority]}
+                <span className={css.pillLabel}>Priority:</span> {priorityLabel(props.task.priority)}
               </span>
             )}
             {props.task.type && (
               <span
                 className={css.typeBadge}
-                style={{ color: TYPE_COLORS[props.task.type], backgroundColor: `${TYPE_COLORS[props.task.type]}1a` }}
+                style={{ color: typeColor(props.task.type), backgroundColor: `${typeColor(props.task.type)}1a` }}
               >
-                <span className={css.pillLabel}>Type:</span> {TYPE_LABELS[props.task.type]}
+                <span className={css.pillLabel}>Type:</span> {typeLabel(props.task.type)}
               </span>
             )}
             {props.task.milestone && (
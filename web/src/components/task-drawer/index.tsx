@@ -1,11 +1,10 @@
 import cx from "classnames";
 import { useCallback, useEffect, useState } from "react";
 import css from "./index.module.css";
-import type { Task, TaskStatus, TaskPriority, TaskType, EventType, Event } from "../../types";
+import type { Task, TaskStatus, TaskPriority, TaskType, EventType, Event, Workflow } from "../../types";
 import {
-  STATUSES, STATUS_LABELS, STATUS_COLORS,
-  PRIORITIES, PRIORITY_LABELS, PRIORITY_COLORS,
-  TASK_TYPES, TYPE_LABELS, TYPE_COLORS,
+  workflowStatuses, nextStatuses, statusLabel, statusColor,
+  priorityLabel, priorityColor, typeLabel, typeColor,
   EVENT_TYPES, EVENT_TYPE_LABELS, EVENT_TYPE_COLORS,
 } from "../../types";
 import { InlineField } from "../inline-field";
@@ -15,6 +14,7 @@ import * as api from "../../api/client";
 export interface ITaskDrawer {
   task: Task | null;
   mode: "view" | "create" | null;
+  workflow: Workflow;
   onClose: () => void;
   onUpdateTask: (id: number, data: Record<string, string>) => void;
   onCreateTask: (data: { title: string; description: string; status: TaskStatus; milestone: string; priority: TaskPriority; type: TaskType }) => void;
@@ -30,7 +30,8 @@ export const TaskDrawer: React.FC<ITaskDrawer> = (props) => {
 
   const [createTitle, setCreateTitle] = useState("");
   const [createDescription, setCreateDescription] = useState("");
-  const [createStatus, setCreateStatus] = useState<TaskStatus>("todo");
+  const initialStatus = props.workflow.statuses[0]?.name ?? "";
+  const [createStatus, setCreateStatus] = useState<TaskStatus>(initialStatus);
   const [createMilestone, setCreateMilestone] = useState("");
   const [createPriority, setCreatePriority] = useState<TaskPriority>("");
   const [createType, setCreateType] = useState<TaskType>("");
@@ -43,11 +44,13 @@ export const TaskDrawer: React.FC<ITaskDrawer> = (props) => {
     if (props.mode === "create") {
       setCreateTitle("");
       setCreateDescription("");
-      setCreateStatus("todo");
+      setCreateStatus(initialStatus);
       setCreateMilestone("");
       setCreatePriority("");
       setCreateType("");
     }
+    // Not keyed on initialStatus: a workflow reload must not wipe a
+    // half-filled form.
   }, [props.mode]);
 
   useEffect(() => {
@@ -79,6 +82,7 @@ export const TaskDrawer: React.FC<ITaskDrawer> = (props) => {
       <div className={cx(css.drawer, open ? css.drawerOpen : css.drawerClosed)}>
         {props.mode === "create" ? (
           <CreateContent
+            workflow={props.workflow}
             title={createTitle} onTitleChange={setCreateTitle}
             description={createDescription} onDescriptionChange={setCreateDescription}
             status={createStatus} onStatusChange={setCreateStatus}
@@ -128,7 +132,7 @@ export const TaskDrawer: React.FC<ITaskDrawer> = (props) => {
 
             <div className={css.content}>
               {tab === "details" ? (
-                <DetailsTab task={props.task} onUpdate={props.onUpdateTask} onDelete={props.onDeleteTask} repoURL={props.repoURL} />
+                <DetailsTab task={props.task} workflow={props.workflow} onUpdate={props.onUpdateTask} onDelete={props.onDeleteTask} repoURL={props.repoURL} />
               ) : tab === "plan" ? (
                 <PlanTab task={props.task} onUpdate={props.onUpdateTask} />
               ) : (
@@ -145,11 +149,12 @@ export const TaskDrawer: React.FC<ITaskDrawer> = (props) => {
 // ---------- Create Content ----------
 
 function CreateContent({
-  title, onTitleChange, description, onDescriptionChange,
+  workflow, title, onTitleChange, description, onDescriptionChange,
   status, onStatusChange, milestone, onMilestoneChange,
   priority, onPriorityChange, type, onTypeChange,
   onClose, onCreate,
 }: {
+  workflow: Workflow;
   title: string; onTitleChange: (v: string) => void;
   description: string; onDescriptionChange: (v: string) => void;
   status: TaskStatus; onStatusChange: (v: TaskStatus) => void;
@@ -174,19 +179,19 @@ function CreateContent({
           <div className={css.formField}>
             <span className={css.fieldLabel}>Status</span>
             <select className={css.input} value={status} onChange={(e) => onStatusChange(e.target.value as TaskStatus)}>
-              {STATUSES.map((s) => <option key={s} value={s}>{STATUS_LABELS[s]}</option>)}
+              {workflowStatuses(workflow).map((s) => <option key={s} value={s}>{statusLabel(s)}</option>)}
             </select>
           </div>
           <div className={css.formField}>
             <span className={css.fieldLabel}>Priority</span>
             <select className={css.input} value={priority} onChange={(e) => onPriorityChange(e.target.value as TaskPriority)}>
-              {PRIORITIES.map((p) => <option key={p} value={p}>{PRIORITY_LABELS[p]}</option>)}
+              {["", ...workflow.priorities].map((p) => <option key={p} value={p}>{priorityLabel(p)}</option>)}
             </select>
           </div>
           <div className={css.formField}>
             <span className={css.fieldLabel}>Type</span>
             <select className={css.input} value={type} onChange={(e) => onTypeChange(e.target.value as TaskType)}>
-              {TASK_TYPES.map((t) => <option key={t} value={t}>{TYPE_LABELS[t]}</option>)}
+              {["", ...workflow.types].map((t) => <option key={t} value={t}>{typeLabel(t)}</option>)}
             </select>
           </div>
           <div className={css.formField}>
@@ -206,7 +211,7 @@ function CreateContent({
 
 // ---------- Details Tab ----------
 
-function DetailsTab({ task, onUpdate, onDelete, repoURL }: { task: Task; onUpdate: (id: number, data: Record<string, string>) => void; onDelete: (id: number) => void; repoURL?: string }) {
+function DetailsTab({ task, workflow, onUpdate, onDelete, repoURL }: { task: Task; workflow: Workflow; onUpdate: (id: number, data: Record<string, string>) => void; onDelete: (id: number) => void; repoURL?: string }) {
   const save = (field: string) => (value: string) => onUpdate(task.id, { [field]: value });
 
   return (
@@ -214,37 +219,37 @@ function DetailsTab({ task, onUpdate, onDelete, repoURL }: { task: Task; onUpdat
       <InlineField label="Ref ID" value={task.ref_id} onSave={() => {}} readOnly />
       <InlineField
         label="Status" value={task.status} onSave={save("status")} type="select"
-        options={STATUSES.map((s) => ({ value: s, label: STATUS_LABELS[s], color: STATUS_COLORS[s] }))}
+        options={nextStatuses(workflow, task.status).map((s) => ({ value: s, label: statusLabel(s), color: statusColor(s) }))}
         renderValue={(v) => (
           <span className={css.statusBadge}>
-            <span className={css.statusDot} style={{ backgroundColor: STATUS_COLORS[v as TaskStatus] }} />
-            {STATUS_LABELS[v as TaskStatus]}
+            <span className={css.statusDot} style={{ backgroundColor: statusColor(v) }} />
+            {statusLabel(v)}
           </span>
         )}
       />
       <InlineField
         label="Priority" value={task.priority} onSave={save("priority")} type="select"
-        options={PRIORITIES.map((p) => ({ value: p, label: PRIORITY_LABELS[p], color: PRIORITY_COLORS[p] }))}
+        options={["", ...workflow.priorities].map((p) => ({ value: p, label: priorityLabel(p), color: priorityColor(p) }))}
         renderValue={(v) => {
           const p = v as TaskPriority;
           if (!p) return <span className={css.noneText}>None</span>;
           return (
             <span className={css.statusBadge}>
-              <span className={css.statusDot} style={{ backgroundColor: PRIORITY_COLORS[p] }} />
-              {PRIORITY_LABELS[p]}
+              <span className={css.statusDot} style={{ backgroundColor: priorityColor(p) }} />
+              {priorityLabel(p)}
             </span>
           );
         }}
       />
       <InlineField
         label="Type" value={task.type} onSave={save("type")} type="select"
-        options={TASK_TYPES.map((t) => ({ value: t, label: TYPE_LABELS[t], color: TYPE_COLORS[t] }))}
+        options={["", ...workflow.types].map((t) => ({ value: t, label: typeLabel(t), color: typeColor(t) }))}
         renderValue={(v) => {
           const t = v as TaskType;
           if (!t) return <span className={css.noneText}>None</span>;
           return (
-            <span className={css.typeBadge} style={{ color: TYPE_COLORS[t], backgroundColor: `${TYPE_COLORS[t]}1a` }}>
-              {TYPE_LABELS[t]}
+            <span className={css.typeBadge} style={{ color: typeColor(t), backgroundColor: `${typeColor(t)}1a` }}>
+              {typeLabel(t)}
             </span>
           );
         }}
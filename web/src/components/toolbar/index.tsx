@@ -15,8 +15,8 @@ import {
 } from "@dnd-kit/sortable";
 import { CSS } from "@dnd-kit/utilities";
 import css from "./index.module.css";
-import type { Task, TaskPriority, TaskType } from "../../types";
-import { PRIORITIES, PRIORITY_LABELS, TASK_TYPES, TYPE_LABELS } from "../../types";
+import type { Task, TaskPriority, TaskType, Workflow } from "../../types";
+import { priorityLabel, typeLabel } from "../../types";
 import type { ViewMode, SortOption } from "../../hooks/useTaskFilters";
 import { orderMilestones } from "../../utils/milestoneOrder";
 
@@ -25,6 +25,7 @@ const NONE_SENTINEL = "__none__";
 export interface IToolbar {
   viewMode: ViewMode;
   onViewModeChange: (mode: ViewMode) => void;
+  workflow: Workflow;
   priorityFilter: TaskPriority | "all";
   onPriorityFilterChange: (priority: TaskPriority | "all") => void;
   typeFilter: TaskType | "all";
@@ -125,8 +126,8 @@ export const Toolbar: React.FC<IToolbar> = (props) => {
             onChange={(e) => props.onPriorityFilterChange(e.target.value as TaskPriority | "all")}
           >
             <option value="all">All Priorities</option>
-            {PRIORITIES.filter((p) => p !== "").map((p) => (
-              <option key={p} value={p}>{PRIORITY_LABELS[p]}</option>
+            {props.workflow.priorities.map((p) => (
+              <option key={p} value={p}>{priorityLabel(p)}</option>
             ))}
           </select>
 
@@ -136,8 +137,8 @@ export const Toolbar: React.FC<IToolbar> = (props) => {
             onChange={(e) => props.onTypeFilterChange(e.target.value as TaskType | "all")}
           >
             <option value="all">All Types</option>
-            {TASK_TYPES.filter((t) => t !== "").map((t) => (
-              <option key={t} value={t}>{TYPE_LABELS[t]}</option>
+            {props.workflow.types.map((t) => (
+              <option key={t} value={t}>{typeLabel(t)}</option>
             ))}
           </select>
 
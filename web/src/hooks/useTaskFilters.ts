@@ -1,18 +1,11 @@
 import { useCallback, useState } from 'react';
-import type { Task, TaskPriority, TaskType } from '../types';
+import type { Task, TaskPriority, TaskType, Workflow } from '../types';
+import { priorityRank } from '../types';
 
 export type ViewMode = 'list' | 'board' | 'plan';
 export type SortOption = 'newest' | 'updated' | 'priority' | 'title';
 
-const PRIORITY_ORDER: Record<TaskPriority, number> = {
-  urgent: 0,
-  high: 1,
-  medium: 2,
-  low: 3,
-  '': 4,
-};
-
-export function useTaskFilters() {
+export function useTaskFilters(workflow: Workflow) {
   const [viewMode, setViewMode] = useState<ViewMode>('list');
   const [priorityFilter, setPriorityFilter] = useState<TaskPriority | 'all'>('all');
   const [typeFilter, setTypeFilter] = useState<TaskType | 'all'>('all');
@@ -65,7 +58,7 @@ export function useTaskFilters() {
           case 'updated':
             return b.updated_at.localeCompare(a.updated_at);
           case 'priority':
-            return (PRIORITY_ORDER[a.priority] ?? 4) - (PRIORITY_ORDER[b.priority] ?? 4);
+            return priorityRank(workflow, a.priority) - priorityRank(workflow, b.priority);
           case 'title':
             return a.title.localeCompare(b.title);
           default:
@@ -75,7 +68,7 @@ export function useTaskFilters() {
 
       return result;
     },
-    [priorityFilter, typeFilter, searchQuery, sortBy, milestoneFilter]
+    [workflow, priorityFilter, typeFilter, searchQuery, sortBy, milestoneFilter]
   );
 
   return {
@@ -1,6 +1,8 @@
-export type TaskStatus = 'todo' | 'in_planning' | 'in_progress' | 'done' | 'blocked';
-export type TaskPriority = '' | 'low' | 'medium' | 'high' | 'urgent';
-export type TaskType = '' | 'bug' | 'feature' | 'improvement' | 'chore';
+// Statuses, priorities and types are configurable per project (see
+// Workflow), so they are plain strings.
+export type TaskStatus = string;
+export type TaskPriority = string;
+export type TaskType = string;
 
 export interface Task {
   id: number;
@@ -14,8 +16,12 @@ export interface Task {
   type: TaskType;
   ref_id: string;
   legacy_id: string;
+  assignee?: string;
+  revision: number;
   created_at: string;
   updated_at: string;
+  started_at?: string;
+  completed_at?: string;
 }
 
 export interface Event {
@@ -24,6 +30,7 @@ export interface Event {
   message: string;
   metadata: string;
   task_id: number | null;
+  actor?: string;
   created_at: string;
 }
 
@@ -38,13 +45,97 @@ export interface MilestoneInfo {
   name: string;
   total: number;
   done: number;
+  state: 'open' | 'closed';
+  start_date?: string;
+  target_date?: string;
+  overdue?: boolean;
 }
 
-export const STATUSES: TaskStatus[] = ['todo', 'in_planning', 'in_progress', 'done', 'blocked'];
-export const LIST_STATUSES: TaskStatus[] = [ 'in_progress', 'in_planning', 'todo', 'done', 'blocked'];
-export const BOARD_STATUSES: TaskStatus[] = ['todo', 'in_planning', 'in_progress', 'done', 'blocked'];
+export interface WorkflowStatus {
+  name: string;
+  done?: boolean;
+  started?: boolean;
+}
+
+// Workflow mirrors the server's workflow (GET /api/workflow). Statuses are in
+// order, the first being where new tasks start; priorities run lowest to
+// highest. A status with no transitions entry may move to any status.
+export interface Workflow {
+  statuses: WorkflowStatus[];
+  transitions?: Record<string, string[]>;
+  priorities: string[];
+  types: string[];
+}
+
+// DEFAULT_WORKFLOW is used until the project's workflow has loaded.
+export const DEFAULT_WORKFLOW: Workflow = {
+  statuses: [
+    { name: 'todo' },
+    { name: 'in_planning', started: true },
+    { name: 'in_progress', started: true },
+    { name: 'done', done: true },
+    { name: 'blocked' },
+  ],
+  priorities: ['low', 'medium', 'high', 'urgent'],
+  types: ['bug', 'feature', 'improvement', 'chore'],
+};
+
+export function workflowStatuses(wf: Workflow): TaskStatus[] {
+  return wf.statuses.map((s) => s.name);
+}
+
+// withStray appends statuses that tasks are in but the workflow no longer
+// defines, so those tasks still show up somewhere.
+function withStray(statuses: TaskStatus[], tasks: Task[]): TaskStatus[] {
+  const out = [...statuses];
+  for (const t of tasks) {
+    if (!out.includes(t.status)) out.push(t.status);
+  }
+  return out;
+}
+
+// boardStatuses returns the board's columns: the workflow's statuses in order.
+export function boardStatuses(wf: Workflow, tasks: Task[]): TaskStatus[] {
+  return withStray(workflowStatuses(wf), tasks);
+}
+
+// listStatuses returns the list's groups: the statuses leading up to the
+// first done status, furthest along first, then the rest in workflow order.
+export function listStatuses(wf: Workflow, tasks: Task[]): TaskStatus[] {
+  const names = workflowStatuses(wf);
+  const done = wf.statuses.findIndex((s) => s.done);
+  const split = done < 0 ? names.length : done;
+  return withStray([...names.slice(0, split).reverse(), ...names.slice(split)], tasks);
+}
+
+// nextStatuses returns the statuses a task in current may move to, current
+// included.
+export function nextStatuses(wf: Workflow, current: TaskStatus): TaskStatus[] {
+  const names = workflowStatuses(wf);
+  const allowed = wf.transitions?.[current];
+  const out = allowed ? names.filter((s) => s === current || allowed.includes(s)) : names;
+  return out.includes(current) ? out : [current, ...out];
+}
 
-export const STATUS_LABELS: Record<TaskStatus, string> = {
+// priorityRank orders priorities for sorting: the workflow's highest priority
+// is 0 and unknown or empty priorities sort last.
+export function priorityRank(wf: Workflow, priority: TaskPriority): number {
+  const i = wf.priorities.indexOf(priority);
+  return i < 0 ? wf.priorities.length : wf.priorities.length - 1 - i;
+}
+
+// humanize turns a workflow name like "in_review" into "In Review".
+function humanize(name: string): string {
+  return name
+    .split(/[_-]/)
+    .filter(Boolean)
+    .map((w) => w.charAt(0).toUpperCase() + w.slice(1))
+    .join(' ');
+}
+
+const FALLBACK_COLOR = '#768390';
+
+export const STATUS_LABELS: Record<string, string> = {
   todo: 'To Do',
   in_planning: 'In Planning',
   in_progress: 'In Progress',
@@ -52,7 +143,7 @@ export const STATUS_LABELS: Record<TaskStatus, string> = {
   blocked: 'Blocked',
 };
 
-export const STATUS_COLORS: Record<TaskStatus, string> = {
+export const STATUS_COLORS: Record<string, string> = {
   todo: '#768390',
   in_planning: '#a371f7',
   in_progress: '#d29922',
@@ -60,9 +151,15 @@ export const STATUS_COLORS: Record<TaskStatus, string> = {
   blocked: '#f85149',
 };
 
-export const PRIORITIES: TaskPriority[] = ['', 'low', 'medium', 'high', 'urgent'];
+export function statusLabel(status: TaskStatus): string {
+  return STATUS_LABELS[status] ?? humanize(status);
+}
+
+export function statusColor(status: TaskStatus): string {
+  return STATUS_COLORS[status] ?? FALLBACK_COLOR;
+}
 
-export const PRIORITY_LABELS: Record<TaskPriority, string> = {
+export const PRIORITY_LABELS: Record<string, string> = {
   '': 'None',
   low: 'Low',
   medium: 'Medium',
@@ -70,7 +167,7 @@ export const PRIORITY_LABELS: Record<TaskPriority, string> = {
   urgent: 'Urgent',
 };
 
-export const PRIORITY_COLORS: Record<TaskPriority, string> = {
+export const PRIORITY_COLORS: Record<string, string> = {
   '': '#484f58',
   low: '#3fb950',
   medium: '#d29922',
@@ -78,9 +175,15 @@ export const PRIORITY_COLORS: Record<TaskPriority, string> = {
   urgent: '#da3633',
 };
 
-export const TASK_TYPES: TaskType[] = ['', 'bug', 'feature', 'improvement', 'chore'];
+export function priorityLabel(priority: TaskPriority): string {
+  return PRIORITY_LABELS[priority] ?? humanize(priority);
+}
+
+export function priorityColor(priority: TaskPriority): string {
+  return PRIORITY_COLORS[priority] ?? FALLBACK_COLOR;
+}
 
-export const TYPE_LABELS: Record<TaskType, string> = {
+export const TYPE_LABELS: Record<string, string> = {
   '': 'None',
   bug: 'Bug',
   feature: 'Feature',
@@ -88,7 +191,7 @@ export const TYPE_LABELS: Record<TaskType, string> = {
   chore: 'Chore',
 };
 
-export const TYPE_COLORS: Record<TaskType, string> = {
+export const TYPE_COLORS: Record<string, string> = {
   '': '#484f58',
   bug: '#f85149',
   feature: '#a371f7',
@@ -96,6 +199,14 @@ export const TYPE_COLORS: Record<TaskType, string> = {
   chore: '#768390',
 };
 
+export function typeLabel(type: TaskType): string {
+  return TYPE_LABELS[type] ?? humanize(type);
+}
+
+export function typeColor(type: TaskType): string {
+  return TYPE_COLORS[type] ?? FALLBACK_COLOR;
+}
+
 export type EventType = 'log' | 'decision' | 'note';
 
 export const EVENT_TYPES: EventType[] = ['log', 'decision', 'note'];